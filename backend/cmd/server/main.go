@@ -15,17 +15,38 @@ import (
 
 	"webcrawler/internal/api"
 	"webcrawler/internal/config"
+	"webcrawler/internal/search"
 	"webcrawler/internal/storage"
 )
 
 func main() {
 	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
 	var store storage.Store
 	storageMode := "memory"
-	if cfg.DisableDB || cfg.DatabaseURL == "" {
+	switch {
+	case cfg.Storage == "sqlite":
+		sqliteStore, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err = sqliteStore.Migrate(ctx)
+			cancel()
+		}
+		if err != nil {
+			log.Printf("sqlite %s failed (%v); falling back to memory store", cfg.SQLitePath, err)
+			store = storage.NewMemory()
+		} else {
+			defer sqliteStore.DB().Close()
+			store = sqliteStore
+			storageMode = "sqlite"
+			log.Printf("using sqlite store at %s", cfg.SQLitePath)
+		}
+	case cfg.Storage == "memory" || cfg.DatabaseURL == "":
 		store = storage.NewMemory()
 		log.Printf("running in memory-only mode (no database)")
-	} else {
+	default:
 		db, err := sql.Open("pgx", cfg.DatabaseURL)
 		if err != nil {
 			log.Printf("db open failed (%v); falling back to memory store", err)
@@ -45,8 +66,14 @@ func main() {
 		}
 	}
 
-	runManager := api.NewRunManager(store, cfg.Defaults)
-	server := api.NewServer(runManager, cfg.AllowedOrigin, storageMode)
+	runManager := api.NewRunManager(store, cfg.Defaults, search.NewManager(cfg.SearchIndexDir))
+	schedules := api.NewScheduleManager(store, runManager)
+	loadCtx, loadCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := schedules.Load(loadCtx); err != nil {
+		log.Printf("load schedules: %v", err)
+	}
+	loadCancel()
+	server := api.NewServer(runManager, schedules, cfg.AllowedOrigin, storageMode)
 
 	srv := &http.Server{
 		Addr:    ":" + itoa(cfg.Port),
@@ -68,6 +95,7 @@ func main() {
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
+	schedules.Close()
 	_ = srv.Shutdown(shutdownCtx)
 	log.Printf("shutdown complete")
 }
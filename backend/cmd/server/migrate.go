@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"webcrawler/internal/config"
+	"webcrawler/internal/storage"
+)
+
+const migrateUsage = "usage: server migrate up|down [steps]|status"
+
+// runMigrate handles `server migrate ...` against the configured store and
+// returns the process exit code.
+func runMigrate(cfg config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	var db *sql.DB
+	var m *storage.Migrator
+	switch {
+	case cfg.Storage == "sqlite":
+		sqliteStore, err := storage.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open sqlite %s: %v\n", cfg.SQLitePath, err)
+			return 1
+		}
+		db, m = sqliteStore.DB(), sqliteStore.Migrator()
+	case cfg.Storage == "memory" || cfg.DatabaseURL == "":
+		fmt.Fprintln(os.Stderr, "migrate needs a database; set DATABASE_URL or STORAGE=sqlite:<path>")
+		return 1
+	default:
+		pg, err := sql.Open("pgx", cfg.DatabaseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open database: %v\n", err)
+			return 1
+		}
+		db, m = pg, storage.NewSQL(pg).Migrator()
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down: %v\n", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no migrations applied")
+		}
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range status {
+			at := "pending"
+			if st.AppliedAt != nil {
+				at = st.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, at)
+		}
+		tw.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
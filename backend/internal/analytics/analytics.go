@@ -0,0 +1,318 @@
+// Package analytics computes link-graph metrics for a finished run: PageRank
+// over URLs and hosts, hubs and authorities, strongly connected components,
+// orphan pages and click depth. The graphs are held in memory, so cost grows
+// with the run's page and link counts rather than being batch bounded.
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+// Version is the format of the stored Result. Results stored in another
+// format are treated as not computed.
+const Version = 1
+
+const (
+	batchSize = 1000
+	// topN bounds every ranked list.
+	topN = 20
+	// largestComponents and componentSample bound the components listing.
+	largestComponents = 10
+	componentSample   = 10
+	orphanSample      = 100
+)
+
+var ErrNotComputed = errors.New("analytics have not been computed for this run")
+
+type Score struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+type PageRank struct {
+	URLs  []Score `json:"urls"`
+	Hosts []Score `json:"hosts"`
+}
+
+type Component struct {
+	Size int `json:"size"`
+	// URLs is a sorted sample of the component's pages.
+	URLs []string `json:"urls"`
+}
+
+type Components struct {
+	Count      int         `json:"count"`
+	Singletons int         `json:"singletons"`
+	Largest    []Component `json:"largest"`
+}
+
+// Orphans are pages no other stored page links to, so the crawl only knew
+// them as seeds.
+type Orphans struct {
+	Count int      `json:"count"`
+	URLs  []string `json:"urls"`
+}
+
+type DepthCount struct {
+	Depth int `json:"depth"`
+	Pages int `json:"pages"`
+}
+
+// Depths compares the shortest link path from a seed (click depth) with the
+// depth at which the crawler discovered each page.
+type Depths struct {
+	Click       []DepthCount `json:"click"`
+	Unreachable int          `json:"unreachable"`
+	Crawl       []DepthCount `json:"crawl"`
+}
+
+type Result struct {
+	Pages       int        `json:"pages"`
+	Links       int        `json:"links"`
+	Hosts       int        `json:"hosts"`
+	PageRank    PageRank   `json:"pagerank"`
+	Hubs        []Score    `json:"hubs"`
+	Authorities []Score    `json:"authorities"`
+	Components  Components `json:"components"`
+	Orphans     Orphans    `json:"orphans"`
+	Depth       Depths     `json:"depth"`
+}
+
+// Stored is a result read back from the store.
+type Stored struct {
+	ComputedAt time.Time `json:"computed_at"`
+	Result
+}
+
+// Run computes the run's analytics and replaces any stored result.
+func Run(ctx context.Context, store storage.Store, runID uuid.UUID, now time.Time) (Stored, error) {
+	result, err := Compute(ctx, store, runID)
+	if err != nil {
+		return Stored{}, err
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return Stored{}, err
+	}
+	rec := storage.RunAnalyticsRecord{RunID: runID, Version: Version, Result: encoded, ComputedAt: now.UTC()}
+	if err := store.UpsertRunAnalytics(ctx, rec); err != nil {
+		return Stored{}, err
+	}
+	return Stored{ComputedAt: rec.ComputedAt, Result: result}, nil
+}
+
+// Load returns the stored result, or ErrNotComputed when there is none in
+// the current format.
+func Load(ctx context.Context, store storage.Store, runID uuid.UUID) (Stored, error) {
+	rec, err := store.GetRunAnalytics(ctx, runID)
+	if err != nil {
+		return Stored{}, err
+	}
+	if rec == nil || rec.Version != Version {
+		return Stored{}, ErrNotComputed
+	}
+	out := Stored{ComputedAt: rec.ComputedAt}
+	if err := json.Unmarshal(rec.Result, &out.Result); err != nil {
+		return Stored{}, err
+	}
+	return out, nil
+}
+
+// Compute reads the run's pages, links and host edges and derives the
+// metrics. The URL graph only has stored pages as nodes: links to URLs that
+// were never fetched are dropped, and links to a redirect's origin count
+// towards the page it redirected to.
+func Compute(ctx context.Context, store storage.Store, runID uuid.UUID) (Result, error) {
+	urls, seeds, crawlDepth, err := loadURLGraph(ctx, store, runID)
+	if err != nil {
+		return Result{}, err
+	}
+	links, err := linkURLGraph(ctx, store, runID, urls)
+	if err != nil {
+		return Result{}, err
+	}
+	hosts, err := loadHostGraph(ctx, store, runID)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Pages: len(urls.ids), Links: links, Hosts: len(hosts.ids)}
+	result.PageRank.URLs = urls.top(urls.pageRank(), topN)
+	result.PageRank.Hosts = hosts.top(hosts.pageRank(), topN)
+	hubs, authorities := urls.hits()
+	result.Hubs = urls.top(hubs, topN)
+	result.Authorities = urls.top(authorities, topN)
+	result.Components = summarizeComponents(urls)
+	result.Orphans = findOrphans(urls)
+	result.Depth = depthDistribution(urls, seeds, crawlDepth)
+	return result, nil
+}
+
+// loadURLGraph adds one node per stored page, in URL order. It returns the
+// pages discovered at depth 0 and every page's crawl depth by node.
+func loadURLGraph(ctx context.Context, store storage.Store, runID uuid.UUID) (*graph, []int, []int, error) {
+	g := newGraph()
+	var seeds, depth []int
+	origins := map[string]int{}
+	q := storage.PageQuery{Sort: storage.PageSortURL, Limit: batchSize}
+	for {
+		rows, err := store.QueryPages(ctx, runID, q)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, row := range rows {
+			if _, dup := g.index[row.CanonicalURL]; dup {
+				continue
+			}
+			i := g.add(row.CanonicalURL)
+			depth = append(depth, row.Depth)
+			if row.Depth == 0 {
+				seeds = append(seeds, i)
+			}
+			if row.OriginURL != "" && row.OriginURL != row.CanonicalURL {
+				origins[row.OriginURL] = i
+			}
+		}
+		if len(rows) < q.Limit {
+			break
+		}
+		cur := storage.PageCursorFor(rows[len(rows)-1], q.Sort)
+		q.After = &cur
+	}
+	// a redirect origin that is also a stored page keeps its own node
+	for origin, i := range origins {
+		if _, ok := g.index[origin]; !ok {
+			g.index[origin] = i
+		}
+	}
+	return g, seeds, depth, nil
+}
+
+func linkURLGraph(ctx context.Context, store storage.Store, runID uuid.UUID, g *graph) (int, error) {
+	links := 0
+	q := storage.LinkEdgeQuery{Limit: batchSize}
+	for {
+		batch, err := store.ListLinkEdges(ctx, runID, q)
+		if err != nil {
+			return links, err
+		}
+		for _, e := range batch {
+			src, okSrc := g.index[e.Src]
+			dst, okDst := g.index[e.Dst]
+			if okSrc && okDst && g.link(src, dst, float64(e.Count)) {
+				links++
+			}
+		}
+		if len(batch) < q.Limit {
+			return links, nil
+		}
+		q.After = &batch[len(batch)-1]
+	}
+}
+
+// loadHostGraph has a node for every host with pages or cross-host edges.
+func loadHostGraph(ctx context.Context, store storage.Store, runID uuid.UUID) (*graph, error) {
+	g := newGraph()
+	counts, err := store.HostPageCounts(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		g.add(c.Host)
+	}
+	q := storage.EdgeQuery{Limit: batchSize}
+	for {
+		batch, err := store.ListEdges(ctx, runID, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range batch {
+			g.link(g.add(e.Src), g.add(e.Dst), float64(e.Count))
+		}
+		if len(batch) < q.Limit {
+			return g, nil
+		}
+		last := batch[len(batch)-1]
+		q.After = &storage.EdgeCursor{Count: last.Count, Src: last.Src, Dst: last.Dst}
+	}
+}
+
+func summarizeComponents(g *graph) Components {
+	comps := g.components()
+	out := Components{Count: len(comps)}
+	samples := make([][]string, len(comps))
+	for i, comp := range comps {
+		if len(comp) == 1 {
+			out.Singletons++
+		}
+		urls := make([]string, len(comp))
+		for j, node := range comp {
+			urls[j] = g.ids[node]
+		}
+		sort.Strings(urls)
+		samples[i] = urls
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if len(samples[i]) != len(samples[j]) {
+			return len(samples[i]) > len(samples[j])
+		}
+		return samples[i][0] < samples[j][0]
+	})
+	for _, urls := range samples {
+		// singletons say nothing beyond their count
+		if len(out.Largest) == largestComponents || len(urls) == 1 {
+			break
+		}
+		out.Largest = append(out.Largest, Component{Size: len(urls), URLs: urls[:min(len(urls), componentSample)]})
+	}
+	return out
+}
+
+func findOrphans(g *graph) Orphans {
+	var out Orphans
+	for i, in := range g.inbound {
+		if in > 0 {
+			continue
+		}
+		out.Count++
+		if len(out.URLs) < orphanSample {
+			out.URLs = append(out.URLs, g.ids[i])
+		}
+	}
+	return out
+}
+
+func depthDistribution(g *graph, seeds, crawlDepth []int) Depths {
+	var out Depths
+	click := map[int]int{}
+	for _, d := range g.distances(seeds) {
+		if d < 0 {
+			out.Unreachable++
+			continue
+		}
+		click[d]++
+	}
+	crawl := map[int]int{}
+	for _, d := range crawlDepth {
+		crawl[d]++
+	}
+	out.Click = histogram(click)
+	out.Crawl = histogram(crawl)
+	return out
+}
+
+func histogram(counts map[int]int) []DepthCount {
+	out := make([]DepthCount, 0, len(counts))
+	for depth, pages := range counts {
+		out = append(out, DepthCount{Depth: depth, Pages: pages})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Depth < out[j].Depth })
+	return out
+}
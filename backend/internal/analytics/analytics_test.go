@@ -0,0 +1,183 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+// testRun is a memory store holding one run. Its helpers fail the test on
+// any store error.
+type testRun struct {
+	t     *testing.T
+	store storage.Store
+	id    uuid.UUID
+	now   time.Time
+}
+
+func newTestRun(t *testing.T) *testRun {
+	t.Helper()
+	store := storage.NewMemory()
+	id, err := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: "https://a.test/"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	return &testRun{t: t, store: store, id: id, now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// page is a page of the run fetched at r.now.
+func (r *testRun) page(u, host string, depth, status int) storage.PageRecord {
+	return storage.PageRecord{RunID: r.id, URL: u, CanonicalURL: u, Host: host, Depth: depth, StatusCode: status, DiscoveredAt: r.now, FetchedAt: &r.now}
+}
+
+func (r *testRun) insertPages(recs ...storage.PageRecord) {
+	r.t.Helper()
+	if err := r.store.InsertPages(context.Background(), recs); err != nil {
+		r.t.Fatalf("insert pages: %v", err)
+	}
+}
+
+// insertLinks stores one anchor link per src, dst pair.
+func (r *testRun) insertLinks(pairs ...[2]string) {
+	r.t.Helper()
+	links := make([]storage.LinkRecord, 0, len(pairs))
+	for _, p := range pairs {
+		links = append(links, storage.LinkRecord{RunID: r.id, SrcURL: p[0], DstURL: p[1], Element: "a"})
+	}
+	if err := r.store.InsertLinks(context.Background(), links); err != nil {
+		r.t.Fatalf("insert links: %v", err)
+	}
+}
+
+func (r *testRun) upsertEdges(recs ...storage.EdgeRecord) {
+	r.t.Helper()
+	for i := range recs {
+		recs[i].RunID = r.id
+	}
+	if err := r.store.UpsertEdges(context.Background(), recs); err != nil {
+		r.t.Fatalf("upsert edges: %v", err)
+	}
+}
+
+func seedRun(t *testing.T) (storage.Store, uuid.UUID) {
+	t.Helper()
+	r := newTestRun(t)
+	moved := r.page("https://a.test/new", "a.test", 1, 200)
+	moved.OriginURL = "https://a.test/old"
+	r.insertPages(
+		r.page("https://a.test/", "a.test", 0, 200),
+		r.page("https://a.test/a", "a.test", 1, 200),
+		r.page("https://a.test/b", "a.test", 1, 200),
+		r.page("https://b.test/c", "b.test", 2, 200),
+		moved,
+		r.page("https://a.test/lonely", "a.test", 1, 200),
+	)
+	r.insertLinks(
+		[2]string{"https://a.test/", "https://a.test/a"},
+		[2]string{"https://a.test/", "https://a.test/old"},
+		[2]string{"https://a.test/", "https://x.test/never-fetched"},
+		[2]string{"https://a.test/a", "https://a.test/b"},
+		[2]string{"https://a.test/b", "https://a.test/a"},
+		[2]string{"https://a.test/b", "https://b.test/c"},
+		[2]string{"https://b.test/c", "https://b.test/c"},
+	)
+	r.upsertEdges(storage.EdgeRecord{Src: "a.test", Dst: "b.test", Count: 1})
+	return r.store, r.id
+}
+
+func TestComputeOverStoredRun(t *testing.T) {
+	store, runID := seedRun(t)
+	r, err := Compute(context.Background(), store, runID)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	// the self-link and the link to an unfetched URL are not edges
+	if r.Pages != 6 || r.Links != 5 || r.Hosts != 2 {
+		t.Fatalf("unexpected graph size: %+v", r)
+	}
+
+	sum := 0.0
+	for _, s := range r.PageRank.URLs {
+		sum += s.Score
+	}
+	if len(r.PageRank.URLs) != 6 || math.Abs(sum-1) > 1e-6 {
+		t.Fatalf("expected ranks of all six pages summing to 1, got %+v (sum %v)", r.PageRank.URLs, sum)
+	}
+	if top := r.PageRank.URLs[0].ID; top != "https://a.test/a" && top != "https://a.test/b" {
+		t.Fatalf("expected the linked pair to rank highest, got %+v", r.PageRank.URLs)
+	}
+	if len(r.PageRank.Hosts) != 2 || r.PageRank.Hosts[0].ID != "b.test" {
+		t.Fatalf("expected the linked host to rank first, got %+v", r.PageRank.Hosts)
+	}
+	// the seed and /b both link to /a and one other page
+	if len(r.Hubs) < 2 || r.Hubs[0].ID != "https://a.test/" || r.Hubs[1].ID != "https://a.test/b" || math.Abs(r.Hubs[0].Score-r.Hubs[1].Score) > 1e-9 {
+		t.Fatalf("unexpected hubs: %+v", r.Hubs)
+	}
+	if len(r.Authorities) == 0 || r.Authorities[0].ID != "https://a.test/a" {
+		t.Fatalf("unexpected authorities: %+v", r.Authorities)
+	}
+
+	wantComponents := Components{Count: 5, Singletons: 4, Largest: []Component{{Size: 2, URLs: []string{"https://a.test/a", "https://a.test/b"}}}}
+	if !reflect.DeepEqual(r.Components, wantComponents) {
+		t.Fatalf("components:\n got %+v\nwant %+v", r.Components, wantComponents)
+	}
+	// the redirect target is linked through its origin, so it is no orphan
+	wantOrphans := Orphans{Count: 2, URLs: []string{"https://a.test/", "https://a.test/lonely"}}
+	if !reflect.DeepEqual(r.Orphans, wantOrphans) {
+		t.Fatalf("orphans:\n got %+v\nwant %+v", r.Orphans, wantOrphans)
+	}
+	wantDepth := Depths{
+		Click:       []DepthCount{{0, 1}, {1, 2}, {2, 1}, {3, 1}},
+		Unreachable: 1,
+		Crawl:       []DepthCount{{0, 1}, {1, 4}, {2, 1}},
+	}
+	if !reflect.DeepEqual(r.Depth, wantDepth) {
+		t.Fatalf("depths:\n got %+v\nwant %+v", r.Depth, wantDepth)
+	}
+}
+
+func TestRunStoresResultForLoad(t *testing.T) {
+	ctx := context.Background()
+	store, runID := seedRun(t)
+	if _, err := Load(ctx, store, runID); !errors.Is(err, ErrNotComputed) {
+		t.Fatalf("expected ErrNotComputed before the job ran, got %v", err)
+	}
+	at := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	ran, err := Run(ctx, store, runID, at)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	loaded, err := Load(ctx, store, runID)
+	if err != nil || !loaded.ComputedAt.Equal(at) || !reflect.DeepEqual(loaded.Result, ran.Result) {
+		t.Fatalf("expected the stored result back, got %+v (%v)", loaded, err)
+	}
+
+	if err := store.UpsertRunAnalytics(ctx, storage.RunAnalyticsRecord{RunID: runID, Version: Version + 1, Result: []byte(`{}`), ComputedAt: at}); err != nil {
+		t.Fatalf("upsert analytics: %v", err)
+	}
+	if _, err := Load(ctx, store, runID); !errors.Is(err, ErrNotComputed) {
+		t.Fatalf("expected results in another format to be ignored, got %v", err)
+	}
+}
+
+func TestComponentsOfLongCycle(t *testing.T) {
+	g := newGraph()
+	const n = 200000
+	for i := 0; i < n; i++ {
+		g.add(strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		g.link(i, (i+1)%n, 1)
+	}
+	comps := g.components()
+	if len(comps) != 1 || len(comps[0]) != n {
+		t.Fatalf("expected one component of %d nodes, got %d components", n, len(comps))
+	}
+}
@@ -0,0 +1,271 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	damping       = 0.85
+	maxIterations = 100
+	// tolerance is the L1 change between iterations below which PageRank and
+	// HITS are considered converged.
+	tolerance = 1e-9
+)
+
+type arc struct {
+	to     int
+	weight float64
+}
+
+// graph is a weighted directed graph over string ids. Parallel arcs are
+// allowed and behave like one arc with the summed weight.
+type graph struct {
+	ids   []string
+	index map[string]int
+	out   [][]arc
+	// inbound counts arcs from other nodes; self-loops are never stored.
+	inbound []int
+}
+
+func newGraph() *graph {
+	return &graph{index: map[string]int{}}
+}
+
+func (g *graph) add(id string) int {
+	if i, ok := g.index[id]; ok {
+		return i
+	}
+	g.index[id] = len(g.ids)
+	g.ids = append(g.ids, id)
+	g.out = append(g.out, nil)
+	g.inbound = append(g.inbound, 0)
+	return len(g.ids) - 1
+}
+
+// link reports whether the arc was added; self-loops carry no ranking signal
+// and are dropped.
+func (g *graph) link(src, dst int, weight float64) bool {
+	if src == dst || weight <= 0 {
+		return false
+	}
+	g.out[src] = append(g.out[src], arc{to: dst, weight: weight})
+	g.inbound[dst]++
+	return true
+}
+
+// pageRank runs weighted power iteration. Rank of nodes without outgoing
+// arcs is spread over every node, so the scores always sum to 1.
+func (g *graph) pageRank() []float64 {
+	n := len(g.ids)
+	if n == 0 {
+		return nil
+	}
+	outWeight := make([]float64, n)
+	for i, arcs := range g.out {
+		for _, a := range arcs {
+			outWeight[i] += a.weight
+		}
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < maxIterations; iter++ {
+		dangling := 0.0
+		for i, w := range outWeight {
+			if w == 0 {
+				dangling += rank[i]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, arcs := range g.out {
+			for _, a := range arcs {
+				next[a.to] += damping * rank[i] * a.weight / outWeight[i]
+			}
+		}
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < tolerance {
+			break
+		}
+	}
+	return rank
+}
+
+// hits computes Kleinberg hub and authority scores, each normalised to unit
+// length. Nodes outside every link have zero scores.
+func (g *graph) hits() (hubs, authorities []float64) {
+	n := len(g.ids)
+	hubs = make([]float64, n)
+	authorities = make([]float64, n)
+	for i := range hubs {
+		hubs[i] = 1
+	}
+	normalize(hubs)
+	next := make([]float64, n)
+	for iter := 0; iter < maxIterations; iter++ {
+		for i := range authorities {
+			authorities[i] = 0
+		}
+		for i, arcs := range g.out {
+			for _, a := range arcs {
+				authorities[a.to] += hubs[i] * a.weight
+			}
+		}
+		normalize(authorities)
+		for i, arcs := range g.out {
+			next[i] = 0
+			for _, a := range arcs {
+				next[i] += authorities[a.to] * a.weight
+			}
+		}
+		normalize(next)
+		delta := 0.0
+		for i := range hubs {
+			delta += math.Abs(next[i] - hubs[i])
+		}
+		hubs, next = next, hubs
+		if delta < tolerance {
+			break
+		}
+	}
+	return hubs, authorities
+}
+
+func normalize(v []float64) {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	if sum == 0 {
+		return
+	}
+	norm := math.Sqrt(sum)
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// components returns the strongly connected components using Tarjan's
+// algorithm with an explicit stack, since crawl graphs can be deeper than
+// the goroutine stack allows.
+func (g *graph) components() [][]int {
+	n := len(g.ids)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	var out [][]int
+	type frame struct{ node, arc int }
+	next := 0
+	visit := func(v int) {
+		index[v], low[v] = next, next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+	}
+	for root := 0; root < n; root++ {
+		if index[root] != -1 {
+			continue
+		}
+		visit(root)
+		calls := []frame{{node: root}}
+		for len(calls) > 0 {
+			top := &calls[len(calls)-1]
+			v := top.node
+			if top.arc < len(g.out[v]) {
+				w := g.out[v][top.arc].to
+				top.arc++
+				if index[w] == -1 {
+					visit(w)
+					calls = append(calls, frame{node: w})
+				} else if onStack[w] {
+					low[v] = min(low[v], index[w])
+				}
+				continue
+			}
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].node
+				low[parent] = min(low[parent], low[v])
+			}
+			if low[v] != index[v] {
+				continue
+			}
+			var comp []int
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp = append(comp, w)
+				if w == v {
+					break
+				}
+			}
+			out = append(out, comp)
+		}
+	}
+	return out
+}
+
+// distances is the number of arcs on the shortest path from any source, or
+// -1 for nodes no source reaches.
+func (g *graph) distances(sources []int) []int {
+	dist := make([]int, len(g.ids))
+	for i := range dist {
+		dist[i] = -1
+	}
+	queue := make([]int, 0, len(sources))
+	for _, s := range sources {
+		if dist[s] == -1 {
+			dist[s] = 0
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, a := range g.out[v] {
+			if dist[a.to] == -1 {
+				dist[a.to] = dist[v] + 1
+				queue = append(queue, a.to)
+			}
+		}
+	}
+	return dist
+}
+
+// top returns the n highest scores, ties broken by id. Zero scores are left
+// out.
+func (g *graph) top(scores []float64, n int) []Score {
+	out := make([]Score, 0, min(n, len(scores)))
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if scores[i] != scores[j] {
+			return scores[i] > scores[j]
+		}
+		return g.ids[i] < g.ids[j]
+	})
+	for _, i := range order {
+		if len(out) == n || scores[i] == 0 {
+			break
+		}
+		out = append(out, Score{ID: g.ids[i], Score: scores[i]})
+	}
+	return out
+}
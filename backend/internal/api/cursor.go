@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+// Cursors are opaque to clients: the JSON of the last row's sort key,
+// base64url encoded.
+func encodeCursor(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return errBadCursor
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errBadCursor
+	}
+	return nil
+}
@@ -3,48 +3,100 @@ package api
 import (
 	"context"
 	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"webcrawler/internal/analytics"
 	"webcrawler/internal/config"
 	"webcrawler/internal/crawler"
+	"webcrawler/internal/export"
 	"webcrawler/internal/metrics"
+	"webcrawler/internal/report"
+	"webcrawler/internal/search"
 	"webcrawler/internal/storage"
 )
 
 type RunState struct {
-	ID        uuid.UUID
-	Config    crawler.RunConfig
-	Status    string
-	Engine    *crawler.Engine
-	Telemetry *metrics.Telemetry
-	CreatedAt time.Time
-	StartedAt *time.Time
-	StoppedAt *time.Time
+	ID         uuid.UUID
+	Config     crawler.RunConfig
+	Status     string
+	Engine     *crawler.Engine
+	Telemetry  *metrics.Telemetry
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	StoppedAt  *time.Time
 	StopReason string
+	// ScheduleID is set for runs started by a schedule.
+	ScheduleID uuid.UUID
 }
 
+var (
+	ErrRunNotFound   = errors.New("run not found")
+	ErrRunNotRunning = errors.New("run is not running")
+	ErrRunNotPaused  = errors.New("run is not paused")
+	ErrRunStopped    = errors.New("run has stopped")
+)
+
+// stopReasonStartFailed marks a scheduled run that was created but could
+// not be started.
+const stopReasonStartFailed = "start_failed"
+
 type RunManager struct {
 	store    storage.Store
 	defaults config.CrawlerDefaults
+	search   *search.Manager
 	mu       sync.Mutex
 	runs     map[uuid.UUID]*RunState
 }
 
-func NewRunManager(store storage.Store, defaults config.CrawlerDefaults) *RunManager {
+func NewRunManager(store storage.Store, defaults config.CrawlerDefaults, searchIndexes *search.Manager) *RunManager {
 	return &RunManager{
 		store:    store,
 		defaults: defaults,
+		search:   searchIndexes,
 		runs:     make(map[uuid.UUID]*RunState),
 	}
 }
 
 func (rm *RunManager) CreateRun(ctx context.Context, cfg crawler.RunConfig) (uuid.UUID, error) {
+	return rm.createRun(ctx, cfg, uuid.Nil)
+}
+
+func (rm *RunManager) createRun(ctx context.Context, cfg crawler.RunConfig, scheduleID uuid.UUID) (uuid.UUID, error) {
 	cfg = rm.applyDefaults(cfg)
 	cfg = cfg.Normalize()
-	id, err := rm.store.CreateRun(ctx, storage.RunConfig{
+	rec, err := runRecord(cfg, scheduleID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := rm.store.CreateRun(ctx, rec)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	rm.mu.Lock()
+	rm.runs[id] = &RunState{ID: id, Config: cfg, Status: "created", CreatedAt: time.Now(), ScheduleID: scheduleID}
+	rm.mu.Unlock()
+	return id, nil
+}
+
+// runRecord is the stored form of a run configuration.
+func runRecord(cfg crawler.RunConfig, scheduleID uuid.UUID) (storage.RunConfig, error) {
+	encoded, version, err := crawler.EncodeRunConfig(cfg)
+	if err != nil {
+		return storage.RunConfig{}, err
+	}
+	var seedHost string
+	if _, u, err := crawler.Canonicalize(cfg.SeedURL); err == nil {
+		seedHost = strings.ToLower(u.Hostname())
+	}
+	return storage.RunConfig{
 		SeedURL:            cfg.SeedURL,
+		SeedHost:           seedHost,
 		MaxDepth:           cfg.MaxDepth,
 		MaxPages:           cfg.MaxPages,
 		TimeBudgetSeconds:  int(cfg.TimeBudget.Seconds()),
@@ -53,14 +105,10 @@ func (rm *RunManager) CreateRun(ctx context.Context, cfg crawler.RunConfig) (uui
 		PerHostConcurrency: cfg.PerHostConcurrency,
 		UserAgent:          cfg.UserAgent,
 		RespectRobots:      cfg.RespectRobots,
-	})
-	if err != nil {
-		return uuid.Nil, err
-	}
-	rm.mu.Lock()
-	rm.runs[id] = &RunState{ID: id, Config: cfg, Status: "created", CreatedAt: time.Now()}
-	rm.mu.Unlock()
-	return id, nil
+		Config:             encoded,
+		ConfigVersion:      version,
+		ScheduleID:         scheduleID,
+	}, nil
 }
 
 func (rm *RunManager) StartRun(ctx context.Context, id uuid.UUID) error {
@@ -68,7 +116,7 @@ func (rm *RunManager) StartRun(ctx context.Context, id uuid.UUID) error {
 	state, ok := rm.runs[id]
 	rm.mu.Unlock()
 	if !ok {
-		return errors.New("run not found")
+		return ErrRunNotFound
 	}
 	if state.Engine != nil {
 		return errors.New("run already started")
@@ -76,20 +124,26 @@ func (rm *RunManager) StartRun(ctx context.Context, id uuid.UUID) error {
 
 	telemetry := metrics.NewTelemetry()
 	engine := crawler.NewEngine(id, state.Config, rm.store, telemetry)
+	if state.Config.ExtractText && rm.search != nil {
+		engine.SetTextIndex(rm.search.Open(id))
+	}
 	now := time.Now()
+	if err := rm.store.UpdateRunStatus(ctx, id, "running", &now, nil, nil); err != nil {
+		return err
+	}
 	state.Engine = engine
 	state.Telemetry = telemetry
 	state.Status = "running"
 	state.StartedAt = &now
-
-	if err := rm.store.UpdateRunStatus(ctx, id, "running", &now, nil, nil); err != nil {
-		return err
-	}
 	engine.Start(state.Config.SeedURL)
 	go func() {
-		<-engine.Done()
+		<-engine.Drained()
+		if state.Config.ExtractText && rm.search != nil {
+			if err := rm.search.Save(id); err != nil {
+				log.Printf("save search index %s: %v", id, err)
+			}
+		}
 		rm.mu.Lock()
-		defer rm.mu.Unlock()
 		stoppedAt := time.Now()
 		state.Status = "stopped"
 		state.StoppedAt = &stoppedAt
@@ -98,25 +152,178 @@ func (rm *RunManager) StartRun(ctx context.Context, id uuid.UUID) error {
 			stopReason = crawler.StopReasonUnknown
 		}
 		state.StopReason = stopReason
+		// replaces a paused status written while the engine was stopping
+		if err := rm.store.UpdateRunStatus(context.Background(), id, "stopped", nil, &stoppedAt, &stopReason); err != nil {
+			log.Printf("update run %s: %v", id, err)
+		}
+		rm.mu.Unlock()
+		if _, err := analytics.Run(context.Background(), rm.store, id, time.Now()); err != nil {
+			log.Printf("analytics for run %s: %v", id, err)
+		}
 	}()
 	return nil
 }
 
-func (rm *RunManager) StopRun(ctx context.Context, id uuid.UUID) error {
+// startScheduledRun creates and starts a run for a schedule. The returned
+// channel is closed once the run has stopped and its records are written.
+func (rm *RunManager) startScheduledRun(ctx context.Context, scheduleID uuid.UUID, cfg crawler.RunConfig) (uuid.UUID, <-chan struct{}, error) {
+	id, err := rm.createRun(ctx, cfg, scheduleID)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	if err := rm.StartRun(ctx, id); err != nil {
+		// a scheduled run nobody will start must not stay "created"
+		rm.abandonRun(id)
+		return id, nil, err
+	}
 	rm.mu.Lock()
-	state, ok := rm.runs[id]
+	defer rm.mu.Unlock()
+	return id, rm.runs[id].Engine.Drained(), nil
+}
+
+// abandonRun marks a created run that could not be started as stopped.
+func (rm *RunManager) abandonRun(id uuid.UUID) {
+	now := time.Now()
+	reason := stopReasonStartFailed
+	rm.mu.Lock()
+	if state, ok := rm.runs[id]; ok {
+		state.Status = "stopped"
+		state.StoppedAt = &now
+		state.StopReason = reason
+	}
 	rm.mu.Unlock()
+	if err := rm.store.UpdateRunStatus(context.Background(), id, "stopped", nil, &now, &reason); err != nil {
+		log.Printf("update run %s: %v", id, err)
+	}
+}
+
+// StopRun stops the run and records actor in its event log.
+func (rm *RunManager) StopRun(ctx context.Context, id uuid.UUID, actor string) error {
+	return rm.stopRun(ctx, id, crawler.StopReasonManual, actor)
+}
+
+func (rm *RunManager) stopRun(ctx context.Context, id uuid.UUID, reason, actor string) error {
+	rm.mu.Lock()
+	state, ok := rm.runs[id]
 	if !ok {
-		return errors.New("run not found")
+		rm.mu.Unlock()
+		return ErrRunNotFound
 	}
 	if state.Engine != nil {
-		state.Engine.StopWithReason(crawler.StopReasonManual)
+		state.Engine.StopWithReason(reason)
 	}
 	now := time.Now()
 	state.Status = "stopped"
 	state.StoppedAt = &now
-	state.StopReason = crawler.StopReasonManual
-	return rm.store.UpdateRunStatus(ctx, id, "stopped", nil, &now, &state.StopReason)
+	state.StopReason = reason
+	rm.mu.Unlock()
+	if err := rm.store.UpdateRunStatus(ctx, id, "stopped", nil, &now, &reason); err != nil {
+		return err
+	}
+	return rm.recordEvent(ctx, id, storage.RunEventStop, actor, map[string]any{"reason": reason})
+}
+
+// PauseRun stops the run's engine from dispatching fetches while keeping its
+// frontier, dedup and host state in memory. Paused time does not count
+// against the time budget. The status is written under the lock so it cannot
+// land after the stopped status of a run that is ending.
+func (rm *RunManager) PauseRun(ctx context.Context, id uuid.UUID, actor string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	state, ok := rm.runs[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	if state.Status != "running" || state.Engine == nil || !state.Engine.Pause() {
+		return ErrRunNotRunning
+	}
+	state.Status = "paused"
+	if err := rm.store.UpdateRunStatus(ctx, id, "paused", nil, nil, nil); err != nil {
+		return err
+	}
+	return rm.recordEvent(ctx, id, storage.RunEventPause, actor, nil)
+}
+
+func (rm *RunManager) ResumeRun(ctx context.Context, id uuid.UUID, actor string) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	state, ok := rm.runs[id]
+	if !ok {
+		return ErrRunNotFound
+	}
+	if state.Status != "paused" || !state.Engine.Resume() {
+		return ErrRunNotPaused
+	}
+	state.Status = "running"
+	if err := rm.store.UpdateRunStatus(ctx, id, "running", nil, nil, nil); err != nil {
+		return err
+	}
+	return rm.recordEvent(ctx, id, storage.RunEventResume, actor, nil)
+}
+
+// ReconfigureRun applies p to a run that has not stopped and returns its new
+// configuration. Running and paused runs change live; runs not yet started
+// start with the new settings. The stored run is updated and the changed
+// settings are recorded in the run's event log with actor. A patch that
+// changes nothing records nothing. Callers validate p first.
+func (rm *RunManager) ReconfigureRun(ctx context.Context, id uuid.UUID, p crawler.ConfigPatch, actor string) (crawler.RunConfig, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	state, ok := rm.runs[id]
+	if !ok {
+		return crawler.RunConfig{}, ErrRunNotFound
+	}
+	old := state.Config
+	var cfg crawler.RunConfig
+	switch {
+	case state.Status == "created" && state.Engine == nil:
+		cfg = old.Apply(p)
+	case state.Status == "running" || state.Status == "paused":
+		if cfg, ok = state.Engine.Reconfigure(p); !ok {
+			return old, ErrRunStopped
+		}
+	default:
+		return old, ErrRunStopped
+	}
+	state.Config = cfg
+	changes := configChanges(old, cfg)
+	if len(changes) == 0 {
+		return cfg, nil
+	}
+	rec, err := runRecord(cfg, state.ScheduleID)
+	if err != nil {
+		return cfg, err
+	}
+	if err := rm.store.UpdateRunConfig(ctx, id, rec); err != nil {
+		return cfg, err
+	}
+	return cfg, rm.recordEvent(ctx, id, storage.RunEventConfig, actor, changes)
+}
+
+// configChanges lists the live settings that differ, keyed by their request
+// field names, as {"from": old, "to": new}.
+func configChanges(old, cfg crawler.RunConfig) map[string]any {
+	changes := map[string]any{}
+	add := func(name string, from, to any) {
+		if !reflect.DeepEqual(from, to) {
+			changes[name] = map[string]any{"from": from, "to": to}
+		}
+	}
+	add("global_concurrency", old.GlobalConcurrency, cfg.GlobalConcurrency)
+	add("per_host_concurrency", old.PerHostConcurrency, cfg.PerHostConcurrency)
+	add("host_concurrency", old.HostConcurrency, cfg.HostConcurrency)
+	add("time_budget_seconds", int(old.TimeBudget.Seconds()), int(cfg.TimeBudget.Seconds()))
+	add("max_pages", old.MaxPages, cfg.MaxPages)
+	return changes
+}
+
+func (rm *RunManager) recordEvent(ctx context.Context, id uuid.UUID, kind, actor string, detail map[string]any) error {
+	return rm.store.InsertRunEvent(ctx, storage.RunEvent{RunID: id, Kind: kind, Actor: actor, Detail: detail, At: time.Now().UTC()})
+}
+
+// RunEvents returns the run's event log, oldest first.
+func (rm *RunManager) RunEvents(ctx context.Context, id uuid.UUID, limit int) ([]storage.RunEvent, error) {
+	return rm.store.ListRunEvents(ctx, id, limit)
 }
 
 func (rm *RunManager) GetRun(ctx context.Context, id uuid.UUID) (RunState, error) {
@@ -130,20 +337,59 @@ func (rm *RunManager) GetRun(ctx context.Context, id uuid.UUID) (RunState, error
 	if err != nil {
 		return RunState{}, err
 	}
-	cfg := crawler.RunConfig{
-		SeedURL:            row.SeedURL,
-		MaxDepth:           row.MaxDepth,
-		MaxPages:           row.MaxPages,
-		TimeBudgetSeconds:  row.TimeBudgetSeconds,
-		MaxLinksPerPage:    row.MaxLinksPerPage,
-		GlobalConcurrency:  row.GlobalConcurrency,
-		PerHostConcurrency: row.PerHostConcurrency,
-		UserAgent:          row.UserAgent,
-		RespectRobots:      row.RespectRobots,
+	return runStateFromRow(row)
+}
+
+// RunListing is one row of ListRuns. Runs managed by this process carry
+// their live state rather than the last status written to the store; Cursor
+// always reflects the stored row so pagination stays stable.
+type RunListing struct {
+	State   RunState
+	Live    bool
+	Summary storage.RunSummary
+	Cursor  storage.RunCursor
+}
+
+func (rm *RunManager) ListRuns(ctx context.Context, q storage.RunQuery) ([]RunListing, error) {
+	rows, err := rm.store.ListRuns(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	summaries, err := rm.store.RunSummaries(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RunListing, 0, len(rows))
+	for _, row := range rows {
+		rm.mu.Lock()
+		live, ok := rm.runs[row.ID]
+		var state RunState
+		if ok {
+			state = *live
+		}
+		rm.mu.Unlock()
+		if !ok {
+			if state, err = runStateFromRow(row); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, RunListing{State: state, Live: ok, Summary: summaries[row.ID], Cursor: storage.RunCursorFor(row, q.Sort)})
+	}
+	return out, nil
+}
+
+func runStateFromRow(row storage.RunRow) (RunState, error) {
+	cfg, err := runConfigFromRow(row)
+	if err != nil {
+		return RunState{}, err
 	}
 	return RunState{
 		ID:        row.ID,
-		Config:    cfg.Normalize(),
+		Config:    cfg,
 		Status:    row.Status,
 		CreatedAt: row.CreatedAt,
 		StartedAt: func() *time.Time {
@@ -166,9 +412,30 @@ func (rm *RunManager) GetRun(ctx context.Context, id uuid.UUID) (RunState, error
 			}
 			return ""
 		}(),
+		ScheduleID: row.ScheduleID.UUID,
 	}, nil
 }
 
+// runConfigFromRow restores the stored configuration. Runs created before
+// configs were persisted only have the summary columns.
+func runConfigFromRow(row storage.RunRow) (crawler.RunConfig, error) {
+	if len(row.Config) > 0 && row.ConfigVersion.Valid {
+		return crawler.DecodeRunConfig(row.Config, int(row.ConfigVersion.Int64))
+	}
+	cfg := crawler.RunConfig{
+		SeedURL:            row.SeedURL,
+		MaxDepth:           row.MaxDepth,
+		MaxPages:           row.MaxPages,
+		TimeBudgetSeconds:  row.TimeBudgetSeconds,
+		MaxLinksPerPage:    row.MaxLinksPerPage,
+		GlobalConcurrency:  row.GlobalConcurrency,
+		PerHostConcurrency: row.PerHostConcurrency,
+		UserAgent:          row.UserAgent,
+		RespectRobots:      row.RespectRobots,
+	}
+	return cfg.Normalize(), nil
+}
+
 func (rm *RunManager) TelemetryFor(id uuid.UUID) (*metrics.Telemetry, bool) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
@@ -183,8 +450,92 @@ func (rm *RunManager) Summary(ctx context.Context, id uuid.UUID) (storage.RunSum
 	return rm.store.GetRunSummary(ctx, id)
 }
 
-func (rm *RunManager) ListPages(ctx context.Context, id uuid.UUID, limit int) ([]storage.PageRow, error) {
-	return rm.store.ListPages(ctx, id, limit)
+func (rm *RunManager) QueryPages(ctx context.Context, id uuid.UUID, q storage.PageQuery) ([]storage.PageRow, error) {
+	return rm.store.QueryPages(ctx, id, q)
+}
+
+func (rm *RunManager) Inlinks(ctx context.Context, id uuid.UUID, canonical string, limit int) ([]storage.LinkRow, error) {
+	return rm.store.ListInlinks(ctx, id, canonical, limit)
+}
+
+func (rm *RunManager) Outlinks(ctx context.Context, id uuid.UUID, canonical string, limit int) ([]storage.LinkRow, error) {
+	return rm.store.ListOutlinks(ctx, id, canonical, limit)
+}
+
+func (rm *RunManager) LinkStatuses(ctx context.Context, id uuid.UUID, failedOnly bool, limit int) ([]storage.LinkStatusRow, error) {
+	return rm.store.ListLinkStatuses(ctx, id, failedOnly, limit)
+}
+
+func (rm *RunManager) RedirectChains(ctx context.Context, id uuid.UUID, minHops, limit int) ([]storage.RedirectChainRow, error) {
+	return rm.store.ListRedirectChains(ctx, id, minHops, limit)
+}
+
+func (rm *RunManager) Security(ctx context.Context, id uuid.UUID, withinDays int, flaggedOnly bool) (report.SecurityReport, error) {
+	return report.Security(ctx, rm.store, id, withinDays, flaggedOnly, time.Now())
+}
+
+func (rm *RunManager) ErrorGroups(ctx context.Context, id uuid.UUID, q storage.ErrorGroupQuery) ([]storage.ErrorGroup, error) {
+	return rm.store.ListErrorGroups(ctx, id, q)
+}
+
+func (rm *RunManager) Hosts(ctx context.Context, id uuid.UUID, limit int) (report.HostsReport, error) {
+	return report.Hosts(ctx, rm.store, id, limit)
+}
+
+func (rm *RunManager) HostTimeseries(ctx context.Context, id uuid.UUID, q storage.HostStatQuery) ([]storage.HostStatRecord, error) {
+	return rm.store.QueryHostStats(ctx, id, q)
+}
+
+func (rm *RunManager) Edges(ctx context.Context, id uuid.UUID, q storage.EdgeQuery) ([]storage.EdgeRecord, error) {
+	return rm.store.ListEdges(ctx, id, q)
+}
+
+// Export streams a dataset of the run to w.
+func (rm *RunManager) Export(ctx context.Context, w io.Writer, id uuid.UUID, opts export.Options) (int64, error) {
+	return export.Write(ctx, w, rm.store, id, opts)
+}
+
+func (rm *RunManager) ExportGraph(ctx context.Context, w io.Writer, id uuid.UUID, opts export.GraphOptions) (export.GraphStats, error) {
+	return export.WriteGraph(ctx, w, rm.store, id, opts)
+}
+
+func (rm *RunManager) Analytics(ctx context.Context, id uuid.UUID) (analytics.Stored, error) {
+	return analytics.Load(ctx, rm.store, id)
+}
+
+// RecomputeAnalytics reruns the analytics job, for runs that stopped before
+// it existed or whose stored result is in an older format.
+func (rm *RunManager) RecomputeAnalytics(ctx context.Context, id uuid.UUID) (analytics.Stored, error) {
+	return analytics.Run(ctx, rm.store, id, time.Now())
+}
+
+func (rm *RunManager) Skipped(ctx context.Context, id uuid.UUID, reason string, limit int) ([]storage.SkipRecord, error) {
+	return rm.store.ListSkipped(ctx, id, reason, limit)
+}
+
+func (rm *RunManager) Explain(ctx context.Context, id uuid.UUID, canonical string) (report.Explanation, error) {
+	return report.Explain(ctx, rm.store, id, canonical)
+}
+
+// Diff passes every change from run a to run b to emit.
+func (rm *RunManager) Diff(ctx context.Context, a, b uuid.UUID, emit func(report.DiffChange) error) (report.DiffSummary, error) {
+	return report.Diff(ctx, rm.store, a, b, emit)
+}
+
+func (rm *RunManager) BrokenLinks(ctx context.Context, id uuid.UUID, cfg crawler.RunConfig) (report.BrokenLinks, error) {
+	return report.Broken(ctx, rm.store, id, cfg)
+}
+
+func (rm *RunManager) Search(ctx context.Context, id uuid.UUID, query string, limit int) ([]search.Hit, int, error) {
+	if rm.search == nil {
+		return nil, 0, search.ErrNoIndex
+	}
+	idx, err := rm.search.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits, total := idx.Search(query, limit)
+	return hits, total, nil
 }
 
 func (rm *RunManager) applyDefaults(cfg crawler.RunConfig) crawler.RunConfig {
@@ -239,5 +590,14 @@ func (rm *RunManager) applyDefaults(cfg crawler.RunConfig) crawler.RunConfig {
 	if cfg.CircuitResetTime == 0 {
 		cfg.CircuitResetTime = rm.defaults.CircuitResetTime
 	}
+	if cfg.MaxRedirects == 0 {
+		cfg.MaxRedirects = rm.defaults.MaxRedirects
+	}
+	if cfg.WriteBatchSize == 0 {
+		cfg.WriteBatchSize = rm.defaults.WriteBatchSize
+	}
+	if cfg.WriteFlushInterval == 0 {
+		cfg.WriteFlushInterval = rm.defaults.WriteFlushInterval
+	}
 	return cfg
 }
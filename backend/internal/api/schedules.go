@@ -0,0 +1,408 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/cron"
+	"webcrawler/internal/storage"
+)
+
+// ScheduleSpec is the user-editable part of a schedule. Config is kept as
+// given; server defaults are applied each time a run is created from it.
+type ScheduleSpec struct {
+	Name     string
+	Cron     string
+	Timezone string
+	Overlap  string
+	Enabled  bool
+	Config   crawler.RunConfig
+}
+
+// Validate rejects specs the manager cannot arm.
+func (spec ScheduleSpec) Validate() error {
+	_, _, err := spec.parse()
+	return err
+}
+
+func (spec ScheduleSpec) parse() (cron.Schedule, *time.Location, error) {
+	if spec.Name == "" {
+		return cron.Schedule{}, nil, errors.New("name required")
+	}
+	switch spec.Overlap {
+	case storage.OverlapSkip, storage.OverlapQueue, storage.OverlapCancel:
+	default:
+		return cron.Schedule{}, nil, errors.New("overlap must be skip, queue or cancel")
+	}
+	sched, err := cron.Parse(spec.Cron)
+	if err == nil {
+		err = sched.Validate()
+	}
+	if err != nil {
+		return cron.Schedule{}, nil, fmt.Errorf("invalid cron: %w", err)
+	}
+	loc, err := time.LoadLocation(spec.Timezone)
+	if err != nil {
+		return cron.Schedule{}, nil, fmt.Errorf("unknown timezone %q", spec.Timezone)
+	}
+	if err := spec.Config.Validate(); err != nil {
+		return cron.Schedule{}, nil, err
+	}
+	return sched, loc, nil
+}
+
+// ScheduleState is a schedule with its next activation and the run it
+// started last, while that run is still going.
+type ScheduleState struct {
+	ID        uuid.UUID
+	Spec      ScheduleSpec
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// NextRunAt is nil for disabled schedules.
+	NextRunAt   *time.Time
+	ActiveRunID uuid.UUID
+	// Queued is set when an activation waits for the active run to stop.
+	Queued bool
+}
+
+type scheduleEntry struct {
+	state ScheduleState
+	cron  cron.Schedule
+	loc   *time.Location
+	timer *time.Timer
+	// armed counts calls to arm, so a timer replaced while it fired can tell.
+	armed int
+	// done is closed when the active run has drained.
+	done <-chan struct{}
+	// starting is set while launch creates a run outside sm.mu, so other
+	// activations treat the schedule as busy.
+	starting bool
+}
+
+// runStarter is the part of the RunManager that schedules drive.
+type runStarter interface {
+	startScheduledRun(ctx context.Context, scheduleID uuid.UUID, cfg crawler.RunConfig) (uuid.UUID, <-chan struct{}, error)
+	stopRun(ctx context.Context, id uuid.UUID, reason, actor string) error
+}
+
+// ScheduleManager starts runs from stored schedules through the RunManager.
+// Every enabled schedule has a timer armed for its next activation;
+// activations missed while the server was down are not caught up.
+//
+// sm.mu guards the entries only and is never held across a store call.
+// Edits hold editMu instead, so the store and the entries change in the
+// same order.
+type ScheduleManager struct {
+	store   storage.Store
+	runs    runStarter
+	editMu  sync.Mutex
+	mu      sync.Mutex
+	entries map[uuid.UUID]*scheduleEntry
+	closed  bool
+}
+
+func NewScheduleManager(store storage.Store, runs *RunManager) *ScheduleManager {
+	return &ScheduleManager{store: store, runs: runs, entries: make(map[uuid.UUID]*scheduleEntry)}
+}
+
+// Load arms the stored schedules. A schedule that can no longer be parsed,
+// for example because its time zone is unknown on this host, is logged and
+// left out until it is updated.
+func (sm *ScheduleManager) Load(ctx context.Context) error {
+	recs, err := sm.store.ListSchedules(ctx)
+	if err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, rec := range recs {
+		cfg, err := crawler.DecodeRunConfig(rec.Config, rec.ConfigVersion)
+		if err != nil {
+			log.Printf("schedule %s: %v", rec.ID, err)
+			continue
+		}
+		spec := ScheduleSpec{Name: rec.Name, Cron: rec.Cron, Timezone: rec.Timezone, Overlap: rec.Overlap, Enabled: rec.Enabled, Config: cfg}
+		sched, loc, err := spec.parse()
+		if err != nil {
+			log.Printf("schedule %s: %v", rec.ID, err)
+			continue
+		}
+		e := &scheduleEntry{state: ScheduleState{ID: rec.ID, Spec: spec, CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt}, cron: sched, loc: loc}
+		sm.entries[rec.ID] = e
+		sm.arm(e, time.Now())
+	}
+	return nil
+}
+
+// Close stops every timer. Runs that are already going are left alone.
+func (sm *ScheduleManager) Close() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.closed = true
+	for _, e := range sm.entries {
+		sm.arm(e, time.Now())
+	}
+}
+
+func (sm *ScheduleManager) Create(ctx context.Context, spec ScheduleSpec) (ScheduleState, error) {
+	sched, loc, err := spec.parse()
+	if err != nil {
+		return ScheduleState{}, err
+	}
+	rec, err := scheduleRecord(spec)
+	if err != nil {
+		return ScheduleState{}, err
+	}
+	rec.CreatedAt = time.Now().UTC()
+	rec.UpdatedAt = rec.CreatedAt
+	id, err := sm.store.CreateSchedule(ctx, rec)
+	if err != nil {
+		return ScheduleState{}, err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	e := &scheduleEntry{state: ScheduleState{ID: id, Spec: spec, CreatedAt: rec.CreatedAt, UpdatedAt: rec.UpdatedAt}, cron: sched, loc: loc}
+	sm.entries[id] = e
+	sm.arm(e, time.Now())
+	return e.state, nil
+}
+
+// Update replaces the schedule's spec and re-arms its timer. A run it
+// already started keeps going.
+func (sm *ScheduleManager) Update(ctx context.Context, id uuid.UUID, spec ScheduleSpec) (ScheduleState, error) {
+	sched, loc, err := spec.parse()
+	if err != nil {
+		return ScheduleState{}, err
+	}
+	rec, err := scheduleRecord(spec)
+	if err != nil {
+		return ScheduleState{}, err
+	}
+	rec.ID = id
+	rec.UpdatedAt = time.Now().UTC()
+	sm.editMu.Lock()
+	defer sm.editMu.Unlock()
+	if !sm.loaded(id) {
+		return ScheduleState{}, storage.ErrScheduleNotFound
+	}
+	if err := sm.store.UpdateSchedule(ctx, rec); err != nil {
+		return ScheduleState{}, err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	// edits are serialized, so the entry is still there
+	e := sm.entries[id]
+	e.state.Spec = spec
+	e.state.UpdatedAt = rec.UpdatedAt
+	e.cron, e.loc = sched, loc
+	if !spec.Enabled {
+		e.state.Queued = false
+	}
+	sm.arm(e, time.Now())
+	return e.state, nil
+}
+
+// Delete removes the schedule. Its runs keep their schedule id, and a run
+// it already started keeps going.
+func (sm *ScheduleManager) Delete(ctx context.Context, id uuid.UUID) error {
+	sm.editMu.Lock()
+	defer sm.editMu.Unlock()
+	if !sm.loaded(id) {
+		return storage.ErrScheduleNotFound
+	}
+	if err := sm.store.DeleteSchedule(ctx, id); err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	e := sm.entries[id]
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(sm.entries, id)
+	return nil
+}
+
+// loaded reports whether id has an entry. Schedules left out by Load are not
+// loaded.
+func (sm *ScheduleManager) loaded(id uuid.UUID) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	_, ok := sm.entries[id]
+	return ok
+}
+
+func (sm *ScheduleManager) Get(id uuid.UUID) (ScheduleState, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	e, ok := sm.entries[id]
+	if !ok {
+		return ScheduleState{}, storage.ErrScheduleNotFound
+	}
+	return e.state, nil
+}
+
+// List returns every loaded schedule, oldest first.
+func (sm *ScheduleManager) List() []ScheduleState {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	out := make([]ScheduleState, 0, len(sm.entries))
+	for _, e := range sm.entries {
+		out = append(out, e.state)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out
+}
+
+// arm replaces the entry's timer with one for the next activation after
+// now. Callers hold sm.mu.
+func (sm *ScheduleManager) arm(e *scheduleEntry, now time.Time) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.armed++
+	e.state.NextRunAt = nil
+	if sm.closed || !e.state.Spec.Enabled {
+		return
+	}
+	next := e.cron.Next(now.In(e.loc))
+	if next.IsZero() {
+		return
+	}
+	e.state.NextRunAt = &next
+	id, armed := e.state.ID, e.armed
+	e.timer = time.AfterFunc(time.Until(next), func() { sm.fire(id, armed, next) })
+}
+
+// fire handles the activation at at, unless the timer was replaced while it
+// fired. Runs are started and stopped after sm.mu is released because both
+// write to the store.
+func (sm *ScheduleManager) fire(id uuid.UUID, armed int, at time.Time) {
+	sm.mu.Lock()
+	e, ok := sm.entries[id]
+	if !ok || e.armed != armed {
+		sm.mu.Unlock()
+		return
+	}
+	sm.arm(e, maxTime(at, time.Now()))
+	if e.done == nil && !e.starting {
+		e.starting = true
+		cfg := e.state.Spec.Config
+		sm.mu.Unlock()
+		sm.launch(id, cfg)
+		return
+	}
+	var supersede uuid.UUID
+	switch e.state.Spec.Overlap {
+	case storage.OverlapSkip:
+		log.Printf("schedule %s: run %s still running, skipping activation at %s", id, e.state.ActiveRunID, at.Format(time.RFC3339))
+	case storage.OverlapQueue:
+		e.state.Queued = true
+	case storage.OverlapCancel:
+		// the next run starts once the cancelled one has drained; a run
+		// still starting is cancelled by launch
+		e.state.Queued = true
+		supersede = e.state.ActiveRunID
+	}
+	sm.mu.Unlock()
+	if supersede != uuid.Nil {
+		sm.supersede(id, supersede)
+	}
+}
+
+// launch starts a run for the schedule. The caller has set the entry's
+// starting flag and released sm.mu.
+func (sm *ScheduleManager) launch(id uuid.UUID, cfg crawler.RunConfig) {
+	runID, done, err := sm.runs.startScheduledRun(context.Background(), id, cfg)
+	sm.mu.Lock()
+	e, ok := sm.entries[id]
+	if ok {
+		e.starting = false
+	}
+	if err != nil {
+		log.Printf("schedule %s: start run: %v", id, err)
+		if ok {
+			// nothing is running for a queued activation to wait on
+			e.state.Queued = false
+		}
+		sm.mu.Unlock()
+		return
+	}
+	if !ok {
+		// deleted while starting; like its other runs, this one keeps going
+		sm.mu.Unlock()
+		return
+	}
+	e.state.ActiveRunID = runID
+	e.done = done
+	superseded := e.state.Queued && e.state.Spec.Overlap == storage.OverlapCancel
+	sm.mu.Unlock()
+	go sm.finished(id, runID, done)
+	if superseded {
+		sm.supersede(id, runID)
+	}
+}
+
+func (sm *ScheduleManager) supersede(id, runID uuid.UUID) {
+	if err := sm.runs.stopRun(context.Background(), runID, crawler.StopReasonSuperseded, "schedule "+id.String()); err != nil {
+		log.Printf("schedule %s: stop run %s: %v", id, runID, err)
+	}
+}
+
+// finished waits for a run to drain and starts the activation queued
+// behind it, if any.
+func (sm *ScheduleManager) finished(id, runID uuid.UUID, done <-chan struct{}) {
+	<-done
+	sm.mu.Lock()
+	e, ok := sm.entries[id]
+	if !ok || e.state.ActiveRunID != runID {
+		sm.mu.Unlock()
+		return
+	}
+	e.state.ActiveRunID = uuid.Nil
+	e.done = nil
+	start := e.state.Queued && e.state.Spec.Enabled && !sm.closed
+	e.state.Queued = false
+	e.starting = start
+	cfg := e.state.Spec.Config
+	sm.mu.Unlock()
+	if start {
+		sm.launch(id, cfg)
+	}
+}
+
+func scheduleRecord(spec ScheduleSpec) (storage.ScheduleRecord, error) {
+	encoded, version, err := crawler.EncodeRunConfig(spec.Config)
+	if err != nil {
+		return storage.ScheduleRecord{}, err
+	}
+	return storage.ScheduleRecord{
+		Name:          spec.Name,
+		Cron:          spec.Cron,
+		Timezone:      spec.Timezone,
+		Overlap:       spec.Overlap,
+		Enabled:       spec.Enabled,
+		Config:        encoded,
+		ConfigVersion: version,
+	}, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/storage"
+)
+
+// stubRuns starts fake runs that drain when finish is called. While gate is
+// set, starts wait until it is closed.
+type stubRuns struct {
+	mu      sync.Mutex
+	gate    chan struct{}
+	started []uuid.UUID
+	stopped []uuid.UUID
+	done    map[uuid.UUID]chan struct{}
+}
+
+func (s *stubRuns) startScheduledRun(ctx context.Context, scheduleID uuid.UUID, cfg crawler.RunConfig) (uuid.UUID, <-chan struct{}, error) {
+	s.mu.Lock()
+	gate := s.gate
+	s.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := uuid.New()
+	done := make(chan struct{})
+	s.started = append(s.started, id)
+	s.done[id] = done
+	return id, done, nil
+}
+
+func (s *stubRuns) stopRun(ctx context.Context, id uuid.UUID, reason, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = append(s.stopped, id)
+	return nil
+}
+
+func (s *stubRuns) finish(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.done[id])
+}
+
+func (s *stubRuns) counts() (started, stopped []uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uuid.UUID(nil), s.started...), append([]uuid.UUID(nil), s.stopped...)
+}
+
+func newTestSchedules(t *testing.T, overlap string) (*ScheduleManager, *stubRuns, uuid.UUID) {
+	t.Helper()
+	runs := &stubRuns{done: make(map[uuid.UUID]chan struct{})}
+	sm := &ScheduleManager{store: storage.NewMemory(), runs: runs, entries: make(map[uuid.UUID]*scheduleEntry)}
+	t.Cleanup(sm.Close)
+	// New Year's midnight never comes during a test; activations are fired
+	// by hand
+	state, err := sm.Create(context.Background(), ScheduleSpec{
+		Name:     "nightly",
+		Cron:     "0 0 1 1 *",
+		Timezone: "UTC",
+		Overlap:  overlap,
+		Enabled:  true,
+		Config:   crawler.RunConfig{SeedURL: "https://a.test/"},
+	})
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	return sm, runs, state.ID
+}
+
+// activate fires the schedule's current timer as if it had expired.
+func (sm *ScheduleManager) activate(id uuid.UUID) {
+	sm.mu.Lock()
+	armed := sm.entries[id].armed
+	sm.mu.Unlock()
+	sm.fire(id, armed, time.Now())
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduleOverlapSkip(t *testing.T) {
+	sm, runs, id := newTestSchedules(t, storage.OverlapSkip)
+	sm.activate(id)
+	sm.activate(id)
+	started, _ := runs.counts()
+	state, _ := sm.Get(id)
+	if len(started) != 1 || state.ActiveRunID != started[0] || state.Queued {
+		t.Fatalf("expected the second activation skipped, got runs %v state %+v", started, state)
+	}
+
+	runs.finish(started[0])
+	waitFor(t, "the run to finish", func() bool {
+		state, _ := sm.Get(id)
+		return state.ActiveRunID == uuid.Nil
+	})
+	if started, _ := runs.counts(); len(started) != 1 {
+		t.Fatalf("a skipped activation started a run: %v", started)
+	}
+}
+
+func TestScheduleOverlapQueue(t *testing.T) {
+	sm, runs, id := newTestSchedules(t, storage.OverlapQueue)
+	sm.activate(id)
+	sm.activate(id)
+	started, stopped := runs.counts()
+	state, _ := sm.Get(id)
+	if len(started) != 1 || len(stopped) != 0 || !state.Queued {
+		t.Fatalf("expected the second activation queued, got runs %v stopped %v state %+v", started, stopped, state)
+	}
+
+	runs.finish(started[0])
+	waitFor(t, "the queued run to start", func() bool {
+		started, _ := runs.counts()
+		return len(started) == 2
+	})
+	started, _ = runs.counts()
+	state, _ = sm.Get(id)
+	if state.Queued || state.ActiveRunID != started[1] {
+		t.Fatalf("unexpected state after the queued run started: %+v", state)
+	}
+}
+
+func TestScheduleOverlapCancel(t *testing.T) {
+	sm, runs, id := newTestSchedules(t, storage.OverlapCancel)
+	sm.activate(id)
+	sm.activate(id)
+	started, stopped := runs.counts()
+	if len(started) != 1 || len(stopped) != 1 || stopped[0] != started[0] {
+		t.Fatalf("expected the active run superseded, got runs %v stopped %v", started, stopped)
+	}
+
+	// the next run waits for the cancelled one to drain
+	runs.finish(started[0])
+	waitFor(t, "the next run to start", func() bool {
+		started, _ := runs.counts()
+		return len(started) == 2
+	})
+}
+
+func TestScheduleCancelWhileStarting(t *testing.T) {
+	sm, runs, id := newTestSchedules(t, storage.OverlapCancel)
+	runs.gate = make(chan struct{})
+	go sm.activate(id)
+	waitFor(t, "the run to start", func() bool {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		return sm.entries[id].starting
+	})
+
+	// there is no run to stop yet; launch stops it once it exists
+	sm.activate(id)
+	if _, stopped := runs.counts(); len(stopped) != 0 {
+		t.Fatalf("stopped a run before it existed: %v", stopped)
+	}
+	runs.mu.Lock()
+	close(runs.gate)
+	runs.gate = nil
+	runs.mu.Unlock()
+	waitFor(t, "the new run to be superseded", func() bool {
+		started, stopped := runs.counts()
+		return len(started) == 1 && len(stopped) == 1 && stopped[0] == started[0]
+	})
+}
+
+func TestScheduleDeletedWhileStarting(t *testing.T) {
+	sm, runs, id := newTestSchedules(t, storage.OverlapQueue)
+	runs.gate = make(chan struct{})
+	launched := make(chan struct{})
+	go func() {
+		sm.activate(id)
+		close(launched)
+	}()
+	waitFor(t, "the run to start", func() bool {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		return sm.entries[id].starting
+	})
+
+	if err := sm.Delete(context.Background(), id); err != nil {
+		t.Fatalf("delete schedule: %v", err)
+	}
+	runs.mu.Lock()
+	close(runs.gate)
+	runs.gate = nil
+	runs.mu.Unlock()
+	<-launched
+
+	// like the schedule's other runs, the one it was starting keeps going
+	started, stopped := runs.counts()
+	if len(started) != 1 || len(stopped) != 0 {
+		t.Fatalf("expected one run left going, got runs %v stopped %v", started, stopped)
+	}
+	if _, err := sm.Get(id); !errors.Is(err, storage.ErrScheduleNotFound) {
+		t.Fatalf("expected the schedule gone, got %v", err)
+	}
+	if err := sm.Delete(context.Background(), id); !errors.Is(err, storage.ErrScheduleNotFound) {
+		t.Fatalf("expected a second delete to miss, got %v", err)
+	}
+}
@@ -1,34 +1,50 @@
 package api
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"webcrawler/internal/analytics"
+	"webcrawler/internal/config"
 	"webcrawler/internal/crawler"
+	"webcrawler/internal/export"
+	"webcrawler/internal/metrics"
+	"webcrawler/internal/report"
+	"webcrawler/internal/search"
 	"webcrawler/internal/storage"
 	"webcrawler/internal/util"
 )
 
 type Server struct {
-	router     chi.Router
-	runManager *RunManager
+	router        chi.Router
+	runManager    *RunManager
+	schedules     *ScheduleManager
 	allowedOrigin string
-	storageMode string
+	storageMode   string
 }
 
-func NewServer(runManager *RunManager, allowedOrigin string, storageMode string) *Server {
+func NewServer(runManager *RunManager, schedules *ScheduleManager, allowedOrigin string, storageMode string) *Server {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 
-	s := &Server{router: r, runManager: runManager, allowedOrigin: allowedOrigin, storageMode: storageMode}
+	s := &Server{router: r, runManager: runManager, schedules: schedules, allowedOrigin: allowedOrigin, storageMode: storageMode}
 	s.routes()
 	return s
 }
@@ -36,12 +52,41 @@ func NewServer(runManager *RunManager, allowedOrigin string, storageMode string)
 func (s *Server) routes() {
 	s.router.Use(s.cors)
 
+	s.router.Get("/runs", s.handleListRuns)
 	s.router.Post("/runs", s.handleCreateRun)
 	s.router.Post("/runs/{id}/start", s.handleStartRun)
 	s.router.Post("/runs/{id}/stop", s.handleStopRun)
+	s.router.Post("/runs/{id}/pause", s.handlePauseRun)
+	s.router.Post("/runs/{id}/resume", s.handleResumeRun)
+	s.router.Patch("/runs/{id}/config", s.handleReconfigureRun)
+	s.router.Get("/runs/{id}/log", s.handleRunLog)
 	s.router.Get("/runs/{id}", s.handleGetRun)
 	s.router.Get("/runs/{id}/pages", s.handleListPages)
 	s.router.Get("/runs/{id}/events", s.handleEvents)
+	s.router.Get("/runs/{id}/search", s.handleSearch)
+	s.router.Get("/runs/{id}/links/in", s.handleInlinks)
+	s.router.Get("/runs/{id}/links/out", s.handleOutlinks)
+	s.router.Get("/runs/{id}/reports/broken-links", s.handleBrokenLinks)
+	s.router.Get("/runs/{id}/link-status", s.handleLinkStatus)
+	s.router.Get("/runs/{id}/reports/redirects", s.handleRedirectChains)
+	s.router.Get("/runs/{id}/reports/security", s.handleSecurity)
+	s.router.Get("/runs/{id}/skipped", s.handleSkipped)
+	s.router.Get("/runs/{id}/errors", s.handleErrors)
+	s.router.Get("/runs/{id}/hosts", s.handleHosts)
+	s.router.Get("/runs/{id}/hosts/{host}/timeseries", s.handleHostTimeseries)
+	s.router.Get("/runs/{id}/edges", s.handleEdges)
+	s.router.Get("/runs/{id}/export", s.handleExport)
+	s.router.Get("/runs/{id}/graph", s.handleGraph)
+	s.router.Get("/runs/{id}/explain", s.handleExplain)
+	s.router.Get("/runs/{id}/analytics", s.handleAnalytics)
+	s.router.Get("/runs/{id}/diff/{other}", s.handleDiff)
+	s.router.Post("/runs/{id}/analytics", s.handleRecomputeAnalytics)
+
+	s.router.Get("/schedules", s.handleListSchedules)
+	s.router.Post("/schedules", s.handleCreateSchedule)
+	s.router.Get("/schedules/{id}", s.handleGetSchedule)
+	s.router.Put("/schedules/{id}", s.handleUpdateSchedule)
+	s.router.Delete("/schedules/{id}", s.handleDeleteSchedule)
 
 	s.router.Handle("/metrics", promhttp.Handler())
 	// pprof via DefaultServeMux
@@ -55,8 +100,8 @@ func (s *Server) Router() http.Handler {
 func (s *Server) cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", s.allowedOrigin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -66,15 +111,117 @@ func (s *Server) cors(next http.Handler) http.Handler {
 }
 
 type createRunRequest struct {
-	SeedURL            string `json:"seed_url"`
-	MaxDepth           int    `json:"max_depth"`
-	MaxPages           int    `json:"max_pages"`
-	TimeBudgetSeconds  int    `json:"time_budget_seconds"`
-	MaxLinksPerPage    int    `json:"max_links_per_page"`
-	GlobalConcurrency  int    `json:"global_concurrency"`
-	PerHostConcurrency int    `json:"per_host_concurrency"`
-	UserAgent          string `json:"user_agent"`
-	RespectRobots      *bool  `json:"respect_robots"`
+	SeedURL                    string `json:"seed_url"`
+	MaxDepth                   int    `json:"max_depth"`
+	MaxPages                   int    `json:"max_pages"`
+	TimeBudgetSeconds          int    `json:"time_budget_seconds"`
+	MaxLinksPerPage            int    `json:"max_links_per_page"`
+	GlobalConcurrency          int    `json:"global_concurrency"`
+	PerHostConcurrency         int    `json:"per_host_concurrency"`
+	UserAgent                  string `json:"user_agent"`
+	RespectRobots              *bool  `json:"respect_robots"`
+	RequestTimeoutMS           int64  `json:"request_timeout_ms"`
+	HeaderTimeoutMS            int64  `json:"header_timeout_ms"`
+	TLSHandshakeTimeoutMS      int64  `json:"tls_handshake_timeout_ms"`
+	IdleConnTimeoutMS          int64  `json:"idle_conn_timeout_ms"`
+	MaxBodyBytes               int64  `json:"max_body_bytes"`
+	RobotsTTLMS                int64  `json:"robots_ttl_ms"`
+	RetryMax                   int    `json:"retry_max"`
+	RetryBaseDelayMS           int64  `json:"retry_base_delay_ms"`
+	CircuitTripCount           int    `json:"circuit_trip_count"`
+	CircuitResetMS             int64  `json:"circuit_reset_ms"`
+	ExtractText                bool   `json:"extract_text"`
+	Mode                       string `json:"mode"`
+	Scope                      string `json:"scope"`
+	ExternalConcurrency        int    `json:"external_concurrency"`
+	ExternalPerHostConcurrency int    `json:"external_per_host_concurrency"`
+	MaxRedirects               int    `json:"max_redirects"`
+	LosslessWrites             *bool  `json:"lossless_writes"`
+	WriteBatchSize             int    `json:"write_batch_size"`
+	WriteFlushMS               int64  `json:"write_flush_ms"`
+
+	HostConcurrency map[string]int `json:"host_concurrency,omitempty"`
+}
+
+func (req createRunRequest) config(defaults config.CrawlerDefaults) crawler.RunConfig {
+	cfg := crawler.RunConfig{
+		SeedURL:                    req.SeedURL,
+		MaxDepth:                   req.MaxDepth,
+		MaxPages:                   req.MaxPages,
+		TimeBudgetSeconds:          req.TimeBudgetSeconds,
+		MaxLinksPerPage:            req.MaxLinksPerPage,
+		GlobalConcurrency:          req.GlobalConcurrency,
+		PerHostConcurrency:         req.PerHostConcurrency,
+		UserAgent:                  req.UserAgent,
+		RespectRobots:              defaults.RespectRobots,
+		RequestTimeout:             msDuration(req.RequestTimeoutMS),
+		HeaderTimeout:              msDuration(req.HeaderTimeoutMS),
+		TLSHandshakeTimeout:        msDuration(req.TLSHandshakeTimeoutMS),
+		IdleConnTimeout:            msDuration(req.IdleConnTimeoutMS),
+		MaxBodyBytes:               req.MaxBodyBytes,
+		RobotsTTL:                  msDuration(req.RobotsTTLMS),
+		RetryMax:                   req.RetryMax,
+		RetryBaseDelay:             msDuration(req.RetryBaseDelayMS),
+		CircuitTripCount:           req.CircuitTripCount,
+		CircuitResetTime:           msDuration(req.CircuitResetMS),
+		ExtractText:                req.ExtractText,
+		Mode:                       req.Mode,
+		Scope:                      req.Scope,
+		ExternalConcurrency:        req.ExternalConcurrency,
+		ExternalPerHostConcurrency: req.ExternalPerHostConcurrency,
+		MaxRedirects:               req.MaxRedirects,
+		LosslessWrites:             defaults.LosslessWrites,
+		WriteBatchSize:             req.WriteBatchSize,
+		WriteFlushInterval:         msDuration(req.WriteFlushMS),
+		HostConcurrency:            req.HostConcurrency,
+	}
+	if req.RespectRobots != nil {
+		cfg.RespectRobots = *req.RespectRobots
+	}
+	if req.LosslessWrites != nil {
+		cfg.LosslessWrites = *req.LosslessWrites
+	}
+	return cfg
+}
+
+// runConfigPayload renders a config in the request shape, so a run's config
+// can be posted back unchanged to repeat it.
+func runConfigPayload(cfg crawler.RunConfig) createRunRequest {
+	return createRunRequest{
+		SeedURL:                    cfg.SeedURL,
+		MaxDepth:                   cfg.MaxDepth,
+		MaxPages:                   cfg.MaxPages,
+		TimeBudgetSeconds:          int(cfg.TimeBudget.Seconds()),
+		MaxLinksPerPage:            cfg.MaxLinksPerPage,
+		GlobalConcurrency:          cfg.GlobalConcurrency,
+		PerHostConcurrency:         cfg.PerHostConcurrency,
+		UserAgent:                  cfg.UserAgent,
+		RespectRobots:              &cfg.RespectRobots,
+		RequestTimeoutMS:           cfg.RequestTimeout.Milliseconds(),
+		HeaderTimeoutMS:            cfg.HeaderTimeout.Milliseconds(),
+		TLSHandshakeTimeoutMS:      cfg.TLSHandshakeTimeout.Milliseconds(),
+		IdleConnTimeoutMS:          cfg.IdleConnTimeout.Milliseconds(),
+		MaxBodyBytes:               cfg.MaxBodyBytes,
+		RobotsTTLMS:                cfg.RobotsTTL.Milliseconds(),
+		RetryMax:                   cfg.RetryMax,
+		RetryBaseDelayMS:           cfg.RetryBaseDelay.Milliseconds(),
+		CircuitTripCount:           cfg.CircuitTripCount,
+		CircuitResetMS:             cfg.CircuitResetTime.Milliseconds(),
+		ExtractText:                cfg.ExtractText,
+		Mode:                       cfg.Mode,
+		Scope:                      cfg.Scope,
+		ExternalConcurrency:        cfg.ExternalConcurrency,
+		ExternalPerHostConcurrency: cfg.ExternalPerHostConcurrency,
+		MaxRedirects:               cfg.MaxRedirects,
+		LosslessWrites:             &cfg.LosslessWrites,
+		WriteBatchSize:             cfg.WriteBatchSize,
+		WriteFlushMS:               cfg.WriteFlushInterval.Milliseconds(),
+		HostConcurrency:            cfg.HostConcurrency,
+	}
+}
+
+func msDuration(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
 }
 
 func (s *Server) handleCreateRun(w http.ResponseWriter, r *http.Request) {
@@ -83,36 +230,135 @@ func (s *Server) handleCreateRun(w http.ResponseWriter, r *http.Request) {
 		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if req.SeedURL == "" {
-		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "seed_url required"})
+	cfg := req.config(s.runManager.defaults)
+	if err := cfg.Validate(); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if _, _, err := crawler.Canonicalize(req.SeedURL); err != nil {
-		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid seed_url"})
+
+	id, err := s.runManager.CreateRun(r.Context(), cfg)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	cfg := crawler.RunConfig{
-		SeedURL:            req.SeedURL,
-		MaxDepth:           req.MaxDepth,
-		MaxPages:           req.MaxPages,
-		TimeBudgetSeconds:  req.TimeBudgetSeconds,
-		MaxLinksPerPage:    req.MaxLinksPerPage,
-		GlobalConcurrency:  req.GlobalConcurrency,
-		PerHostConcurrency: req.PerHostConcurrency,
-		UserAgent:          req.UserAgent,
+	util.WriteJSON(w, http.StatusOK, map[string]any{"id": id.String(), "status": "created", "created_at": time.Now().UTC()})
+}
+
+type runCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d"`
+	CreatedAt time.Time `json:"t"`
+	Key       string    `json:"k,omitempty"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := storage.RunQuery{
+		SeedHost:   strings.ToLower(query.Get("seed_host")),
+		StopReason: query.Get("stop_reason"),
+		Sort:       storage.RunSortCreated,
+		Desc:       true,
+		Limit:      50,
 	}
-	if req.RespectRobots != nil {
-		cfg.RespectRobots = *req.RespectRobots
-	} else {
-		cfg.RespectRobots = s.runManager.defaults.RespectRobots
+	if raw := query.Get("status"); raw != "" {
+		q.Statuses = strings.Split(raw, ",")
+	}
+	if raw := query.Get("schedule_id"); raw != "" {
+		scheduleID, err := uuid.Parse(raw)
+		if err != nil {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid schedule_id"})
+			return
+		}
+		q.ScheduleID = scheduleID
+	}
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"created_after", &q.CreatedAfter}, {"created_before", &q.CreatedBefore}} {
+		raw := query.Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": bound.name + " must be an RFC 3339 timestamp"})
+			return
+		}
+		*bound.dst = &t
+	}
+	switch sort := query.Get("sort"); sort {
+	case "", storage.RunSortCreated:
+	case storage.RunSortSeedURL, storage.RunSortStatus:
+		q.Sort = sort
+		q.Desc = false
+	default:
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "sort must be created_at, seed_url or status"})
+		return
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "order must be asc or desc"})
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 500 {
+			q.Limit = parsed
+		}
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		var cur runCursor
+		if err := decodeCursor(raw, &cur); err != nil || cur.Sort != q.Sort || cur.Desc != q.Desc {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid cursor for this sort"})
+			return
+		}
+		q.After = &storage.RunCursor{CreatedAt: cur.CreatedAt, Key: cur.Key, ID: cur.ID}
 	}
 
-	id, err := s.runManager.CreateRun(r.Context(), cfg)
+	listings, err := s.runManager.ListRuns(r.Context(), q)
 	if err != nil {
 		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	util.WriteJSON(w, http.StatusOK, map[string]any{"id": id.String(), "status": "created", "created_at": time.Now().UTC()})
+	items := make([]map[string]any, 0, len(listings))
+	for _, listing := range listings {
+		state := listing.State
+		item := map[string]any{
+			"id":          state.ID.String(),
+			"seed_url":    state.Config.SeedURL,
+			"mode":        state.Config.Mode,
+			"status":      state.Status,
+			"created_at":  state.CreatedAt,
+			"started_at":  state.StartedAt,
+			"stopped_at":  state.StoppedAt,
+			"stop_reason": state.StopReason,
+			"schedule_id": optionalID(state.ScheduleID),
+			"live":        listing.Live,
+			"summary": map[string]any{
+				"pages_fetched":   listing.Summary.PagesFetched,
+				"pages_failed":    listing.Summary.PagesFailed,
+				"unique_hosts":    listing.Summary.UniqueHosts,
+				"total_bytes":     listing.Summary.TotalBytes,
+				"last_fetched_at": listing.Summary.LastFetchedAt,
+			},
+		}
+		if state.Engine != nil {
+			item["stats"] = map[string]any{"pages_fetched": state.Engine.PagesFetched()}
+		}
+		items = append(items, item)
+	}
+	var next *string
+	if len(listings) == q.Limit {
+		cur := listings[len(listings)-1].Cursor
+		encoded := encodeCursor(runCursor{Sort: q.Sort, Desc: q.Desc, CreatedAt: cur.CreatedAt, Key: cur.Key, ID: cur.ID})
+		next = &encoded
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items, "next_cursor": next})
 }
 
 func (s *Server) handleStartRun(w http.ResponseWriter, r *http.Request) {
@@ -134,13 +380,141 @@ func (s *Server) handleStopRun(w http.ResponseWriter, r *http.Request) {
 		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		return
 	}
-	if err := s.runManager.StopRun(r.Context(), id); err != nil {
+	if err := s.runManager.StopRun(r.Context(), id, actor(r)); err != nil {
 		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
 }
 
+func (s *Server) handlePauseRun(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	if err := s.runManager.PauseRun(r.Context(), id, actor(r)); err != nil {
+		util.WriteJSON(w, runControlStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "paused"})
+}
+
+func (s *Server) handleResumeRun(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	if err := s.runManager.ResumeRun(r.Context(), id, actor(r)); err != nil {
+		util.WriteJSON(w, runControlStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "running"})
+}
+
+// configPatchRequest holds the settings PATCH /runs/{id}/config can change;
+// omitted fields are left as they are.
+type configPatchRequest struct {
+	GlobalConcurrency  *int           `json:"global_concurrency"`
+	PerHostConcurrency *int           `json:"per_host_concurrency"`
+	HostConcurrency    map[string]int `json:"host_concurrency"`
+	TimeBudgetSeconds  *int           `json:"time_budget_seconds"`
+	MaxPages           *int           `json:"max_pages"`
+}
+
+func (req configPatchRequest) patch() crawler.ConfigPatch {
+	p := crawler.ConfigPatch{
+		GlobalConcurrency:  req.GlobalConcurrency,
+		PerHostConcurrency: req.PerHostConcurrency,
+		HostConcurrency:    req.HostConcurrency,
+		MaxPages:           req.MaxPages,
+	}
+	if req.TimeBudgetSeconds != nil {
+		budget := time.Duration(*req.TimeBudgetSeconds) * time.Second
+		p.TimeBudget = &budget
+	}
+	return p
+}
+
+func (s *Server) handleReconfigureRun(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req configPatchRequest
+	if err := util.DecodeJSON(r, &req); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	patch := req.patch()
+	if err := patch.Validate(); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	cfg, err := s.runManager.ReconfigureRun(r.Context(), id, patch, actor(r))
+	if err != nil {
+		util.WriteJSON(w, runControlStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"config": runConfigPayload(cfg)})
+}
+
+func (s *Server) handleRunLog(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	limit := 500
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	events, err := s.runManager.RunEvents(r.Context(), id, limit)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if events == nil {
+		events = []storage.RunEvent{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"events": events})
+}
+
+// actor names who made a change to a run: the X-Actor header, or the client
+// address when it is absent. Requests are not authenticated, so it is only
+// as trustworthy as the network in front of the server.
+func actor(r *http.Request) string {
+	if name := strings.TrimSpace(r.Header.Get("X-Actor")); name != "" {
+		if len(name) > 200 {
+			n := 200
+			for n > 0 && !utf8.RuneStart(name[n]) {
+				n--
+			}
+			name = name[:n]
+		}
+		return name
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func runControlStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRunNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRunNotRunning), errors.Is(err, ErrRunNotPaused), errors.Is(err, ErrRunStopped):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -158,8 +532,8 @@ func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
 		stats["pages_fetched"] = state.Engine.PagesFetched()
 	}
 	stopReason := state.StopReason
-	if stopReason == "" && state.Status == "running" {
-		stopReason = "running"
+	if stopReason == "" && (state.Status == "running" || state.Status == "paused") {
+		stopReason = state.Status
 	}
 	summary, summaryErr := s.runManager.Summary(r.Context(), id)
 	if summaryErr != nil {
@@ -167,48 +541,863 @@ func (s *Server) handleGetRun(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := map[string]any{
-		"id": state.ID.String(),
-		"status": state.Status,
-		"created_at": state.CreatedAt,
-		"started_at": state.StartedAt,
-		"stopped_at": state.StoppedAt,
+		"id":           state.ID.String(),
+		"status":       state.Status,
+		"created_at":   state.CreatedAt,
+		"started_at":   state.StartedAt,
+		"stopped_at":   state.StoppedAt,
 		"storage_mode": s.storageMode,
-		"stop_reason": stopReason,
+		"stop_reason":  stopReason,
+		"schedule_id":  optionalID(state.ScheduleID),
 		"limits": map[string]any{
-			"max_depth": state.Config.MaxDepth,
-			"max_pages": state.Config.MaxPages,
+			"max_depth":           state.Config.MaxDepth,
+			"max_pages":           state.Config.MaxPages,
 			"time_budget_seconds": int(state.Config.TimeBudget.Seconds()),
 		},
+		"config": runConfigPayload(state.Config),
 		"summary": map[string]any{
-			"pages_fetched":  summary.PagesFetched,
-			"pages_failed":   summary.PagesFailed,
-			"unique_hosts":   summary.UniqueHosts,
-			"total_bytes":    summary.TotalBytes,
+			"pages_fetched":   summary.PagesFetched,
+			"pages_failed":    summary.PagesFailed,
+			"unique_hosts":    summary.UniqueHosts,
+			"total_bytes":     summary.TotalBytes,
 			"last_fetched_at": summary.LastFetchedAt,
+			"skipped":         skippedCounts(summary.Skipped),
 		},
 		"stats": stats,
 	}
 	util.WriteJSON(w, http.StatusOK, payload)
 }
 
+type pageCursor struct {
+	Sort string    `json:"s"`
+	Desc bool      `json:"d"`
+	Time time.Time `json:"t"`
+	Num  int64     `json:"n,omitempty"`
+	Text string    `json:"k,omitempty"`
+	ID   int64     `json:"id"`
+}
+
+// parsePageQuery reads the page filters and sort shared by the pages listing
+// and the pages export.
+func parsePageQuery(query url.Values) (storage.PageQuery, error) {
+	q := storage.PageQuery{
+		Host:        strings.ToLower(query.Get("host")),
+		ErrorClass:  query.Get("error_class"),
+		ContentType: strings.ToLower(query.Get("content_type")),
+		URLContains: query.Get("url_contains"),
+		URLPrefix:   query.Get("url_prefix"),
+		Sort:        storage.PageSortFetched,
+		Desc:        true,
+	}
+	ints := map[string]*int64{}
+	for _, name := range []string{"status", "status_min", "status_max", "depth_min", "depth_max", "size_min", "size_max", "fetch_ms_min", "fetch_ms_max"} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			return storage.PageQuery{}, errors.New(name + " must be a non-negative integer")
+		}
+		ints[name] = &n
+	}
+	if n := ints["status"]; n != nil {
+		q.StatusMin, q.StatusMax = int(*n), int(*n)
+	}
+	if n := ints["status_min"]; n != nil {
+		q.StatusMin = int(*n)
+	}
+	if n := ints["status_max"]; n != nil {
+		q.StatusMax = int(*n)
+	}
+	if n := ints["depth_min"]; n != nil {
+		depth := int(*n)
+		q.DepthMin = &depth
+	}
+	if n := ints["depth_max"]; n != nil {
+		depth := int(*n)
+		q.DepthMax = &depth
+	}
+	q.SizeMin, q.SizeMax = ints["size_min"], ints["size_max"]
+	q.FetchMSMin, q.FetchMSMax = ints["fetch_ms_min"], ints["fetch_ms_max"]
+	if sort := query.Get("sort"); sort != "" {
+		if !storage.ValidPageSort(sort) {
+			return storage.PageQuery{}, errors.New("sort must be one of fetched_at, url, host, status_code, depth, size_bytes, fetch_ms, content_type")
+		}
+		q.Sort = sort
+		q.Desc = sort == storage.PageSortFetched
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return storage.PageQuery{}, errors.New("order must be asc or desc")
+	}
+	return q, nil
+}
+
 func (s *Server) handleListPages(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		return
 	}
-	limit := 50
+	query := r.URL.Query()
+	q, err := parsePageQuery(query)
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	q.Limit = 50
+	if raw := query.Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			q.Limit = parsed
+		}
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		var cur pageCursor
+		if err := decodeCursor(raw, &cur); err != nil || cur.Sort != q.Sort || cur.Desc != q.Desc {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid cursor for this sort"})
+			return
+		}
+		q.After = &storage.PageCursor{Time: cur.Time, Num: cur.Num, Text: cur.Text, ID: cur.ID}
+	}
+
+	pages, err := s.runManager.QueryPages(r.Context(), id, q)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if pages == nil {
+		pages = []storage.PageRow{}
+	}
+	var next *string
+	if len(pages) == q.Limit {
+		cur := storage.PageCursorFor(pages[len(pages)-1], q.Sort)
+		encoded := encodeCursor(pageCursor{Sort: q.Sort, Desc: q.Desc, Time: cur.Time, Num: cur.Num, Text: cur.Text, ID: cur.ID})
+		next = &encoded
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": pages, "next_cursor": next})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "q required"})
+		return
+	}
+	limit := 20
 	if raw := r.URL.Query().Get("limit"); raw != "" {
-		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 500 {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+	hits, total, err := s.runManager.Search(r.Context(), id, query, limit)
+	if errors.Is(err, search.ErrNoIndex) {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "search index not available for run"})
+		return
+	}
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if hits == nil {
+		hits = []search.Hit{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"query": query, "total": total, "items": hits})
+}
+
+func (s *Server) handleInlinks(w http.ResponseWriter, r *http.Request) {
+	s.serveLinks(w, r, s.runManager.Inlinks)
+}
+
+func (s *Server) handleOutlinks(w http.ResponseWriter, r *http.Request) {
+	s.serveLinks(w, r, s.runManager.Outlinks)
+}
+
+func (s *Server) serveLinks(w http.ResponseWriter, r *http.Request, list func(context.Context, uuid.UUID, string, int) ([]storage.LinkRow, error)) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	canonical, _, err := crawler.Canonicalize(r.URL.Query().Get("url"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid url"})
+		return
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	links, err := list(r.Context(), id, canonical, limit)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if links == nil {
+		links = []storage.LinkRow{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"url": canonical, "items": links})
+}
+
+func (s *Server) handleBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json or csv"})
+		return
+	}
+	state, err := s.runManager.GetRun(r.Context(), id)
+	if err != nil {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	result, err := s.runManager.BrokenLinks(r.Context(), id, state.Config)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="broken-links-`+id.String()+`.csv"`)
+		if err := report.WriteBrokenCSV(w, result); err != nil {
+			log.Printf("write broken links csv: %v", err)
+		}
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleLinkStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	failedOnly, _ := strconv.ParseBool(r.URL.Query().Get("failed"))
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	items, err := s.runManager.LinkStatuses(r.Context(), id, failedOnly, limit)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		items = []storage.LinkStatusRow{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleRedirectChains(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	minHops := 2
+	if raw := r.URL.Query().Get("min_hops"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "min_hops must be a positive integer"})
+			return
+		}
+		minHops = parsed
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
 			limit = parsed
 		}
 	}
-	pages, err := s.runManager.ListPages(r.Context(), id, limit)
+	items, err := s.runManager.RedirectChains(r.Context(), id, minHops, limit)
 	if err != nil {
 		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	util.WriteJSON(w, http.StatusOK, map[string]any{"items": pages})
+	if items == nil {
+		items = []storage.RedirectChainRow{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"min_hops": minHops, "items": items})
+}
+
+func (s *Server) handleSecurity(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	days := 30
+	if raw := r.URL.Query().Get("expiring_within_days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 || parsed > 3650 {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "expiring_within_days must be between 0 and 3650"})
+			return
+		}
+		days = parsed
+	}
+	flaggedOnly, _ := strconv.ParseBool(r.URL.Query().Get("flagged"))
+	result, err := s.runManager.Security(r.Context(), id, days, flaggedOnly)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+var skipReasons = map[string]bool{
+	crawler.SkipRobotsDenied: true,
+	crawler.SkipMaxDepth:     true,
+	crawler.SkipFrontierFull: true,
+	crawler.SkipParseDropped: true,
+	crawler.SkipOutOfScope:   true,
+	crawler.SkipCircuitOpen:  true,
+}
+
+func (s *Server) handleSkipped(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	if reason != "" && !skipReasons[reason] {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown reason"})
+		return
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	items, err := s.runManager.Skipped(r.Context(), id, reason, limit)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		items = []storage.SkipRecord{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleErrors(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	q := r.URL.Query()
+	query := storage.ErrorGroupQuery{
+		Class:   q.Get("class"),
+		Host:    strings.ToLower(q.Get("host")),
+		Samples: 3,
+		Limit:   100,
+	}
+	if raw := q.Get("samples"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 || parsed > 20 {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "samples must be between 0 and 20"})
+			return
+		}
+		query.Samples = parsed
+	}
+	if raw := q.Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			query.Limit = parsed
+		}
+	}
+	items, err := s.runManager.ErrorGroups(r.Context(), id, query)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		items = []storage.ErrorGroup{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	limit := 100
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	result, err := s.runManager.Hosts(r.Context(), id, limit)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleHostTimeseries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	q := storage.HostStatQuery{Host: strings.ToLower(chi.URLParam(r, "host"))}
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		raw := r.URL.Query().Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": bound.name + " must be an RFC 3339 timestamp"})
+			return
+		}
+		*bound.dst = &t
+	}
+	items, err := s.runManager.HostTimeseries(r.Context(), id, q)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		items = []storage.HostStatRecord{}
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"host":           q.Host,
+		"bucket_seconds": int(metrics.HostStatBucket / time.Second),
+		"items":          items,
+	})
+}
+
+type edgeCursor struct {
+	Count int    `json:"c"`
+	Src   string `json:"s"`
+	Dst   string `json:"d"`
+}
+
+func (s *Server) handleEdges(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	q := storage.EdgeQuery{Host: strings.ToLower(r.URL.Query().Get("host")), Limit: 1000}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 10000 {
+			q.Limit = parsed
+		}
+	}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		var cur edgeCursor
+		if err := decodeCursor(raw, &cur); err != nil {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		q.After = &storage.EdgeCursor{Count: cur.Count, Src: cur.Src, Dst: cur.Dst}
+	}
+	items, err := s.runManager.Edges(r.Context(), id, q)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		items = []storage.EdgeRecord{}
+	}
+	var next *string
+	if len(items) == q.Limit {
+		last := items[len(items)-1]
+		encoded := encodeCursor(edgeCursor{Count: last.Count, Src: last.Src, Dst: last.Dst})
+		next = &encoded
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items, "next_cursor": next})
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	query := r.URL.Query()
+	opts := export.Options{
+		Dataset:     query.Get("dataset"),
+		Format:      query.Get("format"),
+		Compression: query.Get("compression"),
+	}
+	if opts.Compression == "none" {
+		opts.Compression = export.CompressionNone
+	}
+	switch {
+	case opts.Dataset == "items":
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "dataset items is not available: runs do not extract items"})
+		return
+	case !export.ValidDataset(opts.Dataset):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "dataset must be pages, errors or edges"})
+		return
+	case !export.ValidFormat(opts.Format):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be csv, jsonl or parquet"})
+		return
+	case !export.ValidCompression(opts.Compression):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "compression must be none, gzip or zstd"})
+		return
+	}
+	host := strings.ToLower(query.Get("host"))
+	switch opts.Dataset {
+	case export.DatasetPages:
+		if opts.Pages, err = parsePageQuery(query); err != nil {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	case export.DatasetErrors:
+		opts.Errors = storage.ErrorQuery{Class: query.Get("class"), Host: host}
+	case export.DatasetEdges:
+		opts.Edges = storage.EdgeQuery{Host: host}
+	}
+	if _, err := s.runManager.GetRun(r.Context(), id); err != nil {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(opts.Format, opts.Compression))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.FileName(id, opts.Dataset, opts.Format, opts.Compression)+`"`)
+	if n, err := s.runManager.Export(r.Context(), w, id, opts); err != nil {
+		// the status line is already sent; the client sees a truncated file
+		log.Printf("export %s %s of run %s after %d rows: %v", opts.Dataset, opts.Format, id, n, err)
+	}
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	query := r.URL.Query()
+	opts := export.GraphOptions{
+		Level:       query.Get("level"),
+		Format:      query.Get("format"),
+		Compression: query.Get("compression"),
+		Domain:      query.Get("domain"),
+	}
+	if opts.Level == "" {
+		opts.Level = export.GraphLevelHost
+	}
+	if opts.Compression == "none" {
+		opts.Compression = export.CompressionNone
+	}
+	switch {
+	case !export.ValidGraphLevel(opts.Level):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "level must be host or url"})
+		return
+	case !export.ValidGraphFormat(opts.Format):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be graphml, gexf, dot or neo4j"})
+		return
+	case !export.ValidCompression(opts.Compression):
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "compression must be none, gzip or zstd"})
+		return
+	}
+	if raw := query.Get("min_weight"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "min_weight must be a non-negative integer"})
+			return
+		}
+		opts.MinWeight = parsed
+	}
+	if _, err := s.runManager.GetRun(r.Context(), id); err != nil {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", export.GraphContentType(opts.Format, opts.Compression))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.GraphFileName(id, opts.Level, opts.Format, opts.Compression)+`"`)
+	if stats, err := s.runManager.ExportGraph(r.Context(), w, id, opts); err != nil {
+		log.Printf("graph export %s %s of run %s after %d nodes, %d edges: %v", opts.Level, opts.Format, id, stats.Nodes, stats.Edges, err)
+	}
+}
+
+func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	canonical, _, err := crawler.Canonicalize(r.URL.Query().Get("url"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid url"})
+		return
+	}
+	explanation, err := s.runManager.Explain(r.Context(), id, canonical)
+	if errors.Is(err, report.ErrURLNotSeen) {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, explanation)
+}
+
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	result, err := s.runManager.Analytics(r.Context(), id)
+	if errors.Is(err, analytics.ErrNotComputed) {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleRecomputeAnalytics(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	state, err := s.runManager.GetRun(r.Context(), id)
+	if err != nil {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if state.Status == "running" || state.Status == "paused" {
+		util.WriteJSON(w, http.StatusConflict, map[string]string{"error": "run is still running; analytics are computed when it stops"})
+		return
+	}
+	result, err := s.runManager.RecomputeAnalytics(r.Context(), id)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	a, errA := uuid.Parse(chi.URLParam(r, "id"))
+	b, errB := uuid.Parse(chi.URLParam(r, "other"))
+	if errA != nil || errB != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var only map[string]bool
+	if raw := r.URL.Query().Get("category"); raw != "" {
+		only = map[string]bool{}
+		for _, category := range strings.Split(raw, ",") {
+			if !slices.Contains(report.DiffCategories, category) {
+				util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown category " + category})
+				return
+			}
+			only[category] = true
+		}
+	}
+	for _, id := range []uuid.UUID{a, b} {
+		if _, err := s.runManager.GetRun(r.Context(), id); err != nil {
+			util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "run " + id.String() + " not found"})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	summary, err := s.runManager.Diff(r.Context(), a, b, func(c report.DiffChange) error {
+		if only != nil && !only[c.Category] {
+			return nil
+		}
+		return enc.Encode(c)
+	})
+	if err != nil {
+		// without the summary line the client can tell the diff is incomplete
+		log.Printf("diff of runs %s and %s: %v", a, b, err)
+		_ = out.Flush()
+		return
+	}
+	_ = enc.Encode(map[string]any{"category": "summary", "summary": summary})
+	_ = out.Flush()
+}
+
+type scheduleRequest struct {
+	Name     string           `json:"name"`
+	Cron     string           `json:"cron"`
+	Timezone string           `json:"timezone"`
+	Overlap  string           `json:"overlap"`
+	Enabled  *bool            `json:"enabled"`
+	Config   createRunRequest `json:"config"`
+}
+
+func (req scheduleRequest) spec(defaults config.CrawlerDefaults) ScheduleSpec {
+	spec := ScheduleSpec{
+		Name:     req.Name,
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Overlap:  req.Overlap,
+		Enabled:  true,
+		Config:   req.Config.config(defaults).Normalize(),
+	}
+	if spec.Timezone == "" {
+		spec.Timezone = "UTC"
+	}
+	if spec.Overlap == "" {
+		spec.Overlap = storage.OverlapSkip
+	}
+	if req.Enabled != nil {
+		spec.Enabled = *req.Enabled
+	}
+	return spec
+}
+
+func schedulePayload(state ScheduleState) map[string]any {
+	return map[string]any{
+		"id":            state.ID.String(),
+		"name":          state.Spec.Name,
+		"cron":          state.Spec.Cron,
+		"timezone":      state.Spec.Timezone,
+		"overlap":       state.Spec.Overlap,
+		"enabled":       state.Spec.Enabled,
+		"config":        runConfigPayload(state.Spec.Config),
+		"created_at":    state.CreatedAt,
+		"updated_at":    state.UpdatedAt,
+		"next_run_at":   state.NextRunAt,
+		"active_run_id": optionalID(state.ActiveRunID),
+		"queued":        state.Queued,
+	}
+}
+
+func optionalID(id uuid.UUID) *string {
+	if id == uuid.Nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	states := s.schedules.List()
+	items := make([]map[string]any, 0, len(states))
+	for _, state := range states {
+		items = append(items, schedulePayload(state))
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := util.DecodeJSON(r, &req); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	spec := req.spec(s.runManager.defaults)
+	if err := spec.Validate(); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	state, err := s.schedules.Create(r.Context(), spec)
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, schedulePayload(state))
+}
+
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	state, err := s.schedules.Get(id)
+	if err != nil {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, schedulePayload(state))
+}
+
+func (s *Server) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req scheduleRequest
+	if err := util.DecodeJSON(r, &req); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	spec := req.spec(s.runManager.defaults)
+	if err := spec.Validate(); err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	state, err := s.schedules.Update(r.Context(), id, spec)
+	if errors.Is(err, storage.ErrScheduleNotFound) {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, schedulePayload(state))
+}
+
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	err = s.schedules.Delete(r.Context(), id)
+	if errors.Is(err, storage.ErrScheduleNotFound) {
+		util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		util.WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func skippedCounts(counts map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(skipReasons))
+	for reason := range skipReasons {
+		out[reason] = counts[reason]
+	}
+	return out
 }
 
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
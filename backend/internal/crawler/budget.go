@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"sync"
+	"time"
+)
+
+// budgetTimer calls fire once the engine has been running for the time
+// budget. Time spent paused does not count.
+type budgetTimer struct {
+	mu     sync.Mutex
+	budget time.Duration
+	fire   func()
+	// used is the running time before the current stretch, which began at
+	// since; since is zero while paused.
+	used    time.Duration
+	since   time.Time
+	timer   *time.Timer
+	stopped bool
+}
+
+func newBudgetTimer(budget time.Duration, fire func()) *budgetTimer {
+	return &budgetTimer{budget: budget, fire: fire}
+}
+
+func (b *budgetTimer) start(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.since = now
+	b.arm(now)
+}
+
+func (b *budgetTimer) pause(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.since.IsZero() {
+		return
+	}
+	b.used += now.Sub(b.since)
+	b.since = time.Time{}
+	b.disarm()
+}
+
+func (b *budgetTimer) resume(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.since.IsZero() {
+		return
+	}
+	b.since = now
+	b.arm(now)
+}
+
+// setBudget replaces the budget, counting the time already used against it.
+// Zero removes the budget.
+func (b *budgetTimer) setBudget(budget time.Duration, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.budget = budget
+	if !b.since.IsZero() {
+		b.arm(now)
+	}
+}
+
+func (b *budgetTimer) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	b.disarm()
+}
+
+func (b *budgetTimer) arm(now time.Time) {
+	b.disarm()
+	if b.budget <= 0 || b.stopped {
+		return
+	}
+	// a spent budget fires at once
+	b.timer = time.AfterFunc(b.budget-b.used-now.Sub(b.since), b.fire)
+}
+
+func (b *budgetTimer) disarm() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"webcrawler/internal/metrics"
+	"webcrawler/internal/storage"
+)
+
+const (
+	maxCheckRedirects = 10
+	checkDrainBytes   = 64 << 10
+)
+
+type checkResult struct {
+	status   int
+	method   string
+	finalURL string
+	reused   bool
+}
+
+// handleCheck validates an out-of-scope link. It issues HEAD, falls back to
+// a single-byte ranged GET when the server rejects HEAD, and follows
+// redirects inline so the recorded status is the final one.
+func (e *Engine) handleCheck(task *Task) {
+	start := time.Now()
+	res, err := e.checkURL(task.URL)
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		class := classifyError(err)
+		if e.shouldRetry(task, class, 0) {
+			return
+		}
+		e.recordCheck(task, res, latency, class, err.Error())
+		return
+	}
+	if res.status == http.StatusTooManyRequests || res.status >= 500 {
+		if e.retry(task, ErrStatus, 0) {
+			return
+		}
+	}
+	if res.status >= 400 {
+		e.recordCheck(task, res, latency, ErrStatus, http.StatusText(res.status))
+		return
+	}
+	e.recordCheck(task, res, latency, "", "")
+}
+
+func (e *Engine) checkURL(target string) (checkResult, error) {
+	res := checkResult{finalURL: target}
+	for hop := 0; hop <= maxCheckRedirects; hop++ {
+		resp, reused, err := e.checkOnce(http.MethodHead, res.finalURL)
+		res.method = http.MethodHead
+		if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			resp.Body.Close()
+			resp, reused, err = e.checkOnce(http.MethodGet, res.finalURL)
+			res.method = http.MethodGet
+		}
+		if err != nil {
+			return res, err
+		}
+		drainBodyLimited(resp.Body, checkDrainBytes)
+		resp.Body.Close()
+		res.status = resp.StatusCode
+		res.reused = reused
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			return res, nil
+		}
+		base, err := url.Parse(res.finalURL)
+		if err != nil {
+			return res, nil
+		}
+		loc, err := url.Parse(location)
+		if err != nil {
+			return res, nil
+		}
+		res.finalURL = base.ResolveReference(loc).String()
+	}
+	return res, nil
+}
+
+func (e *Engine) checkOnce(method, target string) (*http.Response, bool, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, e.cfg.RequestTimeout)
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		cancel()
+		return nil, false, err
+	}
+	if e.cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", e.cfg.UserAgent)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := e.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, reused, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, reused, nil
+}
+
+func (e *Engine) recordCheck(task *Task, res checkResult, latency int64, errClass, errMessage string) {
+	if errClass != "" {
+		metrics.FetchErrors.WithLabelValues(errClass).Inc()
+	}
+	metrics.LinksChecked.Inc()
+	e.traceURL(task, EventFetch, traceAttempt(task, map[string]any{
+		"method":      res.method,
+		"status_code": res.status,
+		"error_class": errClass,
+		"fetch_ms":    latency,
+		"final_url":   res.finalURL,
+	}))
+	if e.telemetry != nil {
+		select {
+		case e.telemetry.FetchEvents() <- metrics.FetchEvent{Host: task.Host, LatencyMS: latency, ReusedConn: res.reused, ErrClass: errClass}:
+		default:
+		}
+	}
+	if hs := e.scheduler.HostState(task.Host); hs != nil {
+		if hs.OnResult(errClass == "" || (res.status > 0 && res.status < 500)) {
+			e.recordCircuitTrip(task)
+		}
+	}
+	finalURL := res.finalURL
+	if finalURL == task.URL {
+		finalURL = ""
+	}
+	rec := storage.LinkStatusRecord{
+		RunID:        e.runID,
+		URL:          task.URL,
+		CanonicalURL: task.Canonical,
+		Host:         task.Host,
+		StatusCode:   res.status,
+		Method:       res.method,
+		FinalURL:     finalURL,
+		FetchMS:      latency,
+		ErrClass:     errClass,
+		ErrMessage:   errMessage,
+		CheckedAt:    time.Now(),
+	}
+	e.writer.send(writeLinkStatus, rec)
+	if len(task.Redirects) > 0 {
+		outcome := RedirectOutcomeOK
+		if errClass != "" {
+			outcome = RedirectOutcomeError
+		}
+		e.recordRedirectChain(task, task.Redirects, task.URL, res.status, outcome)
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
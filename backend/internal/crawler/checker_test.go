@@ -0,0 +1,162 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+func TestLinkCheckModeValidatesExternalLinksOnce(t *testing.T) {
+	var externalGets, externalHeads atomic.Int32
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			externalHeads.Add(1)
+			if r.URL.Path == "/no-head" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+		case http.MethodGet:
+			externalGets.Add(1)
+			if r.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("fallback GET should be ranged, got %q", r.Header.Get("Range"))
+			}
+		}
+		if r.URL.Path == "/missing" || r.URL.Path == "/no-head" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer external.Close()
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintf(w, `<a href="/about">About</a><a href="%[1]s/ok">ok</a><a href="%[1]s/missing">missing</a>`, external.URL)
+		case "/about":
+			fmt.Fprintf(w, `<a href="%[1]s/ok">ok again</a><a href="%[1]s/no-head">no head</a>`, external.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeLinkCheck), store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	statuses := waitForLinkStatuses(t, store, runID, 3)
+	byPath := map[string]storage.LinkStatusRow{}
+	for _, st := range statuses {
+		byPath[st.URL[len(external.URL):]] = st
+	}
+	if st := byPath["/ok"]; st.StatusCode != 200 || st.Method != http.MethodHead || st.ErrorClass != "" {
+		t.Fatalf("unexpected /ok status: %+v", st)
+	}
+	if st := byPath["/missing"]; st.StatusCode != 404 || st.ErrorClass != ErrStatus {
+		t.Fatalf("unexpected /missing status: %+v", st)
+	}
+	if st := byPath["/no-head"]; st.StatusCode != 404 || st.Method != http.MethodGet {
+		t.Fatalf("unexpected /no-head status: %+v", st)
+	}
+	if externalHeads.Load() != 3 || externalGets.Load() != 1 {
+		t.Fatalf("expected each external link checked once, got %d HEAD / %d GET", externalHeads.Load(), externalGets.Load())
+	}
+	pages, _ := store.ListPages(ctx, runID, 50)
+	for _, p := range pages {
+		if p.Host != hostKeyFor(t, site.URL) {
+			t.Fatalf("external url crawled as page: %s", p.URL)
+		}
+	}
+}
+
+func TestLinkCheckModeChecksLinksOnMaxDepthPages(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer external.Close()
+
+	var deeperFetched atomic.Bool
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/deep">deep</a>`)
+		case "/deep":
+			fmt.Fprintf(w, `<a href="/deeper">deeper</a><a href="%s/from-deep">external</a>`, external.URL)
+		case "/deeper":
+			deeperFetched.Store(true)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeLinkCheck)
+	cfg.MaxDepth = 1
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	statuses := waitForLinkStatuses(t, store, runID, 1)
+	if statuses[0].URL != external.URL+"/from-deep" || statuses[0].StatusCode != 200 {
+		t.Fatalf("unexpected link status: %+v", statuses[0])
+	}
+	engine.Stop()
+	if deeperFetched.Load() {
+		t.Fatal("page beyond max depth was crawled")
+	}
+}
+
+func testRunConfig(seed, mode string) RunConfig {
+	return RunConfig{
+		SeedURL:            seed,
+		MaxDepth:           3,
+		MaxPages:           100,
+		GlobalConcurrency:  4,
+		PerHostConcurrency: 2,
+		RequestTimeout:     2 * time.Second,
+		RetryBaseDelay:     10 * time.Millisecond,
+		CircuitTripCount:   10,
+		CircuitResetTime:   time.Second,
+		Mode:               mode,
+	}.Normalize()
+}
+
+func waitForLinkStatuses(t *testing.T, store storage.Store, runID uuid.UUID, n int) []storage.LinkStatusRow {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rows, _ := store.ListLinkStatuses(context.Background(), runID, false, 100)
+		if len(rows) >= n {
+			return rows
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d link statuses", n)
+	return nil
+}
+
+func hostKeyFor(t *testing.T, raw string) string {
+	t.Helper()
+	_, parsed, err := Canonicalize(raw)
+	if err != nil {
+		t.Fatalf("canonicalize %s: %v", raw, err)
+	}
+	return HostKey(parsed)
+}
@@ -3,9 +3,9 @@ package crawler
 import (
 	"bytes"
 	"context"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/http/httptrace"
@@ -17,9 +17,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/net/html"
+	"golang.org/x/net/http2"
 	"webcrawler/internal/crawler/robots"
 	"webcrawler/internal/metrics"
+	"webcrawler/internal/search"
 	"webcrawler/internal/storage"
 )
 
@@ -34,46 +35,47 @@ type Engine struct {
 
 	deduper   *Deduper
 	scheduler *Scheduler
+	globalSem *Semaphore
 	robotsMgr *robots.Manager
 	client    *http.Client
+	textIndex *search.Index
+	seedHost  string
 
 	enqueueCh chan *Task
 	fetchCh   chan *Task
 	parseCh   chan *FetchResult
+	fetchPool *workerPool
+	parsePool *workerPool
 
-	pageWrites chan storage.PageRecord
-	errorWrites chan errorRecord
-	edgeWrites  chan edgeRecord
+	writer    *storageWriter
+	workers   sync.WaitGroup
+	drained   chan struct{}
+	inspected sync.Map
 
-	startedAt time.Time
+	startedAt    time.Time
+	budget       *budgetTimer
 	pagesFetched atomic.Int64
+	maxPages     atomic.Int64
 	stopReasonMu sync.Mutex
 	stopReason   string
-	stopOnce sync.Once
+	stopOnce     sync.Once
+	pauseMu      sync.Mutex
+	paused       bool
+	// live is cfg with the changes made by Reconfigure; the engine reads the
+	// live settings from the semaphores, pools, budget and maxPages.
+	liveMu sync.Mutex
+	live   RunConfig
 }
 
 const (
-	StopReasonManual    = "manual"
-	StopReasonMaxPages  = "max_pages"
+	StopReasonManual     = "manual"
+	StopReasonMaxPages   = "max_pages"
 	StopReasonTimeBudget = "time_budget"
-	StopReasonUnknown   = "unknown"
+	StopReasonSuperseded = "superseded"
+	StopReasonStorage    = "storage_error"
+	StopReasonUnknown    = "unknown"
 )
 
-type errorRecord struct {
-	runID   uuid.UUID
-	host    string
-	url     string
-	class   string
-	message string
-}
-
-type edgeRecord struct {
-	runID uuid.UUID
-	src   string
-	dst   string
-	count int
-}
-
 func NewEngine(runID uuid.UUID, cfg RunConfig, store storage.Store, telemetry *metrics.Telemetry) *Engine {
 	cfg = cfg.Normalize()
 	if cfg.GlobalConcurrency <= 0 {
@@ -88,6 +90,15 @@ func NewEngine(runID uuid.UUID, cfg RunConfig, store storage.Store, telemetry *m
 	if cfg.RetryMax < 0 {
 		cfg.RetryMax = 0
 	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = 10
+	}
+	if cfg.WriteBatchSize <= 0 {
+		cfg.WriteBatchSize = 500
+	}
+	if cfg.WriteFlushInterval <= 0 {
+		cfg.WriteFlushInterval = 250 * time.Millisecond
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -113,25 +124,48 @@ func NewEngine(runID uuid.UUID, cfg RunConfig, store storage.Store, telemetry *m
 	}
 
 	scheduler := NewScheduler(ctx, enqueueCh, fetchCh, frontierCap, globalSem, cfg.PerHostConcurrency, cfg.CircuitTripCount, cfg.CircuitResetTime, cfg.RespectRobots, robotsMgr)
-
-	return &Engine{
-		runID:      runID,
-		cfg:        cfg,
-		store:      store,
-		telemetry:  telemetry,
-		ctx:        ctx,
-		cancel:     cancel,
-		deduper:    NewDeduper(64),
-		scheduler:  scheduler,
-		robotsMgr:  robotsMgr,
-		client:     client,
-		enqueueCh:  enqueueCh,
-		fetchCh:    fetchCh,
-		parseCh:    parseCh,
-		pageWrites: make(chan storage.PageRecord, 2048),
-		errorWrites: make(chan errorRecord, 1024),
-		edgeWrites: make(chan edgeRecord, 1024),
-	}
+	if cfg.Mode == ModeLinkCheck {
+		if cfg.ExternalConcurrency <= 0 {
+			cfg.ExternalConcurrency = max(4, cfg.GlobalConcurrency/4)
+		}
+		if cfg.ExternalPerHostConcurrency <= 0 {
+			cfg.ExternalPerHostConcurrency = 1
+		}
+		scheduler.SetCheckBudget(NewSemaphore(cfg.ExternalConcurrency), cfg.ExternalPerHostConcurrency)
+	}
+
+	seedHost := ""
+	if _, parsed, err := Canonicalize(cfg.SeedURL); err == nil {
+		seedHost = HostKey(parsed)
+	}
+
+	e := &Engine{
+		runID:     runID,
+		cfg:       cfg,
+		store:     store,
+		telemetry: telemetry,
+		ctx:       ctx,
+		cancel:    cancel,
+		deduper:   NewDeduper(64),
+		scheduler: scheduler,
+		globalSem: globalSem,
+		robotsMgr: robotsMgr,
+		client:    client,
+		seedHost:  seedHost,
+		enqueueCh: enqueueCh,
+		fetchCh:   fetchCh,
+		parseCh:   parseCh,
+		writer:    newStorageWriter(store, cfg.LosslessWrites, cfg.WriteBatchSize, cfg.WriteFlushInterval),
+		drained:   make(chan struct{}),
+		live:      cfg,
+	}
+	e.maxPages.Store(int64(cfg.MaxPages))
+	e.writer.onFail = func() { e.StopWithReason(StopReasonStorage) }
+	e.budget = newBudgetTimer(cfg.TimeBudget, func() { e.StopWithReason(StopReasonTimeBudget) })
+	e.fetchPool = newWorkerPool(func() { e.goWorker(e.fetchLoop) })
+	e.parsePool = newWorkerPool(func() { e.goWorker(e.parseLoop) })
+	scheduler.SetHostLimits(cfg.PerHostConcurrency, cfg.HostConcurrency)
+	return e
 }
 
 func buildTransport(cfg RunConfig) *http.Transport {
@@ -139,10 +173,9 @@ func buildTransport(cfg RunConfig) *http.Transport {
 		Timeout:   cfg.HeaderTimeout,
 		KeepAlive: 30 * time.Second,
 	}
-	return &http.Transport{
+	t := &http.Transport{
 		Proxy:                 http.ProxyFromEnvironment,
 		DialContext:           dialer.DialContext,
-		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          cfg.GlobalConcurrency * 4,
 		MaxIdleConnsPerHost:   max(cfg.PerHostConcurrency*2, 8),
 		MaxConnsPerHost:       max(cfg.PerHostConcurrency*4, 16),
@@ -151,6 +184,17 @@ func buildTransport(cfg RunConfig) *http.Transport {
 		ResponseHeaderTimeout: cfg.HeaderTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+	// HTTP/2 goes through x/net rather than the copy bundled in net/http, so
+	// stream resets and GOAWAYs surface as the http2 error types that
+	// classifyError recognizes. It only fails on a transport already set up.
+	if _, err := http2.ConfigureTransports(t); err != nil {
+		t.ForceAttemptHTTP2 = true
+	}
+	return t
+}
+
+func (e *Engine) SetTextIndex(idx *search.Index) {
+	e.textIndex = idx
 }
 
 func (e *Engine) Start(seed string) {
@@ -167,31 +211,53 @@ func (e *Engine) Start(seed string) {
 			}
 			return out
 		})
+		e.telemetry.SetStatusGetter(func() string {
+			if e.Paused() {
+				return "paused"
+			}
+			return "running"
+		})
 		e.telemetry.SetRobotsManager(e.robotsMgr)
-		go e.telemetry.Run(e.ctx)
+		e.telemetry.SetHostStatSink(e.writeHostStats)
+		e.goWorker(func() { e.telemetry.Run(e.ctx) })
 	}
 
-	go e.scheduler.Run()
-	go e.storageLoop()
+	e.scheduler.SetDropHandler(e.recordSkip)
+	e.scheduler.SetTraceHandler(e.traceURL)
+	e.goWorker(e.scheduler.Run)
+	go e.writer.run()
 	go e.monitorStop()
 
-	fetchWorkers := max(4, e.cfg.GlobalConcurrency)
-	parseWorkers := max(2, e.cfg.GlobalConcurrency/2)
-	for i := 0; i < fetchWorkers; i++ {
-		go e.fetchLoop()
-	}
-	for i := 0; i < parseWorkers; i++ {
-		go e.parseLoop()
-	}
+	e.liveMu.Lock()
+	fetchWorkers, parseWorkers := workerCounts(e.live)
+	e.fetchPool.resize(fetchWorkers)
+	e.parsePool.resize(parseWorkers)
+	e.liveMu.Unlock()
 
 	e.enqueueURL(seed, 0, "")
-	if e.cfg.TimeBudget > 0 {
-		go e.stopAfterBudget()
-	}
+	e.budget.start(e.startedAt)
+}
+
+// goWorker starts a goroutine that may still produce storage writes after
+// the context is cancelled; the writer is closed only once all have exited.
+func (e *Engine) goWorker(fn func()) {
+	e.workers.Add(1)
+	go func() {
+		defer e.workers.Done()
+		fn()
+	}()
 }
 
 func (e *Engine) monitorStop() {
 	<-e.ctx.Done()
+	e.budget.stop()
+	e.fetchPool.close()
+	e.parsePool.close()
+	e.workers.Wait()
+	e.scheduler.abandonOpenCircuits()
+	e.writer.close()
+	<-e.writer.done
+	defer close(e.drained)
 	now := time.Now()
 	reason := e.StopReason()
 	if reason == "" {
@@ -239,15 +305,46 @@ func (e *Engine) Done() <-chan struct{} {
 	return e.ctx.Done()
 }
 
-func (e *Engine) stopAfterBudget() {
-	t := time.NewTimer(e.cfg.TimeBudget)
-	defer t.Stop()
-	select {
-	case <-e.ctx.Done():
-		return
-	case <-t.C:
-		e.StopWithReason(StopReasonTimeBudget)
+// Drained is closed after the engine stopped and every buffered record was
+// written to the store.
+func (e *Engine) Drained() <-chan struct{} {
+	return e.drained
+}
+
+// Pause stops the scheduler from dispatching fetches and holds the time
+// budget. Fetches already dispatched finish and the links they find still
+// enter the frontier. It reports false when the engine is already paused or
+// has stopped.
+func (e *Engine) Pause() bool {
+	e.pauseMu.Lock()
+	defer e.pauseMu.Unlock()
+	if e.paused || e.ctx.Err() != nil {
+		return false
 	}
+	e.paused = true
+	e.scheduler.SetPaused(true)
+	e.budget.pause(time.Now())
+	return true
+}
+
+// Resume continues a paused engine. It reports false when the engine is not
+// paused or has stopped.
+func (e *Engine) Resume() bool {
+	e.pauseMu.Lock()
+	defer e.pauseMu.Unlock()
+	if !e.paused || e.ctx.Err() != nil {
+		return false
+	}
+	e.paused = false
+	e.scheduler.SetPaused(false)
+	e.budget.resume(time.Now())
+	return true
+}
+
+func (e *Engine) Paused() bool {
+	e.pauseMu.Lock()
+	defer e.pauseMu.Unlock()
+	return e.paused
 }
 
 func (e *Engine) enqueueURL(raw string, depth int, sourceHost string) {
@@ -263,23 +360,20 @@ func (e *Engine) enqueueURL(raw string, depth int, sourceHost string) {
 	}
 	host := HostKey(parsed)
 	task := &Task{URL: parsed.String(), Canonical: canonical, Host: host, Depth: depth, SourceHost: sourceHost, DiscoveredAt: time.Now()}
-	select {
-	case e.enqueueCh <- task:
-		// ok
-	default:
-		// backpressure: block until space or context done
-		select {
-		case e.enqueueCh <- task:
-		case <-e.ctx.Done():
-		}
-	}
+	e.traceDiscovered(task, true)
+	// blocks under backpressure until space or context done
+	e.pushTask(task)
 }
 
 func (e *Engine) fetchLoop() {
 	for {
+		if e.fetchPool.retire() {
+			return
+		}
 		select {
 		case <-e.ctx.Done():
 			return
+		case <-e.fetchPool.shrunk():
 		case task := <-e.fetchCh:
 			if task == nil {
 				continue
@@ -292,7 +386,12 @@ func (e *Engine) fetchLoop() {
 func (e *Engine) handleFetch(task *Task) {
 	defer task.Permit.Release()
 
-	if e.cfg.MaxPages > 0 && int(e.pagesFetched.Load()) >= e.cfg.MaxPages {
+	if task.Kind == TaskCheck {
+		e.handleCheck(task)
+		return
+	}
+
+	if e.maxPagesReached() {
 		return
 	}
 
@@ -308,92 +407,118 @@ func (e *Engine) handleFetch(task *Task) {
 		req.Header.Set("User-Agent", e.cfg.UserAgent)
 	}
 
-	var reusedConn bool
-	trace := &httptrace.ClientTrace{
-		GotConn: func(info httptrace.GotConnInfo) {
-			reusedConn = info.Reused
-		},
-	}
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	trace := &fetchTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
 
 	start := time.Now()
 	resp, err := e.client.Do(req)
-	latency := time.Since(start).Milliseconds()
+	res := &FetchResult{Task: task, FetchMS: time.Since(start).Milliseconds(), ReusedConn: trace.reusedConn(), trace: trace}
 
 	if err != nil {
 		class := classifyError(err)
+		if tlsClass(class) {
+			e.probeTLS(task, err)
+		}
 		if e.shouldRetry(task, class, 0) {
 			return
 		}
-		e.recordFetch(task, 0, "", nil, latency, 0, reusedConn, class, err.Error())
+		e.recordFetch(res.fail(class, err.Error()))
 		return
 	}
 	defer resp.Body.Close()
+	e.inspectHost(task, resp)
 
 	status := resp.StatusCode
-	contentType := resp.Header.Get("Content-Type")
+	res.StatusCode = status
+	res.ContentType = resp.Header.Get("Content-Type")
 
 	if status >= 300 && status < 400 {
-		location := resp.Header.Get("Location")
-		size, _ := drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
-		e.handleRedirect(task, location)
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, "", "")
+		res.SizeBytes, _ = drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
+		e.handleRedirect(res, resp.Header.Get("Location"))
+		e.recordFetch(res)
 		return
 	}
 
 	if status == http.StatusTooManyRequests {
 		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
-		size, _ := drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
-		if e.shouldRetry(task, ErrStatus, retryAfter) {
+		res.SizeBytes, _ = drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
+		if e.retry(task, ErrStatus, retryAfter) {
 			return
 		}
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrStatus, "too_many_requests")
+		e.recordFetch(res.fail(ErrStatus, "too_many_requests"))
 		return
 	}
 
 	if status >= 500 {
-		size, _ := drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
-		if e.shouldRetry(task, ErrStatus, 0) {
+		res.SizeBytes, _ = drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
+		if e.retry(task, ErrStatus, 0) {
 			return
 		}
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrStatus, resp.Status)
+		e.recordFetch(res.fail(ErrStatus, resp.Status))
 		return
 	}
 
 	if status >= 400 {
-		size, _ := drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrStatus, resp.Status)
+		res.SizeBytes, _ = drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
+		e.recordFetch(res.fail(ErrStatus, resp.Status))
 		return
 	}
 
-	needBody := isHTML(contentType) && (e.cfg.MaxDepth <= 0 || task.Depth < e.cfg.MaxDepth)
-	if needBody {
+	// HTML is buffered only when the parser wants its links or text
+	if isHTML(res.ContentType) && e.wantBody(task) {
 		body, size, errClass := readBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
+		res.SizeBytes = size
 		if errClass == ErrSizeLimit {
-			e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrSizeLimit, "max_body_bytes")
+			e.recordFetch(res.fail(ErrSizeLimit, "max_body_bytes"))
 			return
 		}
 		if errClass != "" {
-			e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrFetch, errClass)
+			e.recordFetch(res.fail(ErrFetch, errClass))
 			return
 		}
-		e.recordFetch(task, status, contentType, body, latency, size, reusedConn, "", "")
+		sum := sha256.Sum256(body)
+		res.ContentHash = hex.EncodeToString(sum[:])
+		res.Title = extractTitle(bytes.NewReader(body))
+		res.Body = body
+		res.parseLinks = e.wantLinks(task)
+		e.recordFetch(res)
 		return
 	}
 
-	size, errClass := drainBodyLimited(resp.Body, e.cfg.MaxBodyBytes)
-	if errClass == ErrSizeLimit {
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrSizeLimit, "max_body_bytes")
+	// other bodies are hashed while streaming; an HTML title is read from
+	// the head on the way
+	hash := sha256.New()
+	limited := &io.LimitedReader{R: resp.Body, N: e.cfg.MaxBodyBytes + 1}
+	stream := io.TeeReader(limited, hash)
+	if isHTML(res.ContentType) {
+		res.Title = extractTitle(stream)
+	}
+	_, err = io.Copy(io.Discard, stream)
+	res.SizeBytes = e.cfg.MaxBodyBytes + 1 - limited.N
+	if res.SizeBytes > e.cfg.MaxBodyBytes {
+		e.recordFetch(res.fail(ErrSizeLimit, "max_body_bytes"))
 		return
 	}
-	if errClass != "" {
-		e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, ErrFetch, errClass)
+	if err != nil {
+		e.recordFetch(res.fail(ErrFetch, err.Error()))
 		return
 	}
-	e.recordFetch(task, status, contentType, nil, latency, size, reusedConn, "", "")
+	res.ContentHash = hex.EncodeToString(hash.Sum(nil))
+	e.recordFetch(res)
 }
 
-func (e *Engine) recordFetch(task *Task, status int, contentType string, body []byte, latency int64, size int64, reused bool, errClass, errMessage string) {
+func (e *Engine) recordFetch(res *FetchResult) {
+	task := res.Task
+	if res.trace != nil {
+		res.Timing = res.trace.timing(time.Now())
+	}
+	e.traceURL(task, EventFetch, traceAttempt(task, map[string]any{
+		"status_code": res.StatusCode,
+		"error_class": res.ErrClass,
+		"fetch_ms":    res.FetchMS,
+		"size_bytes":  res.SizeBytes,
+	}))
+	errClass := res.ErrClass
 	if errClass == "" {
 		e.pagesFetched.Add(1)
 		metrics.PagesFetched.Inc()
@@ -403,17 +528,30 @@ func (e *Engine) recordFetch(task *Task, status int, contentType string, body []
 
 	if e.telemetry != nil {
 		select {
-		case e.telemetry.FetchEvents() <- metrics.FetchEvent{Host: task.Host, LatencyMS: latency, Bytes: size, ReusedConn: reused, ErrClass: errClass}:
+		case e.telemetry.FetchEvents() <- metrics.FetchEvent{
+			Host:       task.Host,
+			LatencyMS:  res.FetchMS,
+			Bytes:      res.SizeBytes,
+			ReusedConn: res.ReusedConn,
+			ErrClass:   errClass,
+			DNS:        res.Timing.DNS,
+			Connect:    res.Timing.Connect,
+			TLS:        res.Timing.TLS,
+			TTFB:       res.Timing.TTFB,
+			Transfer:   res.Timing.Transfer,
+		}:
 		default:
 		}
 	}
 
-	success := errClass == "" && status < 500
+	success := errClass == "" && res.StatusCode < 500
 	if hs := e.scheduler.HostState(task.Host); hs != nil {
-		hs.OnResult(success)
+		if hs.OnResult(success) {
+			e.recordCircuitTrip(task)
+		}
 	}
 
-	if e.cfg.MaxPages > 0 && int(e.pagesFetched.Load()) >= e.cfg.MaxPages {
+	if e.maxPagesReached() {
 		e.StopWithReason(StopReasonMaxPages)
 	}
 
@@ -423,43 +561,73 @@ func (e *Engine) recordFetch(task *Task, status int, contentType string, body []
 		discovered = e.startedAt
 	}
 	rec := storage.PageRecord{
-		RunID:        e.runID,
-		URL:          task.URL,
-		CanonicalURL: task.Canonical,
-		Host:         task.Host,
-		Depth:        task.Depth,
-		StatusCode:   status,
-		ContentType:  contentType,
-		FetchMS:      latency,
-		SizeBytes:    size,
-		ErrClass:     errClass,
-		ErrMessage:   errMessage,
-		DiscoveredAt: discovered,
-		FetchedAt:    &fetchedAt,
-	}
-	select {
-	case e.pageWrites <- rec:
-	default:
-	}
+		RunID:         e.runID,
+		URL:           task.URL,
+		CanonicalURL:  task.Canonical,
+		Host:          task.Host,
+		Depth:         task.Depth,
+		StatusCode:    res.StatusCode,
+		ContentType:   res.ContentType,
+		FetchMS:       res.FetchMS,
+		SizeBytes:     res.SizeBytes,
+		ErrClass:      errClass,
+		ErrMessage:    res.ErrMessage,
+		Referrer:      task.Referrer,
+		RedirectURL:   res.RedirectURL,
+		OriginURL:     task.OriginURL,
+		RedirectChain: task.Redirects,
+		Timing:        res.Timing.record(),
+		Title:         res.Title,
+		ContentHash:   res.ContentHash,
+		DiscoveredAt:  discovered,
+		FetchedAt:     &fetchedAt,
+	}
+	e.writer.send(writePage, rec)
 
 	if errClass != "" {
-		select {
-		case e.errorWrites <- errorRecord{runID: e.runID, host: task.Host, url: task.URL, class: errClass, message: errMessage}:
-		default:
+		e.writeError(task, errClass, res.ErrMessage)
+	}
+
+	if len(task.Redirects) > 0 && (res.StatusCode < 300 || res.StatusCode >= 400) {
+		outcome := RedirectOutcomeOK
+		if errClass != "" {
+			outcome = RedirectOutcomeError
 		}
+		e.recordRedirectChain(task, task.Redirects, task.URL, res.StatusCode, outcome)
 	}
 
-	if body != nil && isHTML(contentType) && (e.cfg.MaxDepth <= 0 || task.Depth < e.cfg.MaxDepth) {
+	if errClass == "" && isHTML(res.ContentType) && e.cfg.MaxDepth > 0 && task.Depth >= e.cfg.MaxDepth {
+		e.recordSkip(task, SkipMaxDepth, "links not followed at depth "+strconv.Itoa(task.Depth))
+	}
+
+	if res.Body != nil && isHTML(res.ContentType) {
 		select {
-		case e.parseCh <- &FetchResult{Task: task, StatusCode: status, ContentType: contentType, Body: body, FetchMS: latency, SizeBytes: size, ReusedConn: reused}:
+		case e.parseCh <- res:
 		default:
-			// drop parse if backpressure
+			e.recordSkip(task, SkipParseDropped, "parse queue full")
 		}
 	}
 }
 
-func (e *Engine) handleRedirect(task *Task, location string) {
+func (e *Engine) wantBody(task *Task) bool {
+	return e.textIndex != nil || e.wantLinks(task)
+}
+
+// wantLinks reports whether links are extracted from task's page: pages
+// above MaxDepth, and in link-check mode every page so its out-of-scope
+// links are checked.
+func (e *Engine) wantLinks(task *Task) bool {
+	return e.cfg.Mode == ModeLinkCheck || e.cfg.MaxDepth <= 0 || task.Depth < e.cfg.MaxDepth
+}
+
+// handleRedirect follows a 3xx response. The new task inherits the chain of
+// hops so the final page can point back at the URL that started it; loops
+// and chains longer than MaxRedirects end the chain with an error class on
+// the current hop.
+func (e *Engine) handleRedirect(res *FetchResult, location string) {
+	task := res.Task
 	if location == "" {
+		res.fail(ErrHTTP, "redirect without Location header")
 		return
 	}
 	base, err := url.Parse(task.URL)
@@ -468,6 +636,7 @@ func (e *Engine) handleRedirect(task *Task, location string) {
 	}
 	loc, err := url.Parse(location)
 	if err != nil {
+		res.fail(ErrHTTP, "invalid Location header: "+err.Error())
 		return
 	}
 	resolved := base.ResolveReference(loc)
@@ -475,201 +644,327 @@ func (e *Engine) handleRedirect(task *Task, location string) {
 	if err != nil {
 		return
 	}
-	if e.deduper.Seen(canonical) {
+	res.RedirectURL = resolved.String()
+	host := HostKey(parsed)
+	kind, inScope := e.linkKind(host)
+	e.writer.send(writeLink, []storage.LinkRecord{{RunID: e.runID, SrcURL: task.Canonical, DstURL: canonical, Element: "redirect", Followed: inScope}})
+
+	hops := make([]storage.RedirectHop, 0, len(task.Redirects)+1)
+	hops = append(hops, task.Redirects...)
+	hops = append(hops, storage.RedirectHop{URL: task.Canonical, StatusCode: res.StatusCode})
+	for _, hop := range hops {
+		if hop.URL == canonical {
+			res.fail(ErrRedirectLoop, "redirect loop to "+canonical)
+			e.traceRedirect(task, res, canonical, RedirectOutcomeLoop)
+			e.recordRedirectChain(task, hops, canonical, 0, RedirectOutcomeLoop)
+			return
+		}
+	}
+	if len(hops) > e.cfg.MaxRedirects {
+		res.fail(ErrRedirectLimit, "more than "+strconv.Itoa(e.cfg.MaxRedirects)+" redirects")
+		e.traceRedirect(task, res, canonical, RedirectOutcomeLimit)
+		e.recordRedirectChain(task, hops, canonical, 0, RedirectOutcomeLimit)
 		return
 	}
-	host := HostKey(parsed)
-	task.SourceHost = task.Host
-	newTask := &Task{URL: resolved.String(), Canonical: canonical, Host: host, Depth: task.Depth, SourceHost: task.Host, DiscoveredAt: time.Now()}
-	select {
-	case e.enqueueCh <- newTask:
-	default:
-		select {
-		case e.enqueueCh <- newTask:
-		case <-e.ctx.Done():
+	if !inScope {
+		e.traceRedirect(task, res, canonical, RedirectOutcomeOutOfScope)
+		e.recordRedirectChain(task, hops, canonical, 0, RedirectOutcomeOutOfScope)
+		if !e.deduper.Seen(canonical) {
+			skipped := &Task{URL: resolved.String(), Canonical: canonical, Host: host, Depth: task.Depth, Referrer: task.URL}
+			e.traceDiscovered(skipped, false)
+			e.recordSkip(skipped, SkipOutOfScope, "redirect target outside scope "+e.cfg.Scope)
 		}
+		return
+	}
+	if e.deduper.Seen(canonical) {
+		e.traceRedirect(task, res, canonical, RedirectOutcomeSeen)
+		e.recordRedirectChain(task, hops, canonical, 0, RedirectOutcomeSeen)
+		return
+	}
+	origin := task.OriginURL
+	if origin == "" {
+		origin = task.URL
 	}
+	newTask := &Task{Kind: kind, URL: resolved.String(), Canonical: canonical, Host: host, Depth: task.Depth, SourceHost: task.Host, Referrer: task.URL, OriginURL: origin, Redirects: hops, DiscoveredAt: time.Now()}
+	e.traceRedirect(task, res, canonical, RedirectOutcomeOK)
+	e.traceDiscovered(newTask, true)
+	e.pushTask(newTask)
 	if task.Host != host && e.telemetry != nil {
 		select {
 		case e.telemetry.EdgeEvents() <- metrics.EdgeEvent{Src: task.Host, Dst: host}:
 		default:
 		}
 	}
-	select {
-	case e.edgeWrites <- edgeRecord{runID: e.runID, src: task.Host, dst: host, count: 1}:
-	default:
+	e.writer.send(writeEdge, storage.EdgeRecord{RunID: e.runID, Src: task.Host, Dst: host, Count: 1})
+}
+
+func (e *Engine) recordRedirectChain(task *Task, hops []storage.RedirectHop, finalURL string, finalStatus int, outcome string) {
+	origin := task.OriginURL
+	if origin == "" {
+		origin = task.URL
+	}
+	rec := storage.RedirectChainRecord{
+		RunID:       e.runID,
+		OriginURL:   origin,
+		FinalURL:    finalURL,
+		FinalStatus: finalStatus,
+		Hops:        hops,
+		Outcome:     outcome,
+	}
+	e.writer.send(writeChain, rec)
+}
+
+func (e *Engine) writeHostStats(stats []metrics.HostStat) {
+	for _, st := range stats {
+		rec := storage.HostStatRecord{
+			RunID:       e.runID,
+			Host:        st.Host,
+			BucketStart: st.BucketStart,
+			Requests:    st.Requests,
+			Errors:      st.Errors,
+			Latency:     storagePercentiles(st.Latency),
+			Bytes:       st.Bytes,
+			ReuseRate:   st.ReuseRate,
+			DNS:         storagePercentiles(st.Phases.DNS),
+			Connect:     storagePercentiles(st.Phases.Connect),
+			TLS:         storagePercentiles(st.Phases.TLS),
+			TTFB:        storagePercentiles(st.Phases.TTFB),
+			Transfer:    storagePercentiles(st.Phases.Transfer),
+		}
+		e.writer.send(writeHostStat, rec)
+		e.writer.send(writeHostState, storage.HostStateRecord{RunID: e.runID, Host: st.Host, RobotsState: st.RobotsState, CircuitState: st.Circuit})
 	}
 }
 
+func storagePercentiles(p metrics.Percentiles) storage.Percentiles {
+	return storage.Percentiles{P50MS: p.P50Ms, P95MS: p.P95Ms}
+}
+
 func (e *Engine) parseLoop() {
 	for {
+		if e.parsePool.retire() {
+			return
+		}
 		select {
 		case <-e.ctx.Done():
 			return
+		case <-e.parsePool.shrunk():
 		case res := <-e.parseCh:
 			if res == nil {
 				continue
 			}
-			e.handleParse(res)
+			if res.parseLinks {
+				e.handleParse(res)
+			}
+			e.handleExtract(res)
 		}
 	}
 }
 
+// handleParse extracts a page's links and queues the ones to follow. Crawl
+// links and link checks have separate MaxLinksPerPage budgets. A page at
+// MaxDepth only reaches here in link-check mode: its out-of-scope links are
+// checked, but it queues no crawl tasks.
 func (e *Engine) handleParse(res *FetchResult) {
-	if e.cfg.MaxDepth > 0 && res.Task.Depth >= e.cfg.MaxDepth {
-		return
-	}
+	atMaxDepth := e.cfg.MaxDepth > 0 && res.Task.Depth >= e.cfg.MaxDepth
 	baseURL, err := url.Parse(res.Task.URL)
 	if err != nil {
 		return
 	}
 
-	tok := html.NewTokenizer(bytes.NewReader(res.Body))
-	linksFound := 0
-	for {
-		tt := tok.Next()
-		switch tt {
-		case html.ErrorToken:
-			if tok.Err() != io.EOF {
-				e.recordError(res.Task, ErrParse, tok.Err().Error())
-			}
-			return
-		case html.StartTagToken, html.SelfClosingTagToken:
-			name, hasAttr := tok.TagName()
-			if string(name) != "a" || !hasAttr {
-				continue
-			}
-			for {
-				key, val, more := tok.TagAttr()
-				if string(key) == "href" {
-					link := strings.TrimSpace(string(val))
-						if link != "" {
-							if strings.HasPrefix(link, "//") {
-								link = baseURL.Scheme + ":" + link
-							}
-							parsedLink, err := url.Parse(link)
-							if err == nil {
-							resolved := baseURL.ResolveReference(parsedLink)
-							canonical, parsed, err := Canonicalize(resolved.String())
-							if err == nil && !e.deduper.Seen(canonical) {
-								host := HostKey(parsed)
-								task := &Task{URL: resolved.String(), Canonical: canonical, Host: host, Depth: res.Task.Depth + 1, SourceHost: res.Task.Host, DiscoveredAt: time.Now()}
-								select {
-								case e.enqueueCh <- task:
-								default:
-									select {
-									case e.enqueueCh <- task:
-									case <-e.ctx.Done():
-										return
-									}
-								}
-								if res.Task.Host != host && e.telemetry != nil {
-									select {
-									case e.telemetry.EdgeEvents() <- metrics.EdgeEvent{Src: res.Task.Host, Dst: host}:
-									default:
-									}
-								}
-								select {
-								case e.edgeWrites <- edgeRecord{runID: e.runID, src: res.Task.Host, dst: host, count: 1}:
-								default:
-								}
-								linksFound++
-								if e.cfg.MaxLinksPerPage > 0 && linksFound >= e.cfg.MaxLinksPerPage {
-									return
-								}
-							}
+	links, parseErr := extractLinks(baseURL, res.Body)
+	if parseErr != nil {
+		e.recordError(res.Task, ErrParse, parseErr.Error())
+	}
+	records := make([]storage.LinkRecord, 0, len(links))
+	linksFound, checksFound := 0, 0
+	// stopping is set once the engine shuts down mid-page; the remaining
+	// links are still recorded but no longer queued.
+	stopping := false
+	for _, link := range links {
+		canonical, parsed, err := Canonicalize(link.URL)
+		if err != nil {
+			continue
+		}
+		host := HostKey(parsed)
+		kind, inScope := e.linkKind(host)
+		if atMaxDepth && kind != TaskCheck {
+			continue
+		}
+		followed := false
+		if link.Navigate && !inScope && !e.deduper.Seen(canonical) {
+			skipped := &Task{URL: link.URL, Canonical: canonical, Host: host, Depth: res.Task.Depth + 1, Referrer: res.Task.URL}
+			e.traceDiscovered(skipped, false)
+			e.recordSkip(skipped, SkipOutOfScope, "outside scope "+e.cfg.Scope)
+		}
+		found := &linksFound
+		if kind == TaskCheck {
+			found = &checksFound
+		}
+		if link.Navigate && inScope && !stopping && (e.cfg.MaxLinksPerPage <= 0 || *found < e.cfg.MaxLinksPerPage) {
+			followed = true
+			if !e.deduper.Seen(canonical) {
+				task := &Task{Kind: kind, URL: link.URL, Canonical: canonical, Host: host, Depth: res.Task.Depth + 1, SourceHost: res.Task.Host, Referrer: res.Task.URL, DiscoveredAt: time.Now()}
+				e.traceDiscovered(task, true)
+				if e.pushTask(task) {
+					if res.Task.Host != host && e.telemetry != nil {
+						select {
+						case e.telemetry.EdgeEvents() <- metrics.EdgeEvent{Src: res.Task.Host, Dst: host}:
+						default:
 						}
 					}
-				}
-				if !more {
-					break
+					e.writer.send(writeEdge, storage.EdgeRecord{RunID: e.runID, Src: res.Task.Host, Dst: host, Count: 1})
+					*found++
+				} else {
+					stopping, followed = true, false
 				}
 			}
 		}
+		records = append(records, storage.LinkRecord{
+			RunID:      e.runID,
+			SrcURL:     res.Task.Canonical,
+			DstURL:     canonical,
+			AnchorText: link.Anchor,
+			Rel:        link.Rel,
+			Element:    link.Element,
+			Followed:   followed,
+		})
+	}
+	e.traceURL(res.Task, EventParsed, map[string]any{"links": len(links), "enqueued": linksFound, "checks": checksFound})
+	if len(records) > 0 {
+		e.writer.send(writeLink, records)
 	}
 }
 
-func (e *Engine) recordError(task *Task, class, message string) {
-	if e.telemetry != nil {
-		select {
-		case e.telemetry.FetchEvents() <- metrics.FetchEvent{Host: task.Host, ErrClass: class}:
-		default:
-		}
+// linkKind decides how a discovered link on host is handled: in-scope hosts
+// are crawled, out-of-scope hosts are checked in link-check mode and dropped
+// otherwise.
+func (e *Engine) linkKind(host string) (TaskKind, bool) {
+	if e.inScope(host) {
+		return TaskCrawl, true
 	}
-	select {
-	case e.errorWrites <- errorRecord{runID: e.runID, host: task.Host, url: task.URL, class: class, message: message}:
+	if e.cfg.Mode == ModeLinkCheck {
+		return TaskCheck, true
+	}
+	return TaskCrawl, false
+}
+
+func (e *Engine) inScope(host string) bool {
+	return InScope(e.cfg.Scope, e.seedHost, host)
+}
+
+// InScope reports whether host falls within scope for a run seeded on
+// seedHost. Both are host keys as returned by HostKey.
+func InScope(scope, seedHost, host string) bool {
+	switch scope {
+	case ScopeHost:
+		return host == seedHost
+	case ScopeDomain:
+		root := strings.TrimPrefix(seedHost, "www.")
+		return host == root || strings.HasSuffix(host, "."+root)
 	default:
+		return true
 	}
 }
 
-func (e *Engine) storageLoop() {
-	ctx := context.Background()
-	for {
+func (e *Engine) pushTask(task *Task) bool {
+	select {
+	case e.enqueueCh <- task:
+		return true
+	default:
 		select {
+		case e.enqueueCh <- task:
+			return true
 		case <-e.ctx.Done():
-			return
-		case rec := <-e.pageWrites:
-			if err := e.store.InsertPage(ctx, rec); err != nil {
-				log.Printf("store page: %v", err)
-			}
-		case rec := <-e.errorWrites:
-			if err := e.store.InsertError(ctx, rec.runID, rec.host, rec.url, rec.class, rec.message); err != nil {
-				log.Printf("store error: %v", err)
-			}
-		case rec := <-e.edgeWrites:
-			if err := e.store.UpsertEdge(ctx, rec.runID, rec.src, rec.dst, rec.count); err != nil {
-				log.Printf("store edge: %v", err)
-			}
+			return false
 		}
 	}
 }
 
-func (e *Engine) shouldRetry(task *Task, class string, retryAfter time.Duration) bool {
-	if task.Retries >= e.cfg.RetryMax {
-		return false
+func (e *Engine) handleExtract(res *FetchResult) {
+	if e.textIndex == nil {
+		return
 	}
-	if class == ErrStatus || class == ErrTimeout || class == ErrTLS || class == ErrDNS || class == ErrFetch {
-		task.Retries++
-		delay := e.cfg.RetryBaseDelay * time.Duration(1<<task.Retries)
-		if retryAfter > 0 {
-			delay = retryAfter
-		}
-		if delay > 30*time.Second {
-			delay = 30 * time.Second
+	e.textIndex.Add(res.Task.URL, search.Extract(res.Body))
+}
+
+func (e *Engine) recordError(task *Task, class, message string) {
+	if e.telemetry != nil {
+		select {
+		case e.telemetry.FetchEvents() <- metrics.FetchEvent{Host: task.Host, ErrClass: class}:
+		default:
 		}
-		task.NotBefore = time.Now().Add(delay)
+	}
+	e.writeError(task, class, message)
+}
+
+// recordSkip stores a URL the crawler decided not to fetch or expand, so a
+// missing page can be explained later. Skips do not count against the host.
+func (e *Engine) recordSkip(task *Task, reason, detail string) {
+	metrics.URLsSkipped.WithLabelValues(reason).Inc()
+	e.traceURL(task, EventSkipped, map[string]any{"reason": reason, "detail": detail})
+	if e.telemetry != nil {
 		select {
-		case e.enqueueCh <- task:
-			return true
+		case e.telemetry.SkipEvents() <- reason:
 		default:
-			select {
-			case e.enqueueCh <- task:
-				return true
-			case <-e.ctx.Done():
-				return false
-			}
 		}
 	}
-	return false
+	rec := storage.SkipRecord{
+		RunID:    e.runID,
+		URL:      task.URL,
+		Host:     task.Host,
+		Depth:    task.Depth,
+		Reason:   reason,
+		Detail:   detail,
+		Referrer: task.Referrer,
+		At:       time.Now(),
+	}
+	e.writer.send(writeSkip, rec)
 }
 
-func classifyError(err error) string {
-	if errors.Is(err, context.DeadlineExceeded) {
-		return ErrTimeout
+func (e *Engine) writeError(task *Task, class, message string) {
+	e.writer.send(writeError, storage.ErrorRecord{RunID: e.runID, Host: task.Host, URL: task.URL, Class: class, Message: message, At: time.Now()})
+}
+
+func (e *Engine) recordCircuitTrip(task *Task) {
+	metrics.FetchErrors.WithLabelValues(ErrCircuitOpen).Inc()
+	e.writeError(task, ErrCircuitOpen, "circuit opened after "+strconv.Itoa(e.cfg.CircuitTripCount)+" consecutive failures")
+}
+
+func (e *Engine) shouldRetry(task *Task, class string, retryAfter time.Duration) bool {
+	return Retryable(class) && e.retry(task, class, retryAfter)
+}
+
+// retry re-queues task with backoff while it has attempts left. Status
+// responses call it directly: whether a status is worth retrying depends on
+// the code, not on the status class.
+func (e *Engine) retry(task *Task, class string, retryAfter time.Duration) bool {
+	if task.Retries >= e.cfg.RetryMax {
+		return false
 	}
-	var netErr net.Error
-	if errors.As(err, &netErr) {
-		if netErr.Timeout() {
-			return ErrTimeout
-		}
+	detail := traceAttempt(task, map[string]any{"error_class": class})
+	task.Retries++
+	delay := e.cfg.RetryBaseDelay * time.Duration(1<<task.Retries)
+	if retryAfter > 0 {
+		delay = retryAfter
 	}
-	if strings.Contains(err.Error(), "tls") {
-		return ErrTLS
+	if delay > 30*time.Second {
+		delay = 30 * time.Second
 	}
-	if strings.Contains(err.Error(), "no such host") {
-		return ErrDNS
+	task.NotBefore = time.Now().Add(delay)
+	detail["delay_ms"] = delay.Milliseconds()
+	e.traceURL(task, EventRetry, detail)
+	select {
+	case e.enqueueCh <- task:
+		return true
+	default:
+		select {
+		case e.enqueueCh <- task:
+			return true
+		case <-e.ctx.Done():
+			return false
+		}
 	}
-	return ErrFetch
 }
 
 func readBodyLimited(r io.Reader, max int64) ([]byte, int64, string) {
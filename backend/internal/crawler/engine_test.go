@@ -1,9 +1,19 @@
 package crawler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
+
+	"webcrawler/internal/metrics"
+	"webcrawler/internal/search"
+	"webcrawler/internal/storage"
 )
 
 func TestReadBodyLimited(t *testing.T) {
@@ -26,4 +36,571 @@ func TestParseRetryAfter(t *testing.T) {
 	if d := parseRetryAfter("5"); d != 5*time.Second {
 		t.Fatalf("expected 5s, got %v", d)
 	}
-}
\ No newline at end of file
+}
+
+func TestRedirectChainsAndLoops(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/a">a</a><a href="/loop1">loop</a>`))
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/loop1":
+			http.Redirect(w, r, "/loop2", http.StatusFound)
+		case "/loop2":
+			http.Redirect(w, r, "/loop1", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("done"))
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeCrawl), store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	var chains []storage.RedirectChainRow
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		chains, _ = store.ListRedirectChains(ctx, runID, 0, 10)
+		if len(chains) >= 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	byOutcome := map[string]storage.RedirectChainRow{}
+	for _, c := range chains {
+		byOutcome[c.Outcome] = c
+	}
+	ok := byOutcome[RedirectOutcomeOK]
+	if ok.HopCount != 2 || ok.FinalStatus != 200 || ok.Hops[0].StatusCode != 301 || ok.Hops[1].StatusCode != 302 || !strings.HasSuffix(ok.FinalURL, "/c") {
+		t.Fatalf("unexpected chain: %+v", ok)
+	}
+	if loop := byOutcome[RedirectOutcomeLoop]; loop.HopCount != 2 || !strings.HasSuffix(loop.FinalURL, "/loop1") {
+		t.Fatalf("unexpected loop chain: %+v", chains)
+	}
+
+	var final, looped bool
+	var pages []storage.PageRow
+	for !(final && looped) && time.Now().Before(deadline) {
+		pages, _ = store.ListPages(ctx, runID, 50)
+		for _, p := range pages {
+			switch {
+			case strings.HasSuffix(p.URL, "/c"):
+				final = strings.HasSuffix(p.OriginURL, "/a") && len(p.RedirectChain) == 2
+			case strings.HasSuffix(p.URL, "/loop2"):
+				looped = p.ErrorClass == ErrRedirectLoop
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !final || !looped {
+		t.Fatalf("expected final page linked to origin and loop error, got %+v", pages)
+	}
+}
+
+func TestFetchTimingBreakdown(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeCrawl), store, metrics.NewTelemetry())
+	engine.Start(site.URL)
+
+	var pages []storage.PageRow
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pages) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		pages, _ = store.ListPages(ctx, runID, 10)
+	}
+	if len(pages) != 1 || pages[0].Timing.TTFBMS < 30 {
+		t.Fatalf("expected ttfb >= 30ms, got %+v", pages)
+	}
+
+	engine.Stop()
+	var stats []storage.HostStatRecord
+	for len(stats) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		stats, _ = store.ListHostStats(ctx, runID)
+	}
+	if len(stats) != 1 || stats[0].Requests != 1 || stats[0].TTFB.P50MS < 30 || stats[0].TTFB.P95MS < 30 {
+		t.Fatalf("unexpected host stats: %+v", stats)
+	}
+	var states []storage.HostStateRecord
+	for len(states) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		states, _ = store.ListHostStates(ctx, runID)
+	}
+	if len(states) != 1 || states[0].Host != stats[0].Host || states[0].CircuitState != string(CircuitClosed) {
+		t.Fatalf("unexpected host states: %+v", states)
+	}
+}
+
+func TestInspectHostRecordsTLSAndHeaders(t *testing.T) {
+	site := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=63072000")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	}))
+	defer site.Close()
+
+	for _, trusted := range []bool{true, false} {
+		store := storage.NewMemory()
+		ctx := context.Background()
+		runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+		cfg := testRunConfig(site.URL, ModeCrawl)
+		cfg.TLSHandshakeTimeout = time.Second
+		cfg.RetryMax = 0
+		engine := NewEngine(runID, cfg, store, nil)
+		if trusted {
+			engine.client.Transport = site.Client().Transport
+		}
+		engine.Start(site.URL)
+
+		var hosts []storage.HostSecurityRecord
+		deadline := time.Now().Add(5 * time.Second)
+		for len(hosts) == 0 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+			hosts, _ = store.ListHostSecurity(ctx, runID)
+		}
+		engine.Stop()
+		if len(hosts) != 1 {
+			t.Fatalf("trusted=%v: expected one host, got %+v", trusted, hosts)
+		}
+		h := hosts[0]
+		if h.CertVerified != trusted || h.TLSVersion == "" || h.CertNotAfter == nil || len(h.CertSANs) == 0 {
+			t.Fatalf("trusted=%v: unexpected inventory %+v", trusted, h)
+		}
+		if trusted && (h.HSTS != "max-age=63072000" || h.XFrameOptions != "DENY") {
+			t.Fatalf("expected security headers, got %+v", h)
+		}
+		if !trusted && h.CertError == "" {
+			t.Fatalf("expected verification error, got %+v", h)
+		}
+	}
+}
+
+func TestSkippedURLsAreRecorded(t *testing.T) {
+	other := httptest.NewServer(http.NotFoundHandler())
+	defer other.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/private/x">private</a><a href="/leaf">leaf</a><a href="` + other.URL + `/ext">ext</a>`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/deeper">deeper</a>`))
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 1
+	cfg.Scope = ScopeHost
+	cfg.RespectRobots = true
+	cfg.RobotsTTL = time.Minute
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	want := map[string]string{
+		SkipRobotsDenied: "/private/x",
+		SkipOutOfScope:   "/ext",
+		SkipMaxDepth:     "/leaf",
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var summary storage.RunSummary
+	for time.Now().Before(deadline) {
+		summary, _ = store.GetRunSummary(ctx, runID)
+		if len(summary.Skipped) == len(want) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for reason, suffix := range want {
+		items, _ := store.ListSkipped(ctx, runID, reason, 10)
+		if len(items) != 1 || !strings.HasSuffix(items[0].URL, suffix) || summary.Skipped[reason] != 1 {
+			t.Fatalf("expected one %s skip for %s, got %+v (summary %v)", reason, suffix, items, summary.Skipped)
+		}
+	}
+}
+
+func TestSchedulerReportsFrontierFull(t *testing.T) {
+	in := make(chan *Task)
+	out := make(chan *Task, 1)
+	s := NewScheduler(context.Background(), in, out, 1, NewSemaphore(1), 1, 5, time.Second, false, nil)
+	var reasons []string
+	s.SetDropHandler(func(task *Task, reason, detail string) {
+		reasons = append(reasons, reason)
+	})
+	s.enqueue(&Task{URL: "https://a.test/1", Host: "a.test"})
+	s.enqueue(&Task{URL: "https://a.test/2", Host: "a.test"})
+	if len(reasons) != 1 || reasons[0] != SkipFrontierFull {
+		t.Fatalf("expected one frontier_full drop, got %v", reasons)
+	}
+}
+
+func TestSchedulerSkipsTasksBehindOpenCircuitOnStop(t *testing.T) {
+	in := make(chan *Task)
+	out := make(chan *Task, 1)
+	s := NewScheduler(context.Background(), in, out, 0, NewSemaphore(4), 1, 1, time.Minute, false, nil)
+	var dropped []string
+	s.SetDropHandler(func(task *Task, reason, detail string) {
+		if reason != SkipCircuitOpen {
+			t.Errorf("unexpected drop reason %q", reason)
+		}
+		dropped = append(dropped, task.URL)
+	})
+	s.enqueue(&Task{URL: "https://a.test/1", Host: "a.test"})
+	s.enqueue(&Task{URL: "https://a.test/2", Host: "a.test"})
+	s.enqueue(&Task{URL: "https://b.test/1", Host: "b.test"})
+	s.HostState("a.test").OnResult(false)
+	s.schedule()
+	if len(dropped) != 0 {
+		t.Fatalf("deferred tasks were dropped before the stop: %v", dropped)
+	}
+
+	// b.test was dispatched; both a.test tasks wait behind the open circuit
+	s.abandonOpenCircuits()
+	if len(dropped) != 2 || s.FrontierSize() != 0 {
+		t.Fatalf("expected both a.test tasks skipped, got %v (frontier %d)", dropped, s.FrontierSize())
+	}
+}
+
+func TestURLEventsTraceLifecycle(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/old">old</a>`))
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`ok`))
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeCrawl), store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	seed, _, _ := Canonicalize(site.URL)
+	old, _, _ := Canonicalize(site.URL + "/old")
+	eventsFor := func(canonical string, last string) []string {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			events, _ := store.ListURLEvents(ctx, runID, canonical, 0)
+			names := make([]string, 0, len(events))
+			for _, ev := range events {
+				names = append(names, ev.Event)
+			}
+			if (len(names) > 0 && names[len(names)-1] == last) || time.Now().After(deadline) {
+				return names
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	got := strings.Join(eventsFor(seed, EventParsed), ",")
+	if want := "discovered,fetch,parsed"; got != want {
+		t.Fatalf("seed events = %s, want %s", got, want)
+	}
+	got = strings.Join(eventsFor(old, EventFetch), ",")
+	if want := "discovered,redirect,fetch"; got != want {
+		t.Fatalf("redirect events = %s, want %s", got, want)
+	}
+}
+
+func TestPagesRecordTitleAndContentHash(t *testing.T) {
+	leafBody := `<title>Leaf</title><a href="/deeper">deeper</a>`
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<title>Home</title><a href="/leaf">leaf</a><a href="/notes.txt">notes</a>`))
+		case "/leaf":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(leafBody))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 1
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	var pages []storage.PageRow
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		pages, _ = store.QueryPages(ctx, runID, storage.PageQuery{Sort: storage.PageSortURL})
+		if len(pages) == 3 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	byPath := map[string]storage.PageRow{}
+	for _, p := range pages {
+		byPath[strings.TrimPrefix(p.URL, site.URL)] = p
+	}
+	// the leaf sits at max depth, so its links are not parsed but its title is kept
+	if byPath["/leaf"].Title != "Leaf" || byPath["/"].Title != "Home" {
+		t.Fatalf("unexpected titles: %+v", pages)
+	}
+	// and its body is streamed rather than buffered, past the title
+	if sum := sha256.Sum256([]byte(leafBody)); byPath["/leaf"].ContentHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected streamed hash for the leaf: %+v", byPath["/leaf"])
+	}
+	// sha256("hello")
+	if notes := byPath["/notes.txt"]; notes.Title != "" || notes.ContentHash != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Fatalf("unexpected plain text page: %+v", notes)
+	}
+	if len(byPath["/"].ContentHash) != 64 || byPath["/"].ContentHash == byPath["/leaf"].ContentHash {
+		t.Fatalf("expected distinct hashes for the HTML pages: %+v", pages)
+	}
+}
+
+func TestPauseHoldsDispatchUntilResume(t *testing.T) {
+	seedServed := make(chan struct{})
+	release := make(chan struct{})
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			close(seedServed)
+			<-release
+			w.Write([]byte(`<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a>`))
+			return
+		}
+		w.Write([]byte("leaf"))
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeCrawl), store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	<-seedServed
+	if !engine.Pause() || engine.Pause() {
+		t.Fatalf("expected the first pause to succeed and the second to fail")
+	}
+	close(release)
+	// the seed was dispatched before the pause and finishes; its links wait
+	time.Sleep(200 * time.Millisecond)
+	if got := engine.PagesFetched(); got != 1 {
+		t.Fatalf("expected only the seed fetched while paused, got %d", got)
+	}
+	if !engine.Resume() || engine.Resume() {
+		t.Fatalf("expected the first resume to succeed and the second to fail")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.PagesFetched() < 4 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := engine.PagesFetched(); got != 4 {
+		t.Fatalf("expected 4 pages after resuming, got %d", got)
+	}
+}
+
+func TestBudgetTimerSkipsPausedTime(t *testing.T) {
+	fired := make(chan struct{})
+	b := newBudgetTimer(100*time.Millisecond, func() { close(fired) })
+	now := time.Now()
+	b.start(now)
+	b.pause(now.Add(60 * time.Millisecond))
+	select {
+	case <-fired:
+		t.Fatal("budget fired while paused")
+	case <-time.After(200 * time.Millisecond):
+	}
+	resumed := time.Now()
+	b.resume(resumed)
+	select {
+	case <-fired:
+		if waited := time.Since(resumed); waited > 150*time.Millisecond {
+			t.Fatalf("expected the remaining 40ms after resuming, waited %v", waited)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("budget never fired after resuming")
+	}
+}
+
+func TestSemaphoreResize(t *testing.T) {
+	sem := NewSemaphore(2)
+	if !sem.TryAcquire() || !sem.TryAcquire() || sem.TryAcquire() {
+		t.Fatal("expected exactly two permits")
+	}
+	sem.Resize(1)
+	sem.Release()
+	// one permit is still held, which is the new size
+	if sem.TryAcquire() {
+		t.Fatal("expected no permit after shrinking below the permits held")
+	}
+	sem.Resize(3)
+	if !sem.TryAcquire() || !sem.TryAcquire() || sem.TryAcquire() {
+		t.Fatalf("expected two more permits after growing, inflight %d", sem.Inflight())
+	}
+}
+
+func TestReconfigureLiveRun(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a>`))
+			return
+		}
+		w.Write([]byte("leaf"))
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: site.URL})
+	engine := NewEngine(runID, testRunConfig(site.URL, ModeCrawl), store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	workers := func() (int, int) {
+		engine.fetchPool.mu.Lock()
+		defer engine.fetchPool.mu.Unlock()
+		engine.parsePool.mu.Lock()
+		defer engine.parsePool.mu.Unlock()
+		return engine.fetchPool.running, engine.parsePool.running
+	}
+	global, perHost := 8, 1
+	cfg, ok := engine.Reconfigure(ConfigPatch{GlobalConcurrency: &global, PerHostConcurrency: &perHost, HostConcurrency: map[string]int{"Other.Test": 3}})
+	if !ok || cfg.GlobalConcurrency != 8 || cfg.PerHostConcurrency != 1 || cfg.HostConcurrency["other.test"] != 3 || engine.Config().GlobalConcurrency != 8 {
+		t.Fatalf("unexpected config after reconfiguring: %+v", cfg)
+	}
+	if fetch, parse := workers(); engine.globalSem.Capacity() != 8 || fetch != 8 || parse != 4 {
+		t.Fatalf("expected 8 permits, 8 fetch and 4 parse workers, got %d, %d and %d", engine.globalSem.Capacity(), fetch, parse)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.PagesFetched() < 4 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	parsed, _ := url.Parse(site.URL)
+	if hs := engine.scheduler.HostState(HostKey(parsed)); hs == nil || hs.Semaphore.Capacity() != 1 {
+		t.Fatalf("expected the site host limited to 1, got %+v", hs)
+	}
+
+	// idle workers above the new size exit
+	global = 1
+	engine.Reconfigure(ConfigPatch{GlobalConcurrency: &global})
+	for time.Now().Before(deadline) {
+		if fetch, parse := workers(); fetch == 4 && parse == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fetch, parse := workers(); fetch != 4 || parse != 2 {
+		t.Fatalf("expected the pools to shrink to 4 and 2, got %d and %d", fetch, parse)
+	}
+
+	maxPages := 2
+	engine.Reconfigure(ConfigPatch{MaxPages: &maxPages})
+	select {
+	case <-engine.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected lowering max pages below the pages fetched to stop the run")
+	}
+	if engine.StopReason() != StopReasonMaxPages {
+		t.Fatalf("expected stop reason max_pages, got %q", engine.StopReason())
+	}
+	if _, ok := engine.Reconfigure(ConfigPatch{MaxPages: &maxPages}); ok {
+		t.Fatal("expected a stopped engine to refuse changes")
+	}
+}
+
+func TestBudgetTimerSetBudget(t *testing.T) {
+	fired := make(chan struct{}, 1)
+	b := newBudgetTimer(time.Hour, func() { fired <- struct{}{} })
+	start := time.Now()
+	b.start(start)
+	// the time already run counts against the new budget
+	b.setBudget(30*time.Second, start.Add(30*time.Second))
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("expected a spent budget to fire at once")
+	}
+	b.setBudget(0, time.Now())
+	select {
+	case <-fired:
+		t.Fatal("expected a removed budget not to fire")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMaxDepthPagesAreIndexedWithoutParsing(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<p>home page text</p><a href="/deep">deep</a>`))
+		default:
+			w.Write([]byte(`<p>basement page text</p><a href="/deeper">deeper</a>`))
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 1
+	engine := NewEngine(runID, cfg, store, nil)
+	idx := search.NewIndex()
+	engine.SetTextIndex(idx)
+	engine.Start(site.URL)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for idx.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	engine.Stop()
+	<-engine.Drained()
+	if hits, _ := idx.Search("basement", 5); len(hits) != 1 {
+		t.Fatalf("expected the max-depth page indexed, got %d hits", len(hits))
+	}
+	deep, _, _ := Canonicalize(site.URL + "/deep")
+	events, _ := store.ListURLEvents(ctx, runID, deep, 0)
+	for _, ev := range events {
+		if ev.Event == EventParsed {
+			t.Fatal("max-depth page went through link parsing")
+		}
+	}
+}
@@ -1,17 +1,189 @@
 package crawler
 
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	ErrTimeout              = "timeout"
+	ErrConnectTimeout       = "connect_timeout"
+	ErrConnRefused          = "conn_refused"
+	ErrConnReset            = "conn_reset"
+	ErrDNS                  = "dns"
+	ErrDNSNXDomain          = "dns_nxdomain"
+	ErrDNSTimeout           = "dns_timeout"
+	ErrTLS                  = "tls"
+	ErrCertExpired          = "cert_expired"
+	ErrCertUnknownAuthority = "cert_unknown_authority"
+	ErrCertHostname         = "cert_hostname"
+	ErrCertInvalid          = "cert_invalid"
+	ErrHTTP2Protocol        = "http2_protocol"
+	ErrHTTP                 = "http"
+	ErrStatus               = "status"
+	ErrSizeLimit            = "size_limit"
+	ErrParse                = "parse"
+	ErrUnsupported          = "unsupported"
+	ErrRobotsDenied         = "robots_denied"
+	ErrCircuitOpen          = "circuit_open"
+	ErrMaxDepth             = "max_depth"
+	ErrMaxPages             = "max_pages"
+	ErrFetch                = "fetch"
+	ErrRedirectLoop         = "redirect_loop"
+	ErrRedirectLimit        = "redirect_limit"
+)
+
+// Skip reasons explain URLs the crawler discovered but did not fetch or
+// did not expand.
 const (
-	ErrTimeout       = "timeout"
-	ErrDNS           = "dns"
-	ErrTLS           = "tls"
-	ErrHTTP          = "http"
-	ErrStatus        = "status"
-	ErrSizeLimit     = "size_limit"
-	ErrParse         = "parse"
-	ErrUnsupported   = "unsupported"
-	ErrRobotsDenied  = "robots_denied"
-	ErrCircuitOpen   = "circuit_open"
-	ErrMaxDepth      = "max_depth"
-	ErrMaxPages      = "max_pages"
-	ErrFetch         = "fetch"
-)
\ No newline at end of file
+	SkipRobotsDenied = ErrRobotsDenied
+	SkipMaxDepth     = ErrMaxDepth
+	SkipFrontierFull = "frontier_full"
+	SkipParseDropped = "parse_dropped"
+	SkipOutOfScope   = "out_of_scope"
+	SkipCircuitOpen  = ErrCircuitOpen
+)
+
+// ErrorClass describes how the engine treats a class. Retryable classes are
+// re-queued with backoff by shouldRetry; Unreachable classes mean the URL
+// could not be fetched at all and count as broken links. ErrStatus has
+// neither: a response did arrive, and whether it is retried (429, 5xx) or
+// broken (>= 400) is decided from its status code.
+type ErrorClass struct {
+	Retryable   bool
+	Unreachable bool
+}
+
+var errorClasses = map[string]ErrorClass{
+	ErrTimeout:              {Retryable: true, Unreachable: true},
+	ErrConnectTimeout:       {Retryable: true, Unreachable: true},
+	ErrConnRefused:          {Retryable: true, Unreachable: true},
+	ErrConnReset:            {Retryable: true, Unreachable: true},
+	ErrDNS:                  {Retryable: true, Unreachable: true},
+	ErrDNSNXDomain:          {Unreachable: true},
+	ErrDNSTimeout:           {Retryable: true, Unreachable: true},
+	ErrTLS:                  {Unreachable: true},
+	ErrCertExpired:          {Unreachable: true},
+	ErrCertUnknownAuthority: {Unreachable: true},
+	ErrCertHostname:         {Unreachable: true},
+	ErrCertInvalid:          {Unreachable: true},
+	ErrHTTP2Protocol:        {Retryable: true, Unreachable: true},
+	ErrHTTP:                 {Unreachable: true},
+	ErrStatus:               {},
+	ErrFetch:                {Retryable: true, Unreachable: true},
+	ErrSizeLimit:            {},
+	ErrParse:                {},
+	ErrUnsupported:          {},
+	ErrRobotsDenied:         {},
+	ErrCircuitOpen:          {},
+	ErrMaxDepth:             {},
+	ErrMaxPages:             {},
+	ErrRedirectLoop:         {},
+	ErrRedirectLimit:        {},
+}
+
+func Retryable(class string) bool {
+	return errorClasses[class].Retryable
+}
+
+func Unreachable(class string) bool {
+	return errorClasses[class].Unreachable
+}
+
+func tlsClass(class string) bool {
+	switch class {
+	case ErrTLS, ErrCertExpired, ErrCertUnknownAuthority, ErrCertHostname, ErrCertInvalid:
+		return true
+	}
+	return false
+}
+
+// classifyError maps a transport error to a class by inspecting the typed
+// errors wrapped inside it. Certificate errors are checked before timeouts
+// because a handshake that fails verification is not worth retrying.
+func classifyError(err error) string {
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return ErrCertUnknownAuthority
+	}
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) {
+		if invalid.Reason == x509.Expired {
+			return ErrCertExpired
+		}
+		return ErrCertInvalid
+	}
+	var hostname x509.HostnameError
+	if errors.As(err, &hostname) {
+		return ErrCertHostname
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return ErrDNSNXDomain
+		case dnsErr.IsTimeout:
+			return ErrDNSTimeout
+		}
+		return ErrDNS
+	}
+
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return ErrHTTP2Protocol
+	}
+	var goAway http2.GoAwayError
+	if errors.As(err, &goAway) {
+		return ErrHTTP2Protocol
+	}
+	var connErr http2.ConnectionError
+	if errors.As(err, &connErr) {
+		return ErrHTTP2Protocol
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		switch {
+		case errors.Is(opErr, syscall.ECONNREFUSED):
+			return ErrConnRefused
+		case errors.Is(opErr, syscall.ECONNRESET), errors.Is(opErr, syscall.EPIPE):
+			return ErrConnReset
+		case opErr.Op == "dial" && opErr.Timeout():
+			return ErrConnectTimeout
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return ErrTLS
+	}
+	var alertErr tls.AlertError
+	if errors.As(err, &alertErr) {
+		return ErrTLS
+	}
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return ErrCertInvalid
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrConnReset
+	}
+	return ErrFetch
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com/", Err: err}
+	}
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{wrap(&net.DNSError{Err: "no such host", Name: "nope.test", IsNotFound: true}), ErrDNSNXDomain},
+		{wrap(&net.DNSError{Err: "i/o timeout", Name: "slow.test", IsTimeout: true}), ErrDNSTimeout},
+		{wrap(&net.DNSError{Err: "server misbehaving", Name: "bad.test"}), ErrDNS},
+		{wrap(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), ErrConnRefused},
+		{wrap(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), ErrConnReset},
+		{wrap(fmt.Errorf("tls: failed to verify certificate: %w", x509.UnknownAuthorityError{})), ErrCertUnknownAuthority},
+		{wrap(fmt.Errorf("tls: failed to verify certificate: %w", x509.CertificateInvalidError{Reason: x509.Expired})), ErrCertExpired},
+		{wrap(fmt.Errorf("tls: failed to verify certificate: %w", x509.HostnameError{Host: "a.test", Certificate: &x509.Certificate{}})), ErrCertHostname},
+		{wrap(context.DeadlineExceeded), ErrTimeout},
+		{wrap(io.ErrUnexpectedEOF), ErrConnReset},
+		{wrap(errors.New("something else")), ErrFetch},
+	}
+	for _, tc := range cases {
+		if got := classifyError(tc.err); got != tc.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyConnRefused(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+	_, err := http.Get(addr)
+	if err == nil {
+		t.Fatal("expected error from closed server")
+	}
+	if got := classifyError(err); got != ErrConnRefused {
+		t.Fatalf("expected %s, got %s (%v)", ErrConnRefused, got, err)
+	}
+}
+
+// The engine's transport must surface HTTP/2 stream resets as the x/net
+// error types; the copy bundled in net/http returns its own.
+func TestClassifyHTTP2StreamReset(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler) // answered with RST_STREAM over HTTP/2
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport := buildTransport(testRunConfig(srv.URL, ModeCrawl))
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	transport.TLSClientConfig.RootCAs = roots
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the reset stream to fail the request")
+	}
+	if !strings.Contains(err.Error(), "stream error") {
+		t.Fatalf("expected an HTTP/2 stream error, got %v", err)
+	}
+	if got := classifyError(err); got != ErrHTTP2Protocol {
+		t.Fatalf("expected %s, got %s (%v)", ErrHTTP2Protocol, got, err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, class := range []string{ErrTimeout, ErrConnReset, ErrDNSTimeout, ErrHTTP2Protocol} {
+		if !Retryable(class) {
+			t.Errorf("%s should be retryable", class)
+		}
+	}
+	for _, class := range []string{ErrDNSNXDomain, ErrCertExpired, ErrCertUnknownAuthority, ErrRobotsDenied, ErrSizeLimit, ErrStatus, "unknown"} {
+		if Retryable(class) {
+			t.Errorf("%s should not be retryable", class)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"time"
+
+	"webcrawler/internal/storage"
+)
+
+// URL lifecycle events. Each is stored against the canonical URL so a
+// single URL's history can be replayed by the explain endpoint. Scheduling
+// is folded into the fetch and retry events: a typical URL costs three rows,
+// discovered, fetch and parsed.
+const (
+	EventDiscovered = "discovered"
+	EventFetch      = "fetch"
+	EventRetry      = "retry"
+	EventRedirect   = "redirect"
+	EventParsed     = "parsed"
+	EventSkipped    = "skipped"
+	EventDeferred   = "deferred"
+)
+
+func (e *Engine) traceURL(task *Task, event string, detail map[string]any) {
+	if task.Canonical == "" {
+		return
+	}
+	rec := storage.URLEvent{
+		RunID:  e.runID,
+		URL:    task.Canonical,
+		Event:  event,
+		Detail: detail,
+		At:     time.Now(),
+	}
+	e.writer.send(writeEvent, rec)
+}
+
+func (e *Engine) traceDiscovered(task *Task, inScope bool) {
+	e.traceURL(task, EventDiscovered, map[string]any{
+		"url":      task.URL,
+		"source":   task.Referrer,
+		"depth":    task.Depth,
+		"in_scope": inScope,
+		"scope":    e.cfg.Scope,
+	})
+}
+
+// traceAttempt adds how the attempt was scheduled to a fetch or retry event.
+func traceAttempt(task *Task, detail map[string]any) map[string]any {
+	detail["attempt"] = task.Retries + 1
+	detail["queued_ms"] = task.queuedMS
+	if task.robotsChecked {
+		detail["robots"] = "allowed"
+	} else {
+		detail["robots"] = "not_checked"
+	}
+	return detail
+}
+
+func (e *Engine) traceRedirect(task *Task, res *FetchResult, target, outcome string) {
+	e.traceURL(task, EventRedirect, map[string]any{
+		"status_code": res.StatusCode,
+		"location":    res.RedirectURL,
+		"target":      target,
+		"outcome":     outcome,
+		"hop":         len(task.Redirects) + 1,
+	})
+}
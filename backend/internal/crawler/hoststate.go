@@ -54,7 +54,9 @@ func (h *HostState) Allow() bool {
 	}
 }
 
-func (h *HostState) OnResult(success bool) {
+// OnResult updates the breaker and reports whether this result tripped it
+// open.
+func (h *HostState) OnResult(success bool) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	if success {
@@ -62,14 +64,17 @@ func (h *HostState) OnResult(success bool) {
 		if h.Circuit != CircuitClosed {
 			h.Circuit = CircuitClosed
 		}
-		return
+		return false
 	}
 	h.ErrCount++
 	h.LastFail = time.Now()
 	if h.ErrCount >= h.TripCount {
+		tripped := h.Circuit != CircuitOpen
 		h.Circuit = CircuitOpen
 		h.OpenedAt = time.Now()
+		return tripped
 	}
+	return false
 }
 
 func (h *HostState) State() CircuitState {
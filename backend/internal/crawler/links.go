@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	maxAnchorText = 256
+	maxTitle      = 512
+)
+
+type Link struct {
+	URL      string
+	Anchor   string
+	Rel      string
+	Element  string
+	Navigate bool
+}
+
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+}
+
+// extractLinks tokenizes an HTML body and returns every link-bearing element
+// resolved against base. Only a and area links are navigational; the rest are
+// recorded for the link graph but never crawled.
+func extractLinks(base *url.URL, body []byte) ([]Link, error) {
+	tok := html.NewTokenizer(bytes.NewReader(body))
+	var links []Link
+	var open *Link
+	var anchor strings.Builder
+	var altText string
+
+	closeAnchor := func() {
+		if open == nil {
+			return
+		}
+		text := collapseSpace(anchor.String())
+		if text == "" {
+			text = altText
+		}
+		open.Anchor = truncate(text, maxAnchorText)
+		links = append(links, *open)
+		open = nil
+		anchor.Reset()
+		altText = ""
+	}
+
+	for {
+		tt := tok.Next()
+		switch tt {
+		case html.ErrorToken:
+			closeAnchor()
+			if tok.Err() != io.EOF {
+				return links, tok.Err()
+			}
+			return links, nil
+		case html.TextToken:
+			if open != nil && anchor.Len() < maxAnchorText*4 {
+				anchor.Write(tok.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tok.TagName()
+			if string(name) == "a" {
+				closeAnchor()
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tok.TagName()
+			tag := string(name)
+			attrName, ok := linkAttrs[tag]
+			if !ok || !hasAttr {
+				continue
+			}
+			attrs := readAttrs(tok)
+			if tag == "img" && open != nil && altText == "" {
+				altText = collapseSpace(attrs["alt"])
+			}
+			resolved, ok := resolveLink(base, attrs[attrName])
+			if !ok {
+				continue
+			}
+			link := Link{URL: resolved, Rel: strings.ToLower(collapseSpace(attrs["rel"])), Element: tag}
+			switch tag {
+			case "a":
+				closeAnchor()
+				link.Navigate = true
+				if tt == html.SelfClosingTagToken {
+					links = append(links, link)
+					continue
+				}
+				open = &link
+			case "area":
+				link.Navigate = true
+				link.Anchor = truncate(collapseSpace(attrs["alt"]), maxAnchorText)
+				links = append(links, link)
+			default:
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+// extractTitle returns the text of the first <title>, stopping at </head> or
+// <body> so a page without one is not read to the end.
+func extractTitle(r io.Reader) string {
+	tok := html.NewTokenizer(r)
+	var title strings.Builder
+	inTitle := false
+	for {
+		switch tok.Next() {
+		case html.ErrorToken:
+			return truncate(collapseSpace(title.String()), maxTitle)
+		case html.TextToken:
+			if inTitle && title.Len() < maxTitle*4 {
+				title.Write(tok.Text())
+			}
+		case html.StartTagToken:
+			name, _ := tok.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				return truncate(collapseSpace(title.String()), maxTitle)
+			}
+		case html.EndTagToken:
+			if name, _ := tok.TagName(); string(name) == "title" || string(name) == "head" {
+				return truncate(collapseSpace(title.String()), maxTitle)
+			}
+		}
+	}
+}
+
+func readAttrs(tok *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string, 4)
+	for {
+		key, val, more := tok.TagAttr()
+		k := string(key)
+		if _, exists := attrs[k]; !exists {
+			attrs[k] = string(val)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+func resolveLink(base *url.URL, raw string) (string, bool) {
+	link := strings.TrimSpace(raw)
+	if link == "" {
+		return "", false
+	}
+	if strings.HasPrefix(link, "//") {
+		link = base.Scheme + ":" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	resolved := base.ResolveReference(parsed)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return "", false
+	}
+	return resolved.String(), true
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
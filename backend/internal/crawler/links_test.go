@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/")
+	body := `<html><head><link rel="stylesheet" href="/site.css"></head><body>
+	<a href="intro">  Getting
+	started </a>
+	<a href="//cdn.example.com/x" rel="nofollow"><img src="/logo.png" alt="Logo"></a>
+	<a href="mailto:hi@example.com">mail</a>
+	<map><area href="/area" alt="Region"></map>
+	<script src="/app.js"></script>
+	</body></html>`
+	links, err := extractLinks(base, []byte(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Link{
+		{URL: "https://example.com/site.css", Rel: "stylesheet", Element: "link"},
+		{URL: "https://example.com/docs/intro", Anchor: "Getting started", Element: "a", Navigate: true},
+		{URL: "https://example.com/logo.png", Element: "img"},
+		{URL: "https://cdn.example.com/x", Anchor: "Logo", Rel: "nofollow", Element: "a", Navigate: true},
+		{URL: "https://example.com/area", Anchor: "Region", Element: "area", Navigate: true},
+		{URL: "https://example.com/app.js", Element: "script"},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i := range want {
+		if links[i] != want[i] {
+			t.Fatalf("link %d = %+v, want %+v", i, links[i], want[i])
+		}
+	}
+}
+
+func TestExtractTitle(t *testing.T) {
+	cases := map[string]string{
+		`<html><head><title>  Release
+		notes &amp; fixes </title></head><body><title>ignored</title></body></html>`: "Release notes & fixes",
+		`<html><body><h1>No title</h1><title>late</title></body></html>`: "",
+		`<title>unterminated`: "unterminated",
+		`<head><meta charset="utf-8"></head><title>after head</title>`: "",
+	}
+	for body, want := range cases {
+		if got := extractTitle(strings.NewReader(body)); got != want {
+			t.Fatalf("extractTitle(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
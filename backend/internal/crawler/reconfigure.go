@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ConfigPatch lists the settings that can change while a run is going. Nil
+// fields are left as they are; a non-nil HostConcurrency replaces the
+// per-host overrides, so an empty map clears them.
+type ConfigPatch struct {
+	GlobalConcurrency  *int
+	PerHostConcurrency *int
+	HostConcurrency    map[string]int
+	TimeBudget         *time.Duration
+	MaxPages           *int
+}
+
+func (p ConfigPatch) Empty() bool {
+	return p.GlobalConcurrency == nil && p.PerHostConcurrency == nil && p.HostConcurrency == nil && p.TimeBudget == nil && p.MaxPages == nil
+}
+
+// Validate rejects patches that would leave the engine without a limit to
+// apply. Zero keeps its RunConfig meaning for the time budget and MaxPages.
+func (p ConfigPatch) Validate() error {
+	if p.Empty() {
+		return errors.New("nothing to change")
+	}
+	if p.GlobalConcurrency != nil && *p.GlobalConcurrency <= 0 {
+		return errors.New("global_concurrency must be positive")
+	}
+	if p.PerHostConcurrency != nil && *p.PerHostConcurrency <= 0 {
+		return errors.New("per_host_concurrency must be positive")
+	}
+	if p.TimeBudget != nil && *p.TimeBudget < 0 {
+		return errors.New("time_budget must be non-negative")
+	}
+	if p.MaxPages != nil && *p.MaxPages < 0 {
+		return errors.New("max_pages must be non-negative")
+	}
+	for host, n := range p.HostConcurrency {
+		if host == "" || n <= 0 {
+			return errors.New("host_concurrency needs host names with positive limits")
+		}
+	}
+	return nil
+}
+
+// Apply returns c with the patch applied.
+func (c RunConfig) Apply(p ConfigPatch) RunConfig {
+	if p.GlobalConcurrency != nil {
+		c.GlobalConcurrency = *p.GlobalConcurrency
+	}
+	if p.PerHostConcurrency != nil {
+		c.PerHostConcurrency = *p.PerHostConcurrency
+	}
+	if p.HostConcurrency != nil {
+		c.HostConcurrency = p.HostConcurrency
+		if len(c.HostConcurrency) == 0 {
+			c.HostConcurrency = nil
+		}
+	}
+	if p.TimeBudget != nil {
+		c.TimeBudget = *p.TimeBudget
+		c.TimeBudgetSeconds = int(p.TimeBudget.Seconds())
+	}
+	if p.MaxPages != nil {
+		c.MaxPages = *p.MaxPages
+	}
+	return c.Normalize()
+}
+
+// Config returns the configuration the engine is running with, including
+// changes made by Reconfigure.
+func (e *Engine) Config() RunConfig {
+	e.liveMu.Lock()
+	defer e.liveMu.Unlock()
+	return e.live
+}
+
+// Reconfigure applies p to the running engine and returns the resulting
+// configuration. It reports false once the engine has stopped.
+//
+// Concurrency changes resize the semaphores and scale the fetch and parse
+// worker pools; permits held above a lowered limit drain as their fetches
+// finish. The frontier and queue sizes and the HTTP transport keep the
+// sizes they were given at start. A time budget or MaxPages already used up
+// stops the run.
+func (e *Engine) Reconfigure(p ConfigPatch) (RunConfig, bool) {
+	e.liveMu.Lock()
+	defer e.liveMu.Unlock()
+	if e.ctx.Err() != nil {
+		return e.live, false
+	}
+	cfg := e.live.Apply(p)
+	if p.GlobalConcurrency != nil {
+		e.globalSem.Resize(cfg.GlobalConcurrency)
+		fetch, parse := workerCounts(cfg)
+		e.fetchPool.resize(fetch)
+		e.parsePool.resize(parse)
+	}
+	if p.PerHostConcurrency != nil || p.HostConcurrency != nil {
+		e.scheduler.SetHostLimits(cfg.PerHostConcurrency, cfg.HostConcurrency)
+	}
+	if p.TimeBudget != nil {
+		e.budget.setBudget(cfg.TimeBudget, time.Now())
+	}
+	if p.MaxPages != nil {
+		e.maxPages.Store(int64(cfg.MaxPages))
+		if e.maxPagesReached() {
+			e.StopWithReason(StopReasonMaxPages)
+		}
+	}
+	e.live = cfg
+	return cfg, true
+}
+
+func (e *Engine) maxPagesReached() bool {
+	limit := e.maxPages.Load()
+	return limit > 0 && e.pagesFetched.Load() >= limit
+}
+
+// workerCounts sizes the fetch and parse pools for a configuration.
+func workerCounts(cfg RunConfig) (fetch, parse int) {
+	fetch = max(4, cfg.GlobalConcurrency)
+	if cfg.Mode == ModeLinkCheck {
+		fetch += cfg.ExternalConcurrency
+	}
+	return fetch, max(2, cfg.GlobalConcurrency/2)
+}
+
+// workerPool keeps a resizable number of copies of a worker loop running.
+// Shrinking is cooperative: surplus workers exit when they next call retire,
+// and idle workers are woken through shrunk to do so.
+type workerPool struct {
+	mu      sync.Mutex
+	want    int
+	running int
+	spawn   func()
+	wake    chan struct{}
+	closed  bool
+}
+
+func newWorkerPool(spawn func()) *workerPool {
+	return &workerPool{spawn: spawn, wake: make(chan struct{})}
+}
+
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.want = n
+	for p.running < p.want && !p.closed {
+		p.running++
+		p.spawn()
+	}
+	if p.running > p.want {
+		close(p.wake)
+		p.wake = make(chan struct{})
+	}
+}
+
+// close stops the pool from starting workers, so the engine can wait for
+// the ones it has.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
+
+// retire reports whether the calling worker should exit because the pool
+// shrank.
+func (p *workerPool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running > p.want {
+		p.running--
+		return true
+	}
+	return false
+}
+
+// shrunk is closed the next time the pool shrinks.
+func (p *workerPool) shrunk() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.wake
+}
@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RunConfigVersion tags stored run configurations. Bump it when a field
+// changes meaning and teach DecodeRunConfig to upgrade the older form.
+const RunConfigVersion = 1
+
+const (
+	maxRetryMax       = 10
+	maxWriteBatchSize = 10000
+)
+
+// EncodeRunConfig serializes the complete configuration for storage.
+// Durations are kept in nanoseconds so decoding restores them exactly.
+func EncodeRunConfig(c RunConfig) ([]byte, int, error) {
+	data, err := json.Marshal(c)
+	return data, RunConfigVersion, err
+}
+
+func DecodeRunConfig(data []byte, version int) (RunConfig, error) {
+	if version < 1 || version > RunConfigVersion {
+		return RunConfig{}, fmt.Errorf("unsupported run config version %d", version)
+	}
+	var c RunConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return RunConfig{}, fmt.Errorf("decode run config: %w", err)
+	}
+	return c, nil
+}
+
+// Validate rejects configurations the engine cannot run as given. Zero values
+// are allowed everywhere they mean "use the default".
+func (c RunConfig) Validate() error {
+	if c.SeedURL == "" {
+		return errors.New("seed_url required")
+	}
+	if _, _, err := Canonicalize(c.SeedURL); err != nil {
+		return errors.New("invalid seed_url")
+	}
+	switch c.Mode {
+	case "", ModeCrawl, ModeLinkCheck:
+	default:
+		return errors.New("mode must be crawl or link_check")
+	}
+	switch c.Scope {
+	case "", ScopeAll, ScopeHost, ScopeDomain:
+	default:
+		return errors.New("scope must be all, host or domain")
+	}
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"time_budget", c.TimeBudget},
+		{"request_timeout", c.RequestTimeout},
+		{"header_timeout", c.HeaderTimeout},
+		{"tls_handshake_timeout", c.TLSHandshakeTimeout},
+		{"idle_conn_timeout", c.IdleConnTimeout},
+		{"robots_ttl", c.RobotsTTL},
+		{"retry_base_delay", c.RetryBaseDelay},
+		{"circuit_reset_time", c.CircuitResetTime},
+		{"write_flush_interval", c.WriteFlushInterval},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			return fmt.Errorf("%s must be non-negative", d.name)
+		}
+	}
+	if c.MaxBodyBytes < 0 {
+		return errors.New("max_body_bytes must be non-negative")
+	}
+	if c.RetryMax < 0 || c.RetryMax > maxRetryMax {
+		return fmt.Errorf("retry_max must be 0-%d", maxRetryMax)
+	}
+	if c.CircuitTripCount < 0 {
+		return errors.New("circuit_trip_count must be non-negative")
+	}
+	for host, n := range c.HostConcurrency {
+		if host == "" || n <= 0 {
+			return errors.New("host_concurrency needs host names with positive limits")
+		}
+	}
+	if c.WriteBatchSize < 0 || c.WriteBatchSize > maxWriteBatchSize {
+		return fmt.Errorf("write_batch_size must be 0-%d", maxWriteBatchSize)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunConfigRoundTrip(t *testing.T) {
+	cfg := RunConfig{
+		SeedURL:                    "https://example.com/",
+		MaxDepth:                   3,
+		MaxPages:                   500,
+		TimeBudget:                 90 * time.Second,
+		TimeBudgetSeconds:          90,
+		MaxLinksPerPage:            40,
+		GlobalConcurrency:          16,
+		PerHostConcurrency:         2,
+		HostConcurrency:            map[string]int{"slow.example.com": 1},
+		UserAgent:                  "test-agent",
+		RespectRobots:              true,
+		RequestTimeout:             7500 * time.Millisecond,
+		HeaderTimeout:              3 * time.Second,
+		TLSHandshakeTimeout:        4 * time.Second,
+		IdleConnTimeout:            time.Minute,
+		MaxBodyBytes:               2 << 20,
+		RobotsTTL:                  time.Hour,
+		RetryMax:                   4,
+		RetryBaseDelay:             150 * time.Millisecond,
+		CircuitTripCount:           6,
+		CircuitResetTime:           45 * time.Second,
+		ExtractText:                true,
+		Mode:                       ModeLinkCheck,
+		Scope:                      ScopeDomain,
+		ExternalConcurrency:        5,
+		ExternalPerHostConcurrency: 1,
+		MaxRedirects:               7,
+		LosslessWrites:             true,
+		WriteBatchSize:             250,
+		WriteFlushInterval:         100 * time.Millisecond,
+	}
+	data, version, err := EncodeRunConfig(cfg)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := DecodeRunConfig(data, version)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Fatalf("round trip changed config:\n got %+v\nwant %+v", got, cfg)
+	}
+	if _, err := DecodeRunConfig(data, RunConfigVersion+1); err == nil {
+		t.Fatal("expected error for a newer config version")
+	}
+}
+
+func TestRunConfigValidate(t *testing.T) {
+	base := RunConfig{SeedURL: "https://example.com/"}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("minimal config rejected: %v", err)
+	}
+	cases := map[string]func(*RunConfig){
+		"missing seed":      func(c *RunConfig) { c.SeedURL = "" },
+		"bad seed":          func(c *RunConfig) { c.SeedURL = "ftp://example.com/" },
+		"bad mode":          func(c *RunConfig) { c.Mode = "mirror" },
+		"bad scope":         func(c *RunConfig) { c.Scope = "planet" },
+		"negative timeout":  func(c *RunConfig) { c.RequestTimeout = -time.Second },
+		"negative body":     func(c *RunConfig) { c.MaxBodyBytes = -1 },
+		"too many retries":  func(c *RunConfig) { c.RetryMax = maxRetryMax + 1 },
+		"negative circuit":  func(c *RunConfig) { c.CircuitTripCount = -1 },
+		"huge write batch":  func(c *RunConfig) { c.WriteBatchSize = maxWriteBatchSize + 1 },
+		"negative robotttl": func(c *RunConfig) { c.RobotsTTL = -time.Minute },
+		"zero host limit":   func(c *RunConfig) { c.HostConcurrency = map[string]int{"a.test": 0} },
+	}
+	for name, mutate := range cases {
+		cfg := base
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package crawler
 import (
 	"context"
 	"net/url"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"webcrawler/internal/crawler/robots"
@@ -16,10 +18,16 @@ type Scheduler struct {
 	frontierLimit int
 	globalSem     *Semaphore
 	perHost       int
+	hostLimits    map[string]int
+	checkSem      *Semaphore
+	checkPerHost  int
+	checkHosts    map[string]bool
 	tripCount     int
 	circuitReset  time.Duration
 	respectRobots bool
 	robots        *robots.Manager
+	onDrop        func(task *Task, reason, detail string)
+	onTrace       func(task *Task, event string, detail map[string]any)
 
 	hostQueues map[string][]*Task
 	hosts      []string
@@ -27,6 +35,11 @@ type Scheduler struct {
 	hostStates map[string]*HostState
 	frontierSz int
 	mu         sync.RWMutex
+	paused     atomic.Bool
+	// notices holds drop and trace callbacks made under mu; they run once
+	// it is released because the handlers write to storage, which blocks in
+	// lossless mode.
+	notices []func()
 }
 
 func NewScheduler(ctx context.Context, in chan *Task, out chan *Task, frontierLimit int, global *Semaphore, perHost int, tripCount int, circuitReset time.Duration, respectRobots bool, robotsMgr *robots.Manager) *Scheduler {
@@ -43,6 +56,79 @@ func NewScheduler(ctx context.Context, in chan *Task, out chan *Task, frontierLi
 		robots:        robotsMgr,
 		hostQueues:    make(map[string][]*Task),
 		hostStates:    make(map[string]*HostState),
+		checkHosts:    make(map[string]bool),
+	}
+}
+
+// SetCheckBudget gives link-check tasks their own global and per-host
+// concurrency so external validation never competes with the crawl.
+func (s *Scheduler) SetCheckBudget(global *Semaphore, perHost int) {
+	s.checkSem = global
+	s.checkPerHost = perHost
+}
+
+// SetHostLimits changes the per-host concurrency of crawl hosts and replaces
+// the per-host overrides, which apply to any host. Hosts already known are
+// resized at once; permits they hold above a lowered limit drain as their
+// fetches finish.
+func (s *Scheduler) SetHostLimits(perHost int, overrides map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perHost = perHost
+	s.hostLimits = overrides
+	for host, state := range s.hostStates {
+		state.Semaphore.Resize(s.hostLimit(host))
+	}
+}
+
+// hostLimit is the concurrency for host. Callers hold s.mu.
+func (s *Scheduler) hostLimit(host string) int {
+	if n, ok := s.hostLimits[host]; ok {
+		return n
+	}
+	if s.checkHosts[host] {
+		return s.checkPerHost
+	}
+	return s.perHost
+}
+
+// SetDropHandler registers a callback for tasks the scheduler discards, such
+// as URLs disallowed by robots.txt or a full frontier.
+func (s *Scheduler) SetDropHandler(fn func(task *Task, reason, detail string)) {
+	s.onDrop = fn
+}
+
+// SetTraceHandler registers a callback for per-URL lifecycle events.
+func (s *Scheduler) SetTraceHandler(fn func(task *Task, event string, detail map[string]any)) {
+	s.onTrace = fn
+}
+
+// SetPaused stops or resumes dispatching. Tasks are still accepted into the
+// frontier while paused.
+func (s *Scheduler) SetPaused(paused bool) {
+	s.paused.Store(paused)
+}
+
+// trace and drop queue their handler calls until unlock. Callers hold s.mu.
+func (s *Scheduler) trace(task *Task, event string, detail map[string]any) {
+	if s.onTrace != nil {
+		s.notices = append(s.notices, func() { s.onTrace(task, event, detail) })
+	}
+}
+
+func (s *Scheduler) drop(task *Task, reason, detail string) {
+	if s.onDrop != nil {
+		s.notices = append(s.notices, func() { s.onDrop(task, reason, detail) })
+	}
+}
+
+// unlock releases s.mu and then runs the queued notices.
+func (s *Scheduler) unlock() {
+	notices := s.notices
+	s.notices = nil
+	s.mu.Unlock()
+	for _, fn := range notices {
+		fn()
 	}
 }
 
@@ -67,24 +153,32 @@ func (s *Scheduler) enqueue(task *Task) {
 		return
 	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.unlock()
 	if s.frontierLimit > 0 && s.frontierSz >= s.frontierLimit {
+		s.drop(task, SkipFrontierFull, "frontier limit "+strconv.Itoa(s.frontierLimit)+" reached")
 		return
 	}
 	s.frontierSz++
+	task.queuedAt = time.Now()
 	queue := s.hostQueues[task.Host]
 	if len(queue) == 0 {
 		s.hosts = append(s.hosts, task.Host)
 	}
 	s.hostQueues[task.Host] = append(queue, task)
 	if _, ok := s.hostStates[task.Host]; !ok {
-		s.hostStates[task.Host] = NewHostState(task.Host, s.perHost, s.tripCount, s.circuitReset)
+		if task.Kind == TaskCheck && s.checkPerHost > 0 {
+			s.checkHosts[task.Host] = true
+		}
+		s.hostStates[task.Host] = NewHostState(task.Host, s.hostLimit(task.Host), s.tripCount, s.circuitReset)
 	}
 }
 
 func (s *Scheduler) schedule() {
+	if s.paused.Load() {
+		return
+	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.unlock()
 	if len(s.hosts) == 0 {
 		return
 	}
@@ -110,24 +204,40 @@ func (s *Scheduler) schedule() {
 		}
 		state := s.hostStates[host]
 		if state != nil && !state.Allow() {
+			// the task waits rather than being dropped, so it is traced once;
+			// it becomes a skip only if the run stops while the circuit is
+			// still open
+			if !task.circuitDeferred {
+				task.circuitDeferred = true
+				s.trace(task, EventDeferred, map[string]any{"reason": ErrCircuitOpen})
+			}
 			task.NotBefore = time.Now().Add(500 * time.Millisecond)
 			s.hostQueues[host][0] = task
 			s.hostIndex++
 			continue
 		}
-		if !s.globalSem.TryAcquire() {
+		global := s.globalSem
+		if task.Kind == TaskCheck && s.checkSem != nil {
+			global = s.checkSem
+		}
+		if !global.TryAcquire() {
+			if global != s.globalSem {
+				s.hostIndex++
+				continue
+			}
 			return
 		}
 		if state != nil && !state.Semaphore.TryAcquire() {
-			s.globalSem.Release()
+			global.Release()
 			s.hostIndex++
 			continue
 		}
-		if s.respectRobots && s.robots != nil {
+		// link checks are single requests against foreign hosts and skip robots
+		if s.respectRobots && s.robots != nil && task.Kind != TaskCheck {
 			parsed, err := url.Parse(task.URL)
 			if err != nil {
 				state.Semaphore.Release()
-				s.globalSem.Release()
+				global.Release()
 				s.hostQueues[host] = s.hostQueues[host][1:]
 				s.frontierSz--
 				s.hostIndex++
@@ -136,7 +246,7 @@ func (s *Scheduler) schedule() {
 			allowed, ready, _, _ := s.robots.Allowed(s.ctx, parsed)
 			if !ready {
 				state.Semaphore.Release()
-				s.globalSem.Release()
+				global.Release()
 				task.NotBefore = time.Now().Add(750 * time.Millisecond)
 				s.hostQueues[host][0] = task
 				s.hostIndex++
@@ -144,20 +254,22 @@ func (s *Scheduler) schedule() {
 			}
 			if !allowed {
 				state.Semaphore.Release()
-				s.globalSem.Release()
+				global.Release()
 				s.hostQueues[host] = s.hostQueues[host][1:]
 				s.frontierSz--
 				s.hostIndex++
+				s.drop(task, SkipRobotsDenied, "disallowed by robots.txt")
 				continue
 			}
 		}
 		// dequeue
 		s.hostQueues[host] = s.hostQueues[host][1:]
 		s.frontierSz--
-		task.Permit = &Permit{Global: s.globalSem, Host: state.Semaphore}
+		task.Permit = &Permit{Global: global, Host: state.Semaphore}
+		task.queuedMS = time.Since(task.queuedAt).Milliseconds()
+		task.robotsChecked = s.respectRobots && s.robots != nil && task.Kind != TaskCheck
 		select {
 		case s.out <- task:
-			// ok
 		default:
 			// backpressure, requeue
 			task.Permit.Release()
@@ -169,6 +281,24 @@ func (s *Scheduler) schedule() {
 	}
 }
 
+// abandonOpenCircuits drops every task still queued behind an open circuit
+// breaker, so URLs held back when the run stops are recorded as skips.
+func (s *Scheduler) abandonOpenCircuits() {
+	s.mu.Lock()
+	defer s.unlock()
+	for host, queue := range s.hostQueues {
+		state := s.hostStates[host]
+		if len(queue) == 0 || state == nil || state.State() != CircuitOpen {
+			continue
+		}
+		for _, task := range queue {
+			s.drop(task, SkipCircuitOpen, "host circuit open when the run stopped")
+		}
+		s.frontierSz -= len(queue)
+		s.hostQueues[host] = nil
+	}
+}
+
 func (s *Scheduler) removeHostAt(idx int) {
 	host := s.hosts[idx]
 	delete(s.hostQueues, host)
@@ -199,4 +329,3 @@ func (s *Scheduler) HostStatesSnapshot() map[string]*HostState {
 	}
 	return out
 }
- 
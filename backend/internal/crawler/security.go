@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+// inspectHost records the TLS session and security headers of the first
+// HTTPS response seen for a host.
+func (e *Engine) inspectHost(task *Task, resp *http.Response) {
+	if resp.TLS == nil {
+		return
+	}
+	if _, seen := e.inspected.LoadOrStore(task.Host, struct{}{}); seen {
+		return
+	}
+	rec := securityRecord(e.runID, task.Host, resp.TLS)
+	rec.CertVerified = len(resp.TLS.VerifiedChains) > 0
+	rec.HSTS = resp.Header.Get("Strict-Transport-Security")
+	rec.CSP = resp.Header.Get("Content-Security-Policy")
+	rec.XFrameOptions = resp.Header.Get("X-Frame-Options")
+	rec.ReferrerPolicy = resp.Header.Get("Referrer-Policy")
+	e.writeHostSecurity(rec)
+}
+
+// probeTLS inventories a host whose certificate failed verification. The
+// crawl request never completed, so a separate handshake without
+// verification is made to read the certificate; headers stay empty.
+func (e *Engine) probeTLS(task *Task, verifyErr error) {
+	parsed, err := url.Parse(task.URL)
+	if err != nil || parsed.Scheme != "https" {
+		return
+	}
+	if _, seen := e.inspected.LoadOrStore(task.Host, struct{}{}); seen {
+		return
+	}
+	addr := parsed.Host
+	if parsed.Port() == "" {
+		addr = net.JoinHostPort(parsed.Hostname(), "443")
+	}
+	e.goWorker(func() {
+		ctx, cancel := context.WithTimeout(e.ctx, e.cfg.TLSHandshakeTimeout)
+		defer cancel()
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: parsed.Hostname(), InsecureSkipVerify: true}}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		rec := storage.HostSecurityRecord{RunID: e.runID, Host: task.Host, CertError: verifyErr.Error(), InspectedAt: time.Now()}
+		if err == nil {
+			state := conn.(*tls.Conn).ConnectionState()
+			conn.Close()
+			rec = securityRecord(e.runID, task.Host, &state)
+			rec.CertError = verifyErr.Error()
+		}
+		e.writeHostSecurity(rec)
+	})
+}
+
+func securityRecord(runID uuid.UUID, host string, state *tls.ConnectionState) storage.HostSecurityRecord {
+	rec := storage.HostSecurityRecord{
+		RunID:       runID,
+		Host:        host,
+		TLSVersion:  tls.VersionName(state.Version),
+		Cipher:      tls.CipherSuiteName(state.CipherSuite),
+		InspectedAt: time.Now(),
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		notAfter := leaf.NotAfter
+		rec.CertSubject = leaf.Subject.String()
+		rec.CertIssuer = leaf.Issuer.String()
+		rec.CertSANs = certNames(leaf)
+		rec.CertNotAfter = &notAfter
+	}
+	return rec
+}
+
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+func (e *Engine) writeHostSecurity(rec storage.HostSecurityRecord) {
+	e.writer.send(writeHostSecurity, rec)
+}
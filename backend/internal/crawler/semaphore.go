@@ -1,40 +1,73 @@
 package crawler
 
+import "sync"
+
+// Semaphore is a counting semaphore whose size can change while permits are
+// held. Shrinking below the permits in flight refuses new acquisitions until
+// enough have been released.
 type Semaphore struct {
-	ch chan struct{}
+	mu       sync.Mutex
+	cond     *sync.Cond
+	size     int
+	inflight int
 }
 
 func NewSemaphore(size int) *Semaphore {
 	if size <= 0 {
 		size = 1
 	}
-	return &Semaphore{ch: make(chan struct{}, size)}
+	s := &Semaphore{size: size}
+	s.cond = sync.NewCond(&s.mu)
+	return s
 }
 
 func (s *Semaphore) Acquire() {
-	s.ch <- struct{}{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.inflight >= s.size {
+		s.cond.Wait()
+	}
+	s.inflight++
 }
 
 func (s *Semaphore) TryAcquire() bool {
-	select {
-	case s.ch <- struct{}{}:
-		return true
-	default:
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight >= s.size {
 		return false
 	}
+	s.inflight++
+	return true
 }
 
 func (s *Semaphore) Release() {
-	select {
-	case <-s.ch:
-	default:
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight > 0 {
+		s.inflight--
+		s.cond.Signal()
+	}
+}
+
+// Resize changes the number of permits; sizes below one become one.
+func (s *Semaphore) Resize(size int) {
+	if size <= 0 {
+		size = 1
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = size
+	s.cond.Broadcast()
 }
 
 func (s *Semaphore) Inflight() int {
-	return len(s.ch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inflight
 }
 
 func (s *Semaphore) Capacity() int {
-	return cap(s.ch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
 }
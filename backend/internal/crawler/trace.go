@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"webcrawler/internal/storage"
+)
+
+// FetchTiming breaks a fetch into phases. Phases that did not happen, such
+// as DNS and connect on a reused connection, are zero. TTFB runs from the
+// request being written to the first response byte, so it reflects the
+// origin rather than the network setup before it.
+type FetchTiming struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration
+	Transfer time.Duration
+}
+
+func (t FetchTiming) record() storage.FetchTiming {
+	return storage.FetchTiming{
+		DNSMS:      t.DNS.Milliseconds(),
+		ConnectMS:  t.Connect.Milliseconds(),
+		TLSMS:      t.TLS.Milliseconds(),
+		TTFBMS:     t.TTFB.Milliseconds(),
+		TransferMS: t.Transfer.Milliseconds(),
+	}
+}
+
+// fetchTrace collects httptrace callbacks. The transport may invoke dial
+// callbacks from its own goroutines, so all fields are guarded.
+type fetchTrace struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func (f *fetchTrace) clientTrace() *httptrace.ClientTrace {
+	mark := func(t *time.Time) {
+		f.mu.Lock()
+		*t = time.Now()
+		f.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&f.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { mark(&f.dnsDone) },
+		ConnectStart: func(string, string) {
+			f.mu.Lock()
+			if f.connectStart.IsZero() {
+				f.connectStart = time.Now()
+			}
+			f.mu.Unlock()
+		},
+		ConnectDone:          func(string, string, error) { mark(&f.connectDone) },
+		TLSHandshakeStart:    func() { mark(&f.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&f.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&f.wroteRequest) },
+		GotFirstResponseByte: func() { mark(&f.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			f.mu.Lock()
+			f.reused = info.Reused
+			f.mu.Unlock()
+		},
+	}
+}
+
+func (f *fetchTrace) reusedConn() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reused
+}
+
+// timing returns the phase breakdown with the body transfer ending at end.
+func (f *fetchTrace) timing(end time.Time) FetchTiming {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return FetchTiming{
+		DNS:      since(f.dnsStart, f.dnsDone),
+		Connect:  since(f.connectStart, f.connectDone),
+		TLS:      since(f.tlsStart, f.tlsDone),
+		TTFB:     since(f.wroteRequest, f.firstByte),
+		Transfer: since(f.firstByte, end),
+	}
+}
+
+func since(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -1,30 +1,56 @@
 package crawler
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"webcrawler/internal/storage"
+)
 
 type RunConfig struct {
-	SeedURL            string        `json:"seed_url"`
-	MaxDepth           int           `json:"max_depth"`
-	MaxPages           int           `json:"max_pages"`
-	TimeBudget         time.Duration `json:"time_budget"`
-	TimeBudgetSeconds  int           `json:"time_budget_seconds"`
-	MaxLinksPerPage    int           `json:"max_links_per_page"`
-	GlobalConcurrency  int           `json:"global_concurrency"`
-	PerHostConcurrency int           `json:"per_host_concurrency"`
-	UserAgent          string        `json:"user_agent"`
-	RespectRobots      bool          `json:"respect_robots"`
-	RequestTimeout     time.Duration `json:"request_timeout"`
-	HeaderTimeout      time.Duration `json:"header_timeout"`
-	TLSHandshakeTimeout time.Duration `json:"tls_handshake_timeout"`
-	IdleConnTimeout    time.Duration `json:"idle_conn_timeout"`
-	MaxBodyBytes       int64         `json:"max_body_bytes"`
-	RobotsTTL          time.Duration `json:"robots_ttl"`
-	RetryMax           int           `json:"retry_max"`
-	RetryBaseDelay     time.Duration `json:"retry_base_delay"`
-	CircuitTripCount   int           `json:"circuit_trip_count"`
-	CircuitResetTime   time.Duration `json:"circuit_reset_time"`
+	SeedURL                    string        `json:"seed_url"`
+	MaxDepth                   int           `json:"max_depth"`
+	MaxPages                   int           `json:"max_pages"`
+	TimeBudget                 time.Duration `json:"time_budget"`
+	TimeBudgetSeconds          int           `json:"time_budget_seconds"`
+	MaxLinksPerPage            int           `json:"max_links_per_page"`
+	GlobalConcurrency          int           `json:"global_concurrency"`
+	PerHostConcurrency         int           `json:"per_host_concurrency"`
+	UserAgent                  string        `json:"user_agent"`
+	RespectRobots              bool          `json:"respect_robots"`
+	RequestTimeout             time.Duration `json:"request_timeout"`
+	HeaderTimeout              time.Duration `json:"header_timeout"`
+	TLSHandshakeTimeout        time.Duration `json:"tls_handshake_timeout"`
+	IdleConnTimeout            time.Duration `json:"idle_conn_timeout"`
+	MaxBodyBytes               int64         `json:"max_body_bytes"`
+	RobotsTTL                  time.Duration `json:"robots_ttl"`
+	RetryMax                   int           `json:"retry_max"`
+	RetryBaseDelay             time.Duration `json:"retry_base_delay"`
+	CircuitTripCount           int           `json:"circuit_trip_count"`
+	CircuitResetTime           time.Duration `json:"circuit_reset_time"`
+	ExtractText                bool          `json:"extract_text"`
+	Mode                       string        `json:"mode"`
+	Scope                      string        `json:"scope"`
+	ExternalConcurrency        int           `json:"external_concurrency"`
+	ExternalPerHostConcurrency int           `json:"external_per_host_concurrency"`
+	MaxRedirects               int           `json:"max_redirects"`
+	LosslessWrites             bool          `json:"lossless_writes"`
+	WriteBatchSize             int           `json:"write_batch_size"`
+	WriteFlushInterval         time.Duration `json:"write_flush_interval"`
+
+	// HostConcurrency overrides PerHostConcurrency for the listed host keys.
+	HostConcurrency map[string]int `json:"host_concurrency,omitempty"`
 }
 
+const (
+	ModeCrawl     = "crawl"
+	ModeLinkCheck = "link_check"
+
+	ScopeAll    = "all"
+	ScopeHost   = "host"
+	ScopeDomain = "domain"
+)
+
 func (c RunConfig) Normalize() RunConfig {
 	if c.TimeBudget == 0 && c.TimeBudgetSeconds > 0 {
 		c.TimeBudget = time.Duration(c.TimeBudgetSeconds) * time.Second
@@ -44,19 +70,63 @@ func (c RunConfig) Normalize() RunConfig {
 	if c.PerHostConcurrency < 0 {
 		c.PerHostConcurrency = 0
 	}
+	if len(c.HostConcurrency) > 0 {
+		hosts := make(map[string]int, len(c.HostConcurrency))
+		for host, n := range c.HostConcurrency {
+			hosts[strings.TrimSuffix(strings.ToLower(host), ".")] = n
+		}
+		c.HostConcurrency = hosts
+	}
+	if c.Mode == "" {
+		c.Mode = ModeCrawl
+	}
+	if c.Scope == "" {
+		c.Scope = ScopeAll
+		if c.Mode == ModeLinkCheck {
+			c.Scope = ScopeHost
+		}
+	}
+	if c.ExternalConcurrency < 0 {
+		c.ExternalConcurrency = 0
+	}
+	if c.ExternalPerHostConcurrency < 0 {
+		c.ExternalPerHostConcurrency = 0
+	}
+	if c.MaxRedirects < 0 {
+		c.MaxRedirects = 0
+	}
 	return c
 }
 
+type TaskKind int
+
+const (
+	TaskCrawl TaskKind = iota
+	// TaskCheck validates an out-of-scope link with HEAD (or a ranged GET)
+	// without reading or parsing the body.
+	TaskCheck
+)
+
 type Task struct {
-	URL        string
-	Canonical  string
-	Host       string
-	Depth      int
-	NotBefore  time.Time
-	Retries    int
-	SourceHost string
+	Kind         TaskKind
+	URL          string
+	Canonical    string
+	Host         string
+	Depth        int
+	NotBefore    time.Time
+	Retries      int
+	SourceHost   string
+	Referrer     string
+	OriginURL    string
+	Redirects    []storage.RedirectHop
 	DiscoveredAt time.Time
-	Permit     *Permit
+	Permit       *Permit
+
+	circuitDeferred bool
+	queuedAt        time.Time
+	// queuedMS and robotsChecked describe the latest dispatch for tracing
+	queuedMS      int64
+	robotsChecked bool
 }
 
 type Permit struct {
@@ -88,4 +158,26 @@ type FetchResult struct {
 	ErrMessage   string
 	RedirectURL  string
 	RedirectHost string
+	Timing       FetchTiming
+	Title        string
+	ContentHash  string
+
+	trace *fetchTrace
+	// parseLinks is false for bodies kept only for text extraction.
+	parseLinks bool
+}
+
+func (r *FetchResult) fail(class, message string) *FetchResult {
+	r.ErrClass = class
+	r.ErrMessage = message
+	return r
 }
+
+const (
+	RedirectOutcomeOK         = "ok"
+	RedirectOutcomeError      = "error"
+	RedirectOutcomeLoop       = "loop"
+	RedirectOutcomeLimit      = "limit"
+	RedirectOutcomeSeen       = "already_seen"
+	RedirectOutcomeOutOfScope = "out_of_scope"
+)
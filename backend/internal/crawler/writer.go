@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/metrics"
+	"webcrawler/internal/storage"
+)
+
+// Record kinds, used as the metric label for storage writes.
+const (
+	writePage         = "page"
+	writeError        = "error"
+	writeEdge         = "edge"
+	writeLink         = "link"
+	writeLinkStatus   = "link_status"
+	writeChain        = "redirect_chain"
+	writeSkip         = "skip"
+	writeEvent        = "url_event"
+	writeHostSecurity = "host_security"
+	writeHostStat     = "host_stat"
+	writeHostState    = "host_state"
+)
+
+const (
+	writeBufferSize   = 4096
+	writeRetries      = 3
+	writeRetryBackoff = 200 * time.Millisecond
+	// writeTimeout bounds each attempt so a hung store fails the batch
+	// instead of blocking the writer, and with it every lossless send
+	writeTimeout = 30 * time.Second
+)
+
+type writeOp struct {
+	kind string
+	rec  any
+	at   time.Time
+}
+
+type edgeKey struct {
+	runID    uuid.UUID
+	src, dst string
+}
+
+type pendingBatch struct {
+	recs   []any
+	oldest time.Time
+}
+
+// storageWriter batches records from the crawl workers into multi-row
+// writes. Edges are summed in memory and written once per flush. In lossless
+// mode send blocks when the buffer is full, which backpressures the fetch
+// and parse workers; otherwise the record is dropped and counted. close
+// drains everything still buffered before done is closed.
+//
+// Lossless mode does not survive a store that keeps failing: a batch that
+// fails every retry is dropped and onFail is called, which stops the run.
+type storageWriter struct {
+	store      storage.Store
+	lossless   bool
+	batchSize  int
+	flushEvery time.Duration
+	timeout    time.Duration
+	onFail     func()
+	// failed is set once a lossless batch gave up; later batches are tried
+	// once so stopping is not held up by retries against a broken store
+	failed bool
+
+	in     chan writeOp
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	pending    map[string]*pendingBatch
+	edges      map[edgeKey]int
+	edgeOldest time.Time
+}
+
+func newStorageWriter(store storage.Store, lossless bool, batchSize int, flushEvery time.Duration) *storageWriter {
+	return &storageWriter{
+		store:      store,
+		lossless:   lossless,
+		batchSize:  batchSize,
+		flushEvery: flushEvery,
+		timeout:    writeTimeout,
+		in:         make(chan writeOp, writeBufferSize),
+		done:       make(chan struct{}),
+		pending:    make(map[string]*pendingBatch),
+		edges:      make(map[edgeKey]int),
+	}
+}
+
+func (w *storageWriter) send(kind string, rec any) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		metrics.StorageWritesDropped.WithLabelValues(kind).Inc()
+		return
+	}
+	op := writeOp{kind: kind, rec: rec, at: time.Now()}
+	if w.lossless {
+		w.in <- op
+		metrics.StorageQueueDepth.Inc()
+		return
+	}
+	select {
+	case w.in <- op:
+		metrics.StorageQueueDepth.Inc()
+	default:
+		metrics.StorageWritesDropped.WithLabelValues(kind).Inc()
+	}
+}
+
+// close stops accepting records. Sends already blocked in lossless mode
+// complete first because run keeps draining while close waits for the lock.
+func (w *storageWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.in)
+	}
+}
+
+func (w *storageWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case op, ok := <-w.in:
+			if !ok {
+				w.flushAll()
+				return
+			}
+			metrics.StorageQueueDepth.Dec()
+			w.add(op)
+		case <-ticker.C:
+			w.flushAll()
+		}
+	}
+}
+
+func (w *storageWriter) add(op writeOp) {
+	if op.kind == writeEdge {
+		rec := op.rec.(storage.EdgeRecord)
+		if len(w.edges) == 0 {
+			w.edgeOldest = op.at
+		}
+		w.edges[edgeKey{runID: rec.RunID, src: rec.Src, dst: rec.Dst}] += rec.Count
+		if len(w.edges) >= w.batchSize {
+			w.flushEdges()
+		}
+		return
+	}
+	batch := w.pending[op.kind]
+	if batch == nil {
+		batch = &pendingBatch{}
+		w.pending[op.kind] = batch
+	}
+	if len(batch.recs) == 0 {
+		batch.oldest = op.at
+	}
+	// link records arrive one slice per page
+	if recs, ok := op.rec.([]storage.LinkRecord); ok {
+		for _, rec := range recs {
+			batch.recs = append(batch.recs, rec)
+		}
+	} else {
+		batch.recs = append(batch.recs, op.rec)
+	}
+	if len(batch.recs) >= w.batchSize {
+		w.flush(op.kind, batch)
+	}
+}
+
+func (w *storageWriter) flushAll() {
+	for kind, batch := range w.pending {
+		if len(batch.recs) > 0 {
+			w.flush(kind, batch)
+		}
+	}
+	w.flushEdges()
+}
+
+func (w *storageWriter) flushEdges() {
+	if len(w.edges) == 0 {
+		return
+	}
+	recs := make([]storage.EdgeRecord, 0, len(w.edges))
+	for key, count := range w.edges {
+		recs = append(recs, storage.EdgeRecord{RunID: key.runID, Src: key.src, Dst: key.dst, Count: count})
+	}
+	clear(w.edges)
+	w.write(writeEdge, len(recs), w.edgeOldest, func(ctx context.Context) error {
+		return w.store.UpsertEdges(ctx, recs)
+	})
+}
+
+func (w *storageWriter) flush(kind string, batch *pendingBatch) {
+	recs := batch.recs
+	oldest := batch.oldest
+	batch.recs = nil
+	w.write(kind, len(recs), oldest, func(ctx context.Context) error {
+		return w.storeBatch(ctx, kind, recs)
+	})
+}
+
+// write runs one batch, retrying in lossless mode before giving up and
+// calling onFail. Each attempt gets its own deadline.
+func (w *storageWriter) write(kind string, n int, oldest time.Time, fn func(context.Context) error) {
+	attempts := 1
+	if w.lossless && !w.failed {
+		attempts = writeRetries
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * writeRetryBackoff)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		err = fn(ctx)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("store %s batch of %d: %v", kind, n, err)
+		metrics.StorageWritesDropped.WithLabelValues(kind).Add(float64(n))
+		if w.lossless && !w.failed {
+			w.failed = true
+			if w.onFail != nil {
+				w.onFail()
+			}
+		}
+		return
+	}
+	metrics.StorageBatches.WithLabelValues(kind).Inc()
+	metrics.StorageBatchedRecords.WithLabelValues(kind).Add(float64(n))
+	metrics.StorageWriteLag.Observe(time.Since(oldest).Seconds())
+}
+
+func (w *storageWriter) storeBatch(ctx context.Context, kind string, recs []any) error {
+	switch kind {
+	case writePage:
+		return w.store.InsertPages(ctx, batchOf[storage.PageRecord](recs))
+	case writeError:
+		return w.store.InsertErrors(ctx, batchOf[storage.ErrorRecord](recs))
+	case writeLink:
+		return w.store.InsertLinks(ctx, batchOf[storage.LinkRecord](recs))
+	case writeSkip:
+		return w.store.InsertSkips(ctx, batchOf[storage.SkipRecord](recs))
+	case writeEvent:
+		return w.store.InsertURLEvents(ctx, batchOf[storage.URLEvent](recs))
+	case writeLinkStatus:
+		return w.store.UpsertLinkStatuses(ctx, batchOf[storage.LinkStatusRecord](recs))
+	case writeChain:
+		return w.store.InsertRedirectChains(ctx, batchOf[storage.RedirectChainRecord](recs))
+	case writeHostSecurity:
+		return w.store.UpsertHostSecurities(ctx, batchOf[storage.HostSecurityRecord](recs))
+	case writeHostStat:
+		return w.store.UpsertHostStats(ctx, batchOf[storage.HostStatRecord](recs))
+	case writeHostState:
+		return w.store.UpsertHostStates(ctx, batchOf[storage.HostStateRecord](recs))
+	}
+	return nil
+}
+
+func batchOf[T any](recs []any) []T {
+	out := make([]T, len(recs))
+	for i, rec := range recs {
+		out[i] = rec.(T)
+	}
+	return out
+}
@@ -0,0 +1,227 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+type batchStore struct {
+	*storage.MemoryStore
+	mu         sync.Mutex
+	pageBatch  []int
+	edgeBatch  [][]storage.EdgeRecord
+	gate       chan struct{}
+	pagesStore int
+}
+
+func (s *batchStore) InsertPages(ctx context.Context, recs []storage.PageRecord) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	s.pageBatch = append(s.pageBatch, len(recs))
+	s.pagesStore += len(recs)
+	s.mu.Unlock()
+	return s.MemoryStore.InsertPages(ctx, recs)
+}
+
+func (s *batchStore) UpsertEdges(ctx context.Context, recs []storage.EdgeRecord) error {
+	s.mu.Lock()
+	s.edgeBatch = append(s.edgeBatch, recs)
+	s.mu.Unlock()
+	return s.MemoryStore.UpsertEdges(ctx, recs)
+}
+
+func TestStorageWriterBatchesAndAggregatesEdges(t *testing.T) {
+	store := &batchStore{MemoryStore: storage.NewMemory()}
+	w := newStorageWriter(store, false, 3, time.Hour)
+	go w.run()
+	runID := uuid.New()
+	for i := 0; i < 7; i++ {
+		w.send(writePage, storage.PageRecord{RunID: runID, URL: "https://a.test/" + strconv.Itoa(i)})
+	}
+	for i := 0; i < 5; i++ {
+		w.send(writeEdge, storage.EdgeRecord{RunID: runID, Src: "a.test", Dst: "b.test", Count: 1})
+	}
+	w.send(writeEdge, storage.EdgeRecord{RunID: runID, Src: "a.test", Dst: "c.test", Count: 1})
+	w.close()
+	<-w.done
+
+	if fmt.Sprint(store.pageBatch) != "[3 3 1]" {
+		t.Fatalf("page batches = %v, want [3 3 1]", store.pageBatch)
+	}
+	if len(store.edgeBatch) != 1 || len(store.edgeBatch[0]) != 2 {
+		t.Fatalf("expected one edge batch with two pairs, got %+v", store.edgeBatch)
+	}
+	for _, rec := range store.edgeBatch[0] {
+		if rec.Dst == "b.test" && rec.Count != 5 {
+			t.Fatalf("expected b.test count 5, got %d", rec.Count)
+		}
+	}
+}
+
+func TestStorageWriterLosslessBackpressure(t *testing.T) {
+	total := writeBufferSize + 500
+	for _, lossless := range []bool{false, true} {
+		store := &batchStore{MemoryStore: storage.NewMemory(), gate: make(chan struct{})}
+		w := newStorageWriter(store, lossless, 100, time.Hour)
+		go w.run()
+		sent := make(chan struct{})
+		go func() {
+			for i := 0; i < total; i++ {
+				w.send(writePage, storage.PageRecord{URL: strconv.Itoa(i)})
+			}
+			close(sent)
+		}()
+		select {
+		case <-sent:
+			if lossless {
+				t.Fatal("lossless sends completed while the store was blocked")
+			}
+		case <-time.After(200 * time.Millisecond):
+			if !lossless {
+				t.Fatal("lossy sends blocked on a full buffer")
+			}
+		}
+		close(store.gate)
+		<-sent
+		w.close()
+		<-w.done
+		if lossless && store.pagesStore != total {
+			t.Fatalf("lossless stored %d of %d pages", store.pagesStore, total)
+		}
+		if !lossless && store.pagesStore >= total {
+			t.Fatalf("expected lossy writer to drop pages, stored %d of %d", store.pagesStore, total)
+		}
+	}
+}
+
+func TestEngineDrainsWritesOnStop(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Header().Set("Content-Type", "text/html")
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, `<a href="/?n=%d">next</a>`, n*5+i)
+		}
+	}))
+	defer site.Close()
+
+	store := storage.NewMemory()
+	ctx := context.Background()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 10
+	cfg.MaxPages = 40
+	cfg.LosslessWrites = true
+	cfg.WriteBatchSize = 10000
+	cfg.WriteFlushInterval = time.Hour
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.Start(site.URL)
+
+	select {
+	case <-engine.Drained():
+	case <-time.After(10 * time.Second):
+		t.Fatal("engine did not drain")
+	}
+	pages, _ := store.ListPages(ctx, runID, 1000)
+	fetched := 0
+	for _, page := range pages {
+		if page.ErrorClass == "" {
+			fetched++
+		}
+	}
+	if int64(fetched) != engine.PagesFetched() {
+		t.Fatalf("stored %d fetched pages, engine fetched %d", fetched, engine.PagesFetched())
+	}
+	run, _ := store.GetRun(ctx, runID)
+	if run.Status != "stopped" {
+		t.Fatalf("expected run marked stopped after drain, got %s", run.Status)
+	}
+}
+
+type failingPageStore struct {
+	*storage.MemoryStore
+}
+
+func (s failingPageStore) InsertPages(ctx context.Context, recs []storage.PageRecord) error {
+	return errors.New("disk full")
+}
+
+// hungPageStore never answers page writes until the caller gives up.
+type hungPageStore struct {
+	*storage.MemoryStore
+}
+
+func (s hungPageStore) InsertPages(ctx context.Context, recs []storage.PageRecord) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLosslessWriteFailureStopsRun(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/?n=%d">next</a>`, n+1)
+	}))
+	defer site.Close()
+
+	store := failingPageStore{storage.NewMemory()}
+	runID, _ := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 0
+	cfg.MaxPages = 0
+	cfg.LosslessWrites = true
+	cfg.WriteBatchSize = 1
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	select {
+	case <-engine.Drained():
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected a failing lossless write to stop the run")
+	}
+	if engine.StopReason() != StopReasonStorage {
+		t.Fatalf("expected stop reason %s, got %q", StopReasonStorage, engine.StopReason())
+	}
+}
+
+func TestHungStoreTimesOutAndStopsLosslessRun(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/?n=%d">next</a>`, n+1)
+	}))
+	defer site.Close()
+
+	store := hungPageStore{storage.NewMemory()}
+	runID, _ := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: site.URL})
+	cfg := testRunConfig(site.URL, ModeCrawl)
+	cfg.MaxDepth = 0
+	cfg.MaxPages = 0
+	cfg.LosslessWrites = true
+	cfg.WriteBatchSize = 1
+	engine := NewEngine(runID, cfg, store, nil)
+	engine.writer.timeout = 20 * time.Millisecond
+	engine.Start(site.URL)
+	defer engine.Stop()
+
+	select {
+	case <-engine.Drained():
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected a hung store to time out and stop the run")
+	}
+	if engine.StopReason() != StopReasonStorage {
+		t.Fatalf("expected stop reason %s, got %q", StopReasonStorage, engine.StopReason())
+	}
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+// The row types fix the exported columns; JSON tags name the CSV columns and
+// JSONL fields, parquet tags the Parquet columns.
+
+type pageRow struct {
+	ID           int64      `json:"id" parquet:"id"`
+	URL          string     `json:"url" parquet:"url"`
+	CanonicalURL string     `json:"canonical_url" parquet:"canonical_url"`
+	Host         string     `json:"host" parquet:"host,dict"`
+	Depth        int32      `json:"depth" parquet:"depth"`
+	StatusCode   int32      `json:"status_code" parquet:"status_code"`
+	ContentType  string     `json:"content_type" parquet:"content_type,dict"`
+	FetchMS      int64      `json:"fetch_ms" parquet:"fetch_ms"`
+	SizeBytes    int64      `json:"size_bytes" parquet:"size_bytes"`
+	ErrorClass   string     `json:"error_class" parquet:"error_class,dict"`
+	ErrorMessage string     `json:"error_message" parquet:"error_message"`
+	Referrer     string     `json:"referrer" parquet:"referrer"`
+	RedirectURL  string     `json:"redirect_url" parquet:"redirect_url"`
+	OriginURL    string     `json:"origin_url" parquet:"origin_url"`
+	DNSMS        int64      `json:"dns_ms" parquet:"dns_ms"`
+	ConnectMS    int64      `json:"connect_ms" parquet:"connect_ms"`
+	TLSMS        int64      `json:"tls_ms" parquet:"tls_ms"`
+	TTFBMS       int64      `json:"ttfb_ms" parquet:"ttfb_ms"`
+	TransferMS   int64      `json:"transfer_ms" parquet:"transfer_ms"`
+	Title        string     `json:"title" parquet:"title"`
+	ContentHash  string     `json:"content_hash" parquet:"content_hash"`
+	DiscoveredAt time.Time  `json:"discovered_at" parquet:"discovered_at"`
+	FetchedAt    *time.Time `json:"fetched_at" parquet:"fetched_at,optional"`
+}
+
+func newPageRow(p storage.PageRow) pageRow {
+	return pageRow{
+		ID:           p.ID,
+		URL:          p.URL,
+		CanonicalURL: p.CanonicalURL,
+		Host:         p.Host,
+		Depth:        int32(p.Depth),
+		StatusCode:   int32(p.StatusCode),
+		ContentType:  p.ContentType,
+		FetchMS:      p.FetchMS,
+		SizeBytes:    p.SizeBytes,
+		ErrorClass:   p.ErrorClass,
+		ErrorMessage: p.ErrorMessage,
+		Referrer:     p.Referrer,
+		RedirectURL:  p.RedirectURL,
+		OriginURL:    p.OriginURL,
+		DNSMS:        p.Timing.DNSMS,
+		ConnectMS:    p.Timing.ConnectMS,
+		TLSMS:        p.Timing.TLSMS,
+		TTFBMS:       p.Timing.TTFBMS,
+		TransferMS:   p.Timing.TransferMS,
+		Title:        p.Title,
+		ContentHash:  p.ContentHash,
+		DiscoveredAt: p.DiscoveredAt,
+		FetchedAt:    p.FetchedAt,
+	}
+}
+
+type errorRow struct {
+	ID      int64     `json:"id" parquet:"id"`
+	Host    string    `json:"host" parquet:"host,dict"`
+	URL     string    `json:"url" parquet:"url"`
+	Class   string    `json:"class" parquet:"class,dict"`
+	Message string    `json:"message" parquet:"message"`
+	At      time.Time `json:"at" parquet:"at"`
+}
+
+func newErrorRow(e storage.ErrorRecord) errorRow {
+	return errorRow{ID: e.ID, Host: e.Host, URL: e.URL, Class: e.Class, Message: e.Message, At: e.At}
+}
+
+type edgeRow struct {
+	Src   string `json:"src" parquet:"src,dict"`
+	Dst   string `json:"dst" parquet:"dst,dict"`
+	Count int64  `json:"count" parquet:"count"`
+}
+
+func newEdgeRow(e storage.EdgeRecord) edgeRow {
+	return edgeRow{Src: e.Src, Dst: e.Dst, Count: int64(e.Count)}
+}
+
+// The batch functions page through a dataset with its keyset cursor and
+// return an empty batch once it is exhausted.
+
+func pageBatches(ctx context.Context, store storage.Store, runID uuid.UUID, q storage.PageQuery) func() ([]storage.PageRow, error) {
+	q.Limit = batchSize
+	q.After = nil
+	done := false
+	return func() ([]storage.PageRow, error) {
+		if done {
+			return nil, nil
+		}
+		rows, err := store.QueryPages(ctx, runID, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < q.Limit {
+			done = true
+		} else {
+			cur := storage.PageCursorFor(rows[len(rows)-1], q.Sort)
+			q.After = &cur
+		}
+		return rows, nil
+	}
+}
+
+func errorBatches(ctx context.Context, store storage.Store, runID uuid.UUID, q storage.ErrorQuery) func() ([]storage.ErrorRecord, error) {
+	q.Limit = batchSize
+	q.AfterID = 0
+	done := false
+	return func() ([]storage.ErrorRecord, error) {
+		if done {
+			return nil, nil
+		}
+		rows, err := store.QueryErrors(ctx, runID, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < q.Limit {
+			done = true
+		} else {
+			q.AfterID = rows[len(rows)-1].ID
+		}
+		return rows, nil
+	}
+}
+
+func edgeBatches(ctx context.Context, store storage.Store, runID uuid.UUID, q storage.EdgeQuery) func() ([]storage.EdgeRecord, error) {
+	q.Limit = batchSize
+	q.After = nil
+	done := false
+	return func() ([]storage.EdgeRecord, error) {
+		if done {
+			return nil, nil
+		}
+		rows, err := store.ListEdges(ctx, runID, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < q.Limit {
+			done = true
+		} else {
+			last := rows[len(rows)-1]
+			q.After = &storage.EdgeCursor{Count: last.Count, Src: last.Src, Dst: last.Dst}
+		}
+		return rows, nil
+	}
+}
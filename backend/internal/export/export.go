@@ -0,0 +1,283 @@
+// Package export streams run data out of the store as CSV, JSON Lines or
+// Parquet. Rows are read in keyset-paged batches, so memory stays bounded by
+// the batch size (and, for Parquet, the row group) regardless of run size.
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"webcrawler/internal/storage"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+const (
+	DatasetPages  = "pages"
+	DatasetErrors = "errors"
+	DatasetEdges  = "edges"
+)
+
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+const (
+	batchSize   = 1000
+	rowGroupMax = 50000
+)
+
+type Options struct {
+	Dataset     string
+	Format      string
+	Compression string
+	Pages       storage.PageQuery
+	Errors      storage.ErrorQuery
+	Edges       storage.EdgeQuery
+}
+
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatJSONL || format == FormatParquet
+}
+
+func ValidDataset(dataset string) bool {
+	return dataset == DatasetPages || dataset == DatasetErrors || dataset == DatasetEdges
+}
+
+func ValidCompression(compression string) bool {
+	return compression == CompressionNone || compression == CompressionGzip || compression == CompressionZstd
+}
+
+// ContentType is the media type of the response body. Parquet compresses
+// its column chunks internally, so the file itself is never wrapped.
+func ContentType(format, compression string) string {
+	if format != FormatParquet {
+		switch compression {
+		case CompressionGzip:
+			return "application/gzip"
+		case CompressionZstd:
+			return "application/zstd"
+		}
+	}
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+func FileName(runID uuid.UUID, dataset, format, compression string) string {
+	name := dataset + "-" + runID.String() + "." + format
+	if format != FormatParquet {
+		switch compression {
+		case CompressionGzip:
+			name += ".gz"
+		case CompressionZstd:
+			name += ".zst"
+		}
+	}
+	return name
+}
+
+// Write streams the selected dataset to w and returns the number of rows
+// written. Once rows have been written an error leaves w truncated.
+func Write(ctx context.Context, w io.Writer, store storage.Store, runID uuid.UUID, opts Options) (int64, error) {
+	switch opts.Dataset {
+	case DatasetPages:
+		return write(w, opts, pageBatches(ctx, store, runID, opts.Pages), newPageRow)
+	case DatasetErrors:
+		return write(w, opts, errorBatches(ctx, store, runID, opts.Errors), newErrorRow)
+	case DatasetEdges:
+		return write(w, opts, edgeBatches(ctx, store, runID, opts.Edges), newEdgeRow)
+	}
+	return 0, fmt.Errorf("unknown dataset %q", opts.Dataset)
+}
+
+// write drains next, converting each stored record to its export row R.
+func write[S, R any](w io.Writer, opts Options, next func() ([]S, error), convert func(S) R) (int64, error) {
+	out, closeOut, err := compressed(w, opts)
+	if err != nil {
+		return 0, err
+	}
+	enc, err := newEncoder[R](out, opts)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for {
+		batch, err := next()
+		if err != nil {
+			return n, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		rows := make([]R, len(batch))
+		for i, rec := range batch {
+			rows[i] = convert(rec)
+		}
+		if err := enc.write(rows); err != nil {
+			return n, err
+		}
+		n += int64(len(rows))
+	}
+	if err := enc.close(); err != nil {
+		return n, err
+	}
+	return n, closeOut()
+}
+
+func compressed(w io.Writer, opts Options) (io.Writer, func() error, error) {
+	if opts.Format == FormatParquet {
+		return w, func() error { return nil }, nil
+	}
+	switch opts.Compression {
+	case CompressionGzip:
+		zw := gzip.NewWriter(w)
+		return zw, zw.Close, nil
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zw, zw.Close, nil
+	}
+	return w, func() error { return nil }, nil
+}
+
+type encoder[R any] interface {
+	write(rows []R) error
+	close() error
+}
+
+func newEncoder[R any](w io.Writer, opts Options) (encoder[R], error) {
+	switch opts.Format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns(reflect.TypeFor[R]())); err != nil {
+			return nil, err
+		}
+		return &csvEncoder[R]{w: cw}, nil
+	case FormatJSONL:
+		return &jsonlEncoder[R]{enc: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		options := []parquet.WriterOption{parquet.MaxRowsPerRowGroup(rowGroupMax)}
+		switch opts.Compression {
+		case CompressionGzip:
+			options = append(options, parquet.Compression(&parquet.Gzip))
+		case CompressionZstd:
+			options = append(options, parquet.Compression(&parquet.Zstd))
+		}
+		return &parquetEncoder[R]{w: parquet.NewGenericWriter[R](w, options...)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", opts.Format)
+}
+
+type csvEncoder[R any] struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder[R]) write(rows []R) error {
+	for _, row := range rows {
+		if err := e.w.Write(values(reflect.ValueOf(row))); err != nil {
+			return err
+		}
+	}
+	// flush per batch so rows reach the client as they are read
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder[R]) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder[R any] struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder[R]) write(rows []R) error {
+	for _, row := range rows {
+		if err := e.enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *jsonlEncoder[R]) close() error { return nil }
+
+type parquetEncoder[R any] struct {
+	w *parquet.GenericWriter[R]
+}
+
+func (e *parquetEncoder[R]) write(rows []R) error {
+	_, err := e.w.Write(rows)
+	return err
+}
+
+func (e *parquetEncoder[R]) close() error { return e.w.Close() }
+
+// columns names the CSV columns after the row's JSON fields.
+func columns(t reflect.Type) []string {
+	out := make([]string, t.NumField())
+	for i := range out {
+		out[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return out
+}
+
+func values(v reflect.Value) []string {
+	out := make([]string, v.NumField())
+	for i := range out {
+		out[i] = csvValue(v.Field(i))
+	}
+	return out
+}
+
+func csvValue(f reflect.Value) string {
+	if f.Kind() == reflect.Pointer {
+		if f.IsNil() {
+			return ""
+		}
+		f = f.Elem()
+	}
+	switch v := f.Interface().(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(f.Interface())
+}
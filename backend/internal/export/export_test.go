@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"webcrawler/internal/storage"
+)
+
+// testRun is a memory store holding one run. Its helpers fail the test on
+// any store error.
+type testRun struct {
+	t     *testing.T
+	store storage.Store
+	id    uuid.UUID
+	now   time.Time
+}
+
+func newTestRun(t *testing.T) *testRun {
+	t.Helper()
+	store := storage.NewMemory()
+	id, err := store.CreateRun(context.Background(), storage.RunConfig{SeedURL: "https://a.test/"})
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	return &testRun{t: t, store: store, id: id, now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// page is a page of the run fetched at r.now.
+func (r *testRun) page(u, host string, depth, status int) storage.PageRecord {
+	return storage.PageRecord{RunID: r.id, URL: u, CanonicalURL: u, Host: host, Depth: depth, StatusCode: status, DiscoveredAt: r.now, FetchedAt: &r.now}
+}
+
+func (r *testRun) insertPages(recs ...storage.PageRecord) {
+	r.t.Helper()
+	if err := r.store.InsertPages(context.Background(), recs); err != nil {
+		r.t.Fatalf("insert pages: %v", err)
+	}
+}
+
+// insertLinks stores one anchor link per src, dst pair.
+func (r *testRun) insertLinks(pairs ...[2]string) {
+	r.t.Helper()
+	links := make([]storage.LinkRecord, 0, len(pairs))
+	for _, p := range pairs {
+		links = append(links, storage.LinkRecord{RunID: r.id, SrcURL: p[0], DstURL: p[1], Element: "a"})
+	}
+	if err := r.store.InsertLinks(context.Background(), links); err != nil {
+		r.t.Fatalf("insert links: %v", err)
+	}
+}
+
+func (r *testRun) upsertEdges(recs ...storage.EdgeRecord) {
+	r.t.Helper()
+	for i := range recs {
+		recs[i].RunID = r.id
+	}
+	if err := r.store.UpsertEdges(context.Background(), recs); err != nil {
+		r.t.Fatalf("upsert edges: %v", err)
+	}
+}
+
+func (r *testRun) insertErrors(recs ...storage.ErrorRecord) {
+	r.t.Helper()
+	for i := range recs {
+		recs[i].RunID = r.id
+	}
+	if err := r.store.InsertErrors(context.Background(), recs); err != nil {
+		r.t.Fatalf("insert errors: %v", err)
+	}
+}
+
+func seedStore(t *testing.T, pages int) (storage.Store, uuid.UUID) {
+	t.Helper()
+	r := newTestRun(t)
+	recs := make([]storage.PageRecord, pages)
+	for i := range recs {
+		recs[i] = r.page("https://a.test/"+strconv.Itoa(i), "a.test", 0, 200)
+		at := r.now.Add(time.Duration(i) * time.Second)
+		recs[i].SizeBytes, recs[i].DiscoveredAt, recs[i].FetchedAt = int64(i), at, &at
+		if i%10 == 0 {
+			recs[i].StatusCode, recs[i].ErrClass = 404, "status"
+		}
+	}
+	r.insertPages(recs...)
+	r.insertErrors(storage.ErrorRecord{Host: "a.test", URL: "https://a.test/0", Class: "status", Message: "404", At: r.now})
+	r.upsertEdges(storage.EdgeRecord{Src: "a.test", Dst: "b.test", Count: 3})
+	return r.store, r.id
+}
+
+func TestWriteCSVStreamsAllBatches(t *testing.T) {
+	store, runID := seedStore(t, 2*batchSize+5)
+	var buf bytes.Buffer
+	n, err := Write(context.Background(), &buf, store, runID, Options{Dataset: DatasetPages, Format: FormatCSV, Pages: storage.PageQuery{Sort: storage.PageSortFetched}})
+	if err != nil || n != 2*batchSize+5 {
+		t.Fatalf("wrote %d rows (%v)", n, err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(records) != int(n)+1 || records[0][0] != "id" || records[0][len(records[0])-1] != "fetched_at" {
+		t.Fatalf("unexpected header or row count: %v (%d rows)", records[0], len(records))
+	}
+	if records[1][1] != "https://a.test/0" || records[len(records)-1][1] != "https://a.test/"+strconv.Itoa(2*batchSize+4) {
+		t.Fatalf("rows out of order: first %v, last %v", records[1], records[len(records)-1])
+	}
+}
+
+func TestWriteAppliesFiltersAndCompression(t *testing.T) {
+	store, runID := seedStore(t, 50)
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		var buf bytes.Buffer
+		opts := Options{Dataset: DatasetPages, Format: FormatJSONL, Compression: compression, Pages: storage.PageQuery{Sort: storage.PageSortFetched, ErrorClass: "status"}}
+		if _, err := Write(context.Background(), &buf, store, runID, opts); err != nil {
+			t.Fatalf("%s: %v", compression, err)
+		}
+		var r io.Reader
+		if compression == CompressionGzip {
+			gz, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = gz
+		} else {
+			zr, err := zstd.NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zr.Close()
+			r = zr
+		}
+		dec := json.NewDecoder(r)
+		var rows []pageRow
+		for dec.More() {
+			var row pageRow
+			if err := dec.Decode(&row); err != nil {
+				t.Fatalf("%s: decode: %v", compression, err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) != 5 || rows[0].StatusCode != 404 || rows[0].ErrorClass != "status" {
+			t.Fatalf("%s: expected the 5 failed pages, got %+v", compression, rows)
+		}
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	store, runID := seedStore(t, 20)
+	for _, dataset := range []string{DatasetPages, DatasetErrors, DatasetEdges} {
+		var buf bytes.Buffer
+		opts := Options{Dataset: dataset, Format: FormatParquet, Compression: CompressionZstd, Pages: storage.PageQuery{Sort: storage.PageSortURL}}
+		n, err := Write(context.Background(), &buf, store, runID, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", dataset, err)
+		}
+		var got int
+		switch dataset {
+		case DatasetPages:
+			rows, err := parquet.Read[pageRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil || rows[0].FetchedAt == nil || rows[0].DiscoveredAt.IsZero() {
+				t.Fatalf("read pages: %+v (%v)", rows, err)
+			}
+			got = len(rows)
+		case DatasetErrors:
+			rows, err := parquet.Read[errorRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil || rows[0].Class != "status" {
+				t.Fatalf("read errors: %+v (%v)", rows, err)
+			}
+			got = len(rows)
+		case DatasetEdges:
+			rows, err := parquet.Read[edgeRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil || rows[0].Count != 3 {
+				t.Fatalf("read edges: %+v (%v)", rows, err)
+			}
+			got = len(rows)
+		}
+		if int64(got) != n || n == 0 {
+			t.Fatalf("%s: wrote %d rows, read back %d", dataset, n, got)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+const (
+	GraphFormatGraphML = "graphml"
+	GraphFormatGEXF    = "gexf"
+	GraphFormatDOT     = "dot"
+	GraphFormatNeo4j   = "neo4j"
+)
+
+// A host graph has one node per host and the cross-host edges counted by the
+// crawler; a URL graph has one node per stored page and its links.
+const (
+	GraphLevelHost = "host"
+	GraphLevelURL  = "url"
+)
+
+type GraphOptions struct {
+	Level       string
+	Format      string
+	Compression string
+	// MinWeight drops edges backed by fewer links.
+	MinWeight int
+	// Domain keeps nodes whose host is the domain or one of its subdomains.
+	// An edge is kept only when both of its ends are.
+	Domain string
+}
+
+// GraphStats counts what a graph export wrote.
+type GraphStats struct {
+	Nodes int64
+	Edges int64
+}
+
+func ValidGraphFormat(format string) bool {
+	return format == GraphFormatGraphML || format == GraphFormatGEXF || format == GraphFormatDOT || format == GraphFormatNeo4j
+}
+
+func ValidGraphLevel(level string) bool {
+	return level == GraphLevelHost || level == GraphLevelURL
+}
+
+// GraphContentType is the media type of a graph export. Neo4j exports are a
+// zip of node and relationship CSVs and are never wrapped again.
+func GraphContentType(format, compression string) string {
+	if format != GraphFormatNeo4j {
+		switch compression {
+		case CompressionGzip:
+			return "application/gzip"
+		case CompressionZstd:
+			return "application/zstd"
+		}
+	}
+	switch format {
+	case GraphFormatGraphML:
+		return "application/graphml+xml"
+	case GraphFormatGEXF:
+		return "application/gexf+xml"
+	case GraphFormatDOT:
+		return "text/vnd.graphviz"
+	default:
+		return "application/zip"
+	}
+}
+
+func GraphFileName(runID uuid.UUID, level, format, compression string) string {
+	if format == GraphFormatNeo4j {
+		return "graph-" + level + "-" + runID.String() + "-neo4j.zip"
+	}
+	name := "graph-" + level + "-" + runID.String() + "." + format
+	switch compression {
+	case CompressionGzip:
+		name += ".gz"
+	case CompressionZstd:
+		name += ".zst"
+	}
+	return name
+}
+
+type graphNode struct {
+	ID        string
+	Host      string
+	Pages     int64
+	ErrorRate float64
+	// Depth is the shallowest crawl depth, or -1 for hosts only known as
+	// link targets.
+	Depth int
+	// Status is set on URL nodes only.
+	Status int
+}
+
+type graphEdge struct {
+	Src    string
+	Dst    string
+	Weight int64
+}
+
+// WriteGraph streams the run's link graph to w. Nodes are written before
+// edges, as GEXF and the Neo4j importer require. URL graphs only hold pages
+// stored for the run; links to URLs that were never fetched are dropped.
+func WriteGraph(ctx context.Context, w io.Writer, store storage.Store, runID uuid.UUID, opts GraphOptions) (GraphStats, error) {
+	opts.Domain = strings.TrimPrefix(strings.ToLower(opts.Domain), ".")
+	var out io.Writer = w
+	closeOut := func() error { return nil }
+	if opts.Format != GraphFormatNeo4j {
+		var err error
+		// the compression wrappers are shared with the tabular exports
+		if out, closeOut, err = compressed(w, Options{Compression: opts.Compression}); err != nil {
+			return GraphStats{}, err
+		}
+	}
+	enc, err := newGraphEncoder(out, opts)
+	if err != nil {
+		return GraphStats{}, err
+	}
+	var stats GraphStats
+	switch opts.Level {
+	case GraphLevelHost:
+		stats, err = writeHostGraph(ctx, enc, store, runID, opts)
+	case GraphLevelURL:
+		stats, err = writeURLGraph(ctx, enc, store, runID, opts)
+	default:
+		err = fmt.Errorf("unknown graph level %q", opts.Level)
+	}
+	if err != nil {
+		return stats, err
+	}
+	if err := enc.close(); err != nil {
+		return stats, err
+	}
+	return stats, closeOut()
+}
+
+func inDomain(host, domain string) bool {
+	return domain == "" || host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func errorRate(failed, pages int64) float64 {
+	if pages == 0 {
+		return 0
+	}
+	return float64(failed) / float64(pages)
+}
+
+// writeHostGraph holds the host graph in memory: it has at most one node per
+// host and one edge per host pair, and hosts that were only linked to are
+// known once every edge has been read.
+func writeHostGraph(ctx context.Context, enc graphEncoder, store storage.Store, runID uuid.UUID, opts GraphOptions) (GraphStats, error) {
+	counts, err := store.HostPageCounts(ctx, runID)
+	if err != nil {
+		return GraphStats{}, err
+	}
+	nodes := make(map[string]graphNode, len(counts))
+	for _, c := range counts {
+		if inDomain(c.Host, opts.Domain) {
+			nodes[c.Host] = graphNode{ID: c.Host, Host: c.Host, Pages: c.Pages, ErrorRate: errorRate(c.Failed, c.Pages), Depth: c.MinDepth}
+		}
+	}
+	var edges []graphEdge
+	next := edgeBatches(ctx, store, runID, storage.EdgeQuery{})
+	for {
+		batch, err := next()
+		if err != nil {
+			return GraphStats{}, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, e := range batch {
+			if e.Count < opts.MinWeight || !inDomain(e.Src, opts.Domain) || !inDomain(e.Dst, opts.Domain) {
+				continue
+			}
+			for _, host := range []string{e.Src, e.Dst} {
+				if _, ok := nodes[host]; !ok {
+					nodes[host] = graphNode{ID: host, Host: host, Depth: -1}
+				}
+			}
+			edges = append(edges, graphEdge{Src: e.Src, Dst: e.Dst, Weight: int64(e.Count)})
+		}
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var stats GraphStats
+	for _, id := range ids {
+		if err := enc.node(nodes[id]); err != nil {
+			return stats, err
+		}
+		stats.Nodes++
+	}
+	if err := enc.edges(); err != nil {
+		return stats, err
+	}
+	for _, e := range edges {
+		if err := enc.edge(e); err != nil {
+			return stats, err
+		}
+		stats.Edges++
+	}
+	return stats, nil
+}
+
+// writeURLGraph streams pages and then link edges in batches. Only the set
+// of node ids is kept, to drop edges that leave the graph.
+func writeURLGraph(ctx context.Context, enc graphEncoder, store storage.Store, runID uuid.UUID, opts GraphOptions) (GraphStats, error) {
+	var stats GraphStats
+	kept := map[string]struct{}{}
+	pages := pageBatches(ctx, store, runID, storage.PageQuery{Sort: storage.PageSortURL})
+	for {
+		batch, err := pages()
+		if err != nil {
+			return stats, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, p := range batch {
+			if !inDomain(p.Host, opts.Domain) {
+				continue
+			}
+			if _, dup := kept[p.CanonicalURL]; dup {
+				continue
+			}
+			kept[p.CanonicalURL] = struct{}{}
+			n := graphNode{ID: p.CanonicalURL, Host: p.Host, Pages: 1, Depth: p.Depth, Status: p.StatusCode}
+			if p.ErrorClass != "" {
+				n.ErrorRate = 1
+			}
+			if err := enc.node(n); err != nil {
+				return stats, err
+			}
+			stats.Nodes++
+		}
+	}
+	if err := enc.edges(); err != nil {
+		return stats, err
+	}
+
+	q := storage.LinkEdgeQuery{MinCount: opts.MinWeight, Limit: batchSize}
+	for {
+		batch, err := store.ListLinkEdges(ctx, runID, q)
+		if err != nil {
+			return stats, err
+		}
+		for _, e := range batch {
+			_, src := kept[e.Src]
+			_, dst := kept[e.Dst]
+			if !src || !dst {
+				continue
+			}
+			if err := enc.edge(graphEdge{Src: e.Src, Dst: e.Dst, Weight: int64(e.Count)}); err != nil {
+				return stats, err
+			}
+			stats.Edges++
+		}
+		if len(batch) < q.Limit {
+			return stats, nil
+		}
+		q.After = &batch[len(batch)-1]
+	}
+}
+
+type graphEncoder interface {
+	node(n graphNode) error
+	// edges ends the node section.
+	edges() error
+	edge(e graphEdge) error
+	close() error
+}
+
+func newGraphEncoder(w io.Writer, opts GraphOptions) (graphEncoder, error) {
+	switch opts.Format {
+	case GraphFormatGraphML:
+		return newGraphMLEncoder(w)
+	case GraphFormatGEXF:
+		return newGEXFEncoder(w)
+	case GraphFormatDOT:
+		return newDOTEncoder(w)
+	case GraphFormatNeo4j:
+		label := "Host"
+		if opts.Level == GraphLevelURL {
+			label = "Page"
+		}
+		return newNeo4jEncoder(w, label)
+	}
+	return nil, fmt.Errorf("unknown graph format %q", opts.Format)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+type graphMLEncoder struct {
+	w *bufio.Writer
+}
+
+func newGraphMLEncoder(w io.Writer) (*graphMLEncoder, error) {
+	e := &graphMLEncoder{w: bufio.NewWriter(w)}
+	_, err := e.w.WriteString(xml.Header + `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="host" for="node" attr.name="host" attr.type="string"/>
+  <key id="pages" for="node" attr.name="pages" attr.type="long"/>
+  <key id="error_rate" for="node" attr.name="error_rate" attr.type="double"/>
+  <key id="depth" for="node" attr.name="depth" attr.type="int"/>
+  <key id="status" for="node" attr.name="status" attr.type="int"/>
+  <key id="weight" for="edge" attr.name="weight" attr.type="long"/>
+  <graph id="crawl" edgedefault="directed">
+`)
+	return e, err
+}
+
+func (e *graphMLEncoder) node(n graphNode) error {
+	fmt.Fprintf(e.w, `    <node id="%s"><data key="host">%s</data><data key="pages">%d</data><data key="error_rate">%s</data>`,
+		xmlEscape(n.ID), xmlEscape(n.Host), n.Pages, formatFloat(n.ErrorRate))
+	if n.Depth >= 0 {
+		fmt.Fprintf(e.w, `<data key="depth">%d</data>`, n.Depth)
+	}
+	if n.Status != 0 {
+		fmt.Fprintf(e.w, `<data key="status">%d</data>`, n.Status)
+	}
+	_, err := e.w.WriteString("</node>\n")
+	return err
+}
+
+func (e *graphMLEncoder) edges() error { return nil }
+
+func (e *graphMLEncoder) edge(g graphEdge) error {
+	_, err := fmt.Fprintf(e.w, `    <edge source="%s" target="%s"><data key="weight">%d</data></edge>`+"\n", xmlEscape(g.Src), xmlEscape(g.Dst), g.Weight)
+	return err
+}
+
+func (e *graphMLEncoder) close() error {
+	if _, err := e.w.WriteString("  </graph>\n</graphml>\n"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+type gexfEncoder struct {
+	w      *bufio.Writer
+	nextID int64
+}
+
+func newGEXFEncoder(w io.Writer) (*gexfEncoder, error) {
+	e := &gexfEncoder{w: bufio.NewWriter(w)}
+	_, err := e.w.WriteString(xml.Header + `<gexf xmlns="http://gexf.net/1.3" version="1.3">
+  <graph mode="static" defaultedgetype="directed">
+    <attributes class="node">
+      <attribute id="host" title="host" type="string"/>
+      <attribute id="pages" title="pages" type="long"/>
+      <attribute id="error_rate" title="error_rate" type="double"/>
+      <attribute id="depth" title="depth" type="integer"/>
+      <attribute id="status" title="status" type="integer"/>
+    </attributes>
+    <nodes>
+`)
+	return e, err
+}
+
+func (e *gexfEncoder) node(n graphNode) error {
+	id := xmlEscape(n.ID)
+	fmt.Fprintf(e.w, `      <node id="%s" label="%s"><attvalues><attvalue for="host" value="%s"/><attvalue for="pages" value="%d"/><attvalue for="error_rate" value="%s"/>`,
+		id, id, xmlEscape(n.Host), n.Pages, formatFloat(n.ErrorRate))
+	if n.Depth >= 0 {
+		fmt.Fprintf(e.w, `<attvalue for="depth" value="%d"/>`, n.Depth)
+	}
+	if n.Status != 0 {
+		fmt.Fprintf(e.w, `<attvalue for="status" value="%d"/>`, n.Status)
+	}
+	_, err := e.w.WriteString("</attvalues></node>\n")
+	return err
+}
+
+func (e *gexfEncoder) edges() error {
+	_, err := e.w.WriteString("    </nodes>\n    <edges>\n")
+	return err
+}
+
+func (e *gexfEncoder) edge(g graphEdge) error {
+	_, err := fmt.Fprintf(e.w, `      <edge id="%d" source="%s" target="%s" weight="%d"/>`+"\n", e.nextID, xmlEscape(g.Src), xmlEscape(g.Dst), g.Weight)
+	e.nextID++
+	return err
+}
+
+func (e *gexfEncoder) close() error {
+	if _, err := e.w.WriteString("    </edges>\n  </graph>\n</gexf>\n"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+type dotEncoder struct {
+	w *bufio.Writer
+}
+
+func newDOTEncoder(w io.Writer) (*dotEncoder, error) {
+	e := &dotEncoder{w: bufio.NewWriter(w)}
+	_, err := e.w.WriteString("digraph crawl {\n")
+	return e, err
+}
+
+// dotQuote writes s as a DOT quoted string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func (e *dotEncoder) node(n graphNode) error {
+	fmt.Fprintf(e.w, "  %s [host=%s, pages=%d, error_rate=%s", dotQuote(n.ID), dotQuote(n.Host), n.Pages, formatFloat(n.ErrorRate))
+	if n.Depth >= 0 {
+		fmt.Fprintf(e.w, ", depth=%d", n.Depth)
+	}
+	if n.Status != 0 {
+		fmt.Fprintf(e.w, ", status=%d", n.Status)
+	}
+	_, err := e.w.WriteString("];\n")
+	return err
+}
+
+func (e *dotEncoder) edges() error { return nil }
+
+func (e *dotEncoder) edge(g graphEdge) error {
+	_, err := fmt.Fprintf(e.w, "  %s -> %s [weight=%d];\n", dotQuote(g.Src), dotQuote(g.Dst), g.Weight)
+	return err
+}
+
+func (e *dotEncoder) close() error {
+	if _, err := e.w.WriteString("}\n"); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// neo4jEncoder writes the CSV pair read by neo4j-admin database import:
+// nodes.csv followed by relationships.csv in one zip archive.
+type neo4jEncoder struct {
+	zw    *zip.Writer
+	cw    *csv.Writer
+	label string
+}
+
+func newNeo4jEncoder(w io.Writer, label string) (*neo4jEncoder, error) {
+	e := &neo4jEncoder{zw: zip.NewWriter(w), label: label}
+	err := e.file("nodes.csv", []string{"id:ID", "host", "pages:long", "error_rate:double", "depth:int", "status:int", ":LABEL"})
+	return e, err
+}
+
+func (e *neo4jEncoder) file(name string, header []string) error {
+	if e.cw != nil {
+		e.cw.Flush()
+		if err := e.cw.Error(); err != nil {
+			return err
+		}
+	}
+	f, err := e.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	e.cw = csv.NewWriter(f)
+	return e.cw.Write(header)
+}
+
+func (e *neo4jEncoder) node(n graphNode) error {
+	depth, status := "", ""
+	if n.Depth >= 0 {
+		depth = strconv.Itoa(n.Depth)
+	}
+	if n.Status != 0 {
+		status = strconv.Itoa(n.Status)
+	}
+	return e.cw.Write([]string{n.ID, n.Host, strconv.FormatInt(n.Pages, 10), formatFloat(n.ErrorRate), depth, status, e.label})
+}
+
+func (e *neo4jEncoder) edges() error {
+	return e.file("relationships.csv", []string{":START_ID", ":END_ID", "weight:long", ":TYPE"})
+}
+
+func (e *neo4jEncoder) edge(g graphEdge) error {
+	return e.cw.Write([]string{g.Src, g.Dst, strconv.FormatInt(g.Weight, 10), "LINKS_TO"})
+}
+
+func (e *neo4jEncoder) close() error {
+	e.cw.Flush()
+	if err := e.cw.Error(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
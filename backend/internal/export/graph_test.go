@@ -0,0 +1,151 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+func seedGraph(t *testing.T) (storage.Store, uuid.UUID) {
+	t.Helper()
+	r := newTestRun(t)
+	missing := r.page("https://a.test/x", "a.test", 1, 404)
+	missing.ErrClass = "status"
+	r.insertPages(
+		r.page("https://a.test/", "a.test", 0, 200),
+		missing,
+		r.page("https://blog.a.test/", "blog.a.test", 1, 200),
+		r.page("https://b.test/", "b.test", 2, 200),
+	)
+	r.insertLinks(
+		[2]string{"https://a.test/", "https://a.test/x"},
+		[2]string{"https://a.test/", "https://a.test/x"},
+		[2]string{"https://a.test/", "https://blog.a.test/"},
+		[2]string{"https://a.test/", "https://b.test/"},
+		[2]string{"https://b.test/", "https://c.test/never-fetched"},
+	)
+	r.upsertEdges(
+		storage.EdgeRecord{Src: "a.test", Dst: "blog.a.test", Count: 1},
+		storage.EdgeRecord{Src: "a.test", Dst: "b.test", Count: 4},
+		storage.EdgeRecord{Src: "b.test", Dst: "c.test", Count: 2},
+	)
+	return r.store, r.id
+}
+
+type graphML struct {
+	Keys []struct {
+		ID string `xml:"id,attr"`
+	} `xml:"key"`
+	Nodes []struct {
+		ID   string `xml:"id,attr"`
+		Data []struct {
+			Key   string `xml:"key,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"data"`
+	} `xml:"graph>node"`
+	Edges []struct {
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+		Weight string `xml:"data"`
+	} `xml:"graph>edge"`
+}
+
+func TestWriteHostGraphML(t *testing.T) {
+	store, runID := seedGraph(t)
+	var buf bytes.Buffer
+	stats, err := WriteGraph(context.Background(), &buf, store, runID, GraphOptions{Level: GraphLevelHost, Format: GraphFormatGraphML})
+	if err != nil || stats != (GraphStats{Nodes: 4, Edges: 3}) {
+		t.Fatalf("stats %+v (%v)", stats, err)
+	}
+	var g graphML
+	if err := xml.Unmarshal(buf.Bytes(), &g); err != nil {
+		t.Fatalf("parse graphml: %v\n%s", err, buf.String())
+	}
+	if len(g.Keys) != 6 || len(g.Nodes) != 4 || len(g.Edges) != 3 {
+		t.Fatalf("unexpected graph: %+v", g)
+	}
+	a := g.Nodes[0]
+	attrs := map[string]string{}
+	for _, d := range a.Data {
+		attrs[d.Key] = d.Value
+	}
+	if a.ID != "a.test" || attrs["pages"] != "2" || attrs["error_rate"] != "0.5" || attrs["depth"] != "0" {
+		t.Fatalf("unexpected a.test node: %+v", a)
+	}
+	// c.test was linked to but never crawled: no depth
+	if c := g.Nodes[3]; c.ID != "c.test" || len(c.Data) != 3 {
+		t.Fatalf("unexpected c.test node: %+v", c)
+	}
+	if e := g.Edges[0]; e.Source != "a.test" || e.Target != "b.test" || e.Weight != "4" {
+		t.Fatalf("expected heaviest edge first, got %+v", e)
+	}
+}
+
+func TestWriteGraphFilters(t *testing.T) {
+	store, runID := seedGraph(t)
+	var buf bytes.Buffer
+	stats, err := WriteGraph(context.Background(), &buf, store, runID, GraphOptions{Level: GraphLevelHost, Format: GraphFormatDOT, Domain: "a.test"})
+	if err != nil || stats != (GraphStats{Nodes: 2, Edges: 1}) {
+		t.Fatalf("domain filter: stats %+v (%v)\n%s", stats, err, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"a.test" -> "blog.a.test" [weight=1];`) {
+		t.Fatalf("missing subdomain edge:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	stats, err = WriteGraph(context.Background(), &buf, store, runID, GraphOptions{Level: GraphLevelURL, Format: GraphFormatGEXF, MinWeight: 2})
+	if err != nil || stats != (GraphStats{Nodes: 4, Edges: 1}) {
+		t.Fatalf("min weight: stats %+v (%v)", stats, err)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Fatalf("parse gexf: %v", err)
+	}
+	if !strings.Contains(buf.String(), `source="https://a.test/" target="https://a.test/x" weight="2"`) {
+		t.Fatalf("missing weighted edge:\n%s", buf.String())
+	}
+
+	// links to pages that were never fetched leave the URL graph
+	buf.Reset()
+	stats, err = WriteGraph(context.Background(), &buf, store, runID, GraphOptions{Level: GraphLevelURL, Format: GraphFormatDOT})
+	if err != nil || stats != (GraphStats{Nodes: 4, Edges: 3}) {
+		t.Fatalf("url graph: stats %+v (%v)\n%s", stats, err, buf.String())
+	}
+}
+
+func TestWriteGraphNeo4j(t *testing.T) {
+	store, runID := seedGraph(t)
+	var buf bytes.Buffer
+	if _, err := WriteGraph(context.Background(), &buf, store, runID, GraphOptions{Level: GraphLevelURL, Format: GraphFormatNeo4j}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil || len(zr.File) != 2 || zr.File[0].Name != "nodes.csv" || zr.File[1].Name != "relationships.csv" {
+		t.Fatalf("unexpected archive (%v)", err)
+	}
+	read := func(f *zip.File) [][]string {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		records, err := csv.NewReader(rc).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+	nodes, rels := read(zr.File[0]), read(zr.File[1])
+	if len(nodes) != 5 || nodes[0][0] != "id:ID" || nodes[1][0] != "https://a.test/" || nodes[1][6] != "Page" {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+	if len(rels) != 4 || rels[0][0] != ":START_ID" || rels[1][2] != "2" || rels[1][3] != "LINKS_TO" {
+		t.Fatalf("unexpected relationships: %v", rels)
+	}
+}
@@ -15,8 +15,38 @@ var (
 		Name: "crawler_queue_depth",
 		Help: "Queue depth by stage",
 	}, []string{"stage"})
+	LinksChecked = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crawler_links_checked_total",
+		Help: "Total out-of-scope links validated in link-check mode",
+	})
+	URLsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_urls_skipped_total",
+		Help: "Total URLs skipped by reason",
+	}, []string{"reason"})
+	StorageWritesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_storage_writes_dropped_total",
+		Help: "Records not persisted because the write buffer was full, the writer was closed or the batch failed",
+	}, []string{"kind"})
+	StorageBatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_storage_batches_total",
+		Help: "Batches flushed to the store by record kind",
+	}, []string{"kind"})
+	StorageBatchedRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crawler_storage_batched_records_total",
+		Help: "Records flushed to the store by record kind",
+	}, []string{"kind"})
+	StorageWriteLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crawler_storage_write_lag_seconds",
+		Help:    "Time from a record being queued to its batch being flushed",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	StorageQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crawler_storage_queue_depth",
+		Help: "Records waiting in the storage write buffer",
+	})
 )
 
 func init() {
-	prometheus.MustRegister(PagesFetched, FetchErrors, QueueDepth)
+	prometheus.MustRegister(PagesFetched, FetchErrors, QueueDepth, LinksChecked, URLsSkipped,
+		StorageWritesDropped, StorageBatches, StorageBatchedRecords, StorageWriteLag, StorageQueueDepth)
 }
\ No newline at end of file
@@ -16,6 +16,11 @@ type FetchEvent struct {
 	Bytes      int64
 	ReusedConn bool
 	ErrClass   string
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	TTFB       time.Duration
+	Transfer   time.Duration
 }
 
 type EdgeEvent struct {
@@ -24,12 +29,14 @@ type EdgeEvent struct {
 }
 
 type Frame struct {
-	Ts         time.Time   `json:"ts"`
-	Throughput Throughput  `json:"throughput"`
-	Queues     QueueDepths `json:"queues"`
-	Errors     []ErrCount  `json:"errors"`
-	Hosts      []HostFrame `json:"hosts"`
-	GraphDelta GraphDelta  `json:"graph_delta"`
+	Ts         time.Time      `json:"ts"`
+	Status     string         `json:"status,omitempty"`
+	Throughput Throughput     `json:"throughput"`
+	Queues     QueueDepths    `json:"queues"`
+	Errors     []ErrCount     `json:"errors"`
+	Hosts      []HostFrame    `json:"hosts"`
+	Skipped    map[string]int `json:"skipped"`
+	GraphDelta GraphDelta     `json:"graph_delta"`
 }
 
 type Throughput struct {
@@ -48,13 +55,46 @@ type ErrCount struct {
 }
 
 type HostFrame struct {
-	Host        string `json:"host"`
-	Inflight    int    `json:"inflight"`
-	P95Ms       int    `json:"p95_ms"`
+	Host        string  `json:"host"`
+	Inflight    int     `json:"inflight"`
+	P50Ms       int     `json:"p50_ms"`
+	P95Ms       int     `json:"p95_ms"`
 	ErrorRate   float64 `json:"error_rate"`
 	ReuseRate   float64 `json:"reuse_rate"`
-	RobotsState string `json:"robots_state"`
-	Circuit     string `json:"circuit_state"`
+	RobotsState string  `json:"robots_state"`
+	Circuit     string  `json:"circuit_state"`
+	Phases      Phases  `json:"phases"`
+}
+
+type Percentiles struct {
+	P50Ms int `json:"p50_ms"`
+	P95Ms int `json:"p95_ms"`
+}
+
+// Phases are per-host percentiles of the fetch phases captured by
+// httptrace. Samples are only taken when a phase happened, so DNS and
+// connect reflect new connections only.
+type Phases struct {
+	DNS      Percentiles `json:"dns"`
+	Connect  Percentiles `json:"connect"`
+	TLS      Percentiles `json:"tls"`
+	TTFB     Percentiles `json:"ttfb"`
+	Transfer Percentiles `json:"transfer"`
+}
+
+// HostStat is one host's aggregate over a bucket, handed to the host stat
+// sink along with the host's robots and circuit state at the time.
+type HostStat struct {
+	Host        string
+	BucketStart time.Time
+	Requests    int
+	Errors      int
+	Bytes       int64
+	ReuseRate   float64
+	Latency     Percentiles
+	Phases      Phases
+	RobotsState string
+	Circuit     string
 }
 
 type HostSnapshot struct {
@@ -63,43 +103,67 @@ type HostSnapshot struct {
 }
 
 type GraphDelta struct {
-	Nodes []string   `json:"nodes"`
-	Edges [][3]any   `json:"edges"`
+	Nodes []string `json:"nodes"`
+	Edges [][3]any `json:"edges"`
 }
 
 type Telemetry struct {
-	fetchCh     chan FetchEvent
-	edgesCh     chan EdgeEvent
-	queueGetter func() (int, int, int)
-	hostGetter  func() map[string]HostSnapshot
-	robots      *robots.Manager
+	fetchCh      chan FetchEvent
+	edgesCh      chan EdgeEvent
+	skipCh       chan string
+	queueGetter  func() (int, int, int)
+	hostGetter   func() map[string]HostSnapshot
+	statusGetter func() string
+	robots       *robots.Manager
+	hostSink     func([]HostStat)
 
 	mu          sync.Mutex
 	subscribers map[int]chan Frame
 	nextID      int
 
 	hostStats   map[string]*hostMetrics
+	bucketSize  time.Duration
+	bucketStart time.Time
+	bucket      map[string]*hostMetrics
 	errorCounts map[string]int
+	skipCounts  map[string]int
 	nodesSeen   map[string]struct{}
 	edgesSeen   map[string]int
 
 	intervalPages int
 }
 
+const latencyWindow = 200
+
+// HostStatBucket is the width of the per-host time buckets flushed to the
+// host stat sink. Buckets are aligned to the wall clock.
+const HostStatBucket = 10 * time.Second
+
 type hostMetrics struct {
 	latencies []int
 	reqs      int
 	errs      int
 	reuse     int
+	bytes     int64
+
+	dns      []time.Duration
+	connect  []time.Duration
+	tls      []time.Duration
+	ttfb     []time.Duration
+	transfer []time.Duration
 }
 
 func NewTelemetry() *Telemetry {
 	return &Telemetry{
 		fetchCh:     make(chan FetchEvent, 2048),
 		edgesCh:     make(chan EdgeEvent, 2048),
+		skipCh:      make(chan string, 1024),
 		subscribers: make(map[int]chan Frame),
 		hostStats:   make(map[string]*hostMetrics),
+		bucketSize:  HostStatBucket,
+		bucket:      make(map[string]*hostMetrics),
 		errorCounts: make(map[string]int),
+		skipCounts:  make(map[string]int),
 		nodesSeen:   make(map[string]struct{}),
 		edgesSeen:   make(map[string]int),
 	}
@@ -113,10 +177,23 @@ func (t *Telemetry) SetHostGetter(getter func() map[string]HostSnapshot) {
 	t.hostGetter = getter
 }
 
+// SetStatusGetter reports the run status carried by each frame, such as
+// running or paused.
+func (t *Telemetry) SetStatusGetter(getter func() string) {
+	t.statusGetter = getter
+}
+
 func (t *Telemetry) SetRobotsManager(mgr *robots.Manager) {
 	t.robots = mgr
 }
 
+// SetHostStatSink registers a callback that receives the per-host
+// aggregates of each bucket when it closes, and of the partial bucket when
+// the telemetry loop stops. Hosts without fetches in a bucket are left out.
+func (t *Telemetry) SetHostStatSink(sink func([]HostStat)) {
+	t.hostSink = sink
+}
+
 func (t *Telemetry) FetchEvents() chan<- FetchEvent {
 	return t.fetchCh
 }
@@ -125,6 +202,11 @@ func (t *Telemetry) EdgeEvents() chan<- EdgeEvent {
 	return t.edgesCh
 }
 
+// SkipEvents receives skip reasons; frames carry cumulative counts.
+func (t *Telemetry) SkipEvents() chan<- string {
+	return t.skipCh
+}
+
 func (t *Telemetry) Subscribe() (<-chan Frame, func()) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -146,44 +228,79 @@ func (t *Telemetry) Run(ctx context.Context) {
 	frameInterval := 200 * time.Millisecond
 	ticker := time.NewTicker(frameInterval)
 	defer ticker.Stop()
+	t.bucketStart = time.Now().Truncate(t.bucketSize)
 
 	for {
 		select {
 		case <-ctx.Done():
+			t.flushBucket(t.bucketStart)
 			return
 		case ev := <-t.fetchCh:
 			t.onFetch(ev)
 		case ev := <-t.edgesCh:
 			t.onEdge(ev)
-		case <-ticker.C:
+		case reason := <-t.skipCh:
+			t.skipCounts[reason]++
+		case now := <-ticker.C:
 			t.emitFrame(frameInterval)
+			t.rollBucket(now)
 		}
 	}
 }
 
+// rollBucket flushes the current bucket once now has moved past it.
+func (t *Telemetry) rollBucket(now time.Time) {
+	if now.Before(t.bucketStart.Add(t.bucketSize)) {
+		return
+	}
+	t.flushBucket(t.bucketStart)
+	t.bucketStart = now.Truncate(t.bucketSize)
+}
+
+func (t *Telemetry) flushBucket(start time.Time) {
+	if len(t.bucket) > 0 && t.hostSink != nil {
+		t.hostSink(t.hostStatsSnapshot(t.bucket, start))
+	}
+	t.bucket = make(map[string]*hostMetrics)
+}
+
 func (t *Telemetry) onFetch(ev FetchEvent) {
-	stats := t.hostStats[ev.Host]
-	if stats == nil {
-		stats = &hostMetrics{}
-		t.hostStats[ev.Host] = stats
+	// the run-wide stats feed the live frames, the bucket feeds the sink
+	for _, byHost := range []map[string]*hostMetrics{t.hostStats, t.bucket} {
+		stats := byHost[ev.Host]
+		if stats == nil {
+			stats = &hostMetrics{}
+			byHost[ev.Host] = stats
+		}
+		stats.add(ev)
 	}
-	stats.reqs++
 	if ev.ErrClass != "" {
-		stats.errs++
 		t.errorCounts[ev.ErrClass]++
+	} else {
+		t.intervalPages++
+	}
+}
+
+func (m *hostMetrics) add(ev FetchEvent) {
+	m.reqs++
+	if ev.ErrClass != "" {
+		m.errs++
 	}
 	if ev.ReusedConn {
-		stats.reuse++
+		m.reuse++
 	}
+	m.bytes += ev.Bytes
 	if ev.LatencyMS > 0 {
-		stats.latencies = append(stats.latencies, int(ev.LatencyMS))
-		if len(stats.latencies) > 200 {
-			stats.latencies = stats.latencies[len(stats.latencies)-200:]
+		m.latencies = append(m.latencies, int(ev.LatencyMS))
+		if len(m.latencies) > latencyWindow {
+			m.latencies = m.latencies[len(m.latencies)-latencyWindow:]
 		}
 	}
-	if ev.ErrClass == "" {
-		t.intervalPages++
-	}
+	m.dns = appendSample(m.dns, ev.DNS)
+	m.connect = appendSample(m.connect, ev.Connect)
+	m.tls = appendSample(m.tls, ev.TLS)
+	m.ttfb = appendSample(m.ttfb, ev.TTFB)
+	m.transfer = appendSample(m.transfer, ev.Transfer)
 }
 
 func (t *Telemetry) onEdge(ev EdgeEvent) {
@@ -216,23 +333,19 @@ func (t *Telemetry) emitFrame(interval time.Duration) {
 
 	hosts := make([]HostFrame, 0, len(t.hostStats))
 	for host, stats := range t.hostStats {
-		p95 := 0
-		if len(stats.latencies) > 0 {
-			lat := append([]int(nil), stats.latencies...)
-			sort.Ints(lat)
-			idx := int(float64(len(lat)-1) * 0.95)
-			p95 = lat[idx]
-		}
 		errRate := 0.0
 		if stats.reqs > 0 {
 			errRate = float64(stats.errs) / float64(stats.reqs)
 		}
-		reuseRate := 0.0
-		if stats.reqs > 0 {
-			reuseRate = float64(stats.reuse) / float64(stats.reqs)
+		latency := stats.latency()
+		frame := HostFrame{
+			Host:      host,
+			P50Ms:     latency.P50Ms,
+			P95Ms:     latency.P95Ms,
+			ErrorRate: errRate,
+			ReuseRate: stats.reuseRate(),
+			Phases:    stats.phases(),
 		}
-
-		frame := HostFrame{Host: host, P95Ms: p95, ErrorRate: errRate, ReuseRate: reuseRate}
 		if hs, ok := hostSnapshot[host]; ok {
 			frame.Inflight = hs.Inflight
 			frame.Circuit = hs.Circuit
@@ -274,17 +387,26 @@ func (t *Telemetry) emitFrame(interval time.Duration) {
 	t.nodesSeen = make(map[string]struct{})
 	t.edgesSeen = make(map[string]int)
 
+	skipped := make(map[string]int, len(t.skipCounts))
+	for reason, count := range t.skipCounts {
+		skipped[reason] = count
+	}
+
 	pagesPerSec := float64(t.intervalPages) / interval.Seconds()
 	t.intervalPages = 0
 
 	frame := Frame{
-		Ts: time.Now(),
+		Ts:         time.Now(),
 		Throughput: Throughput{PagesPerSec: pagesPerSec},
-		Queues: queues,
-		Errors: errors,
-		Hosts: hosts,
+		Queues:     queues,
+		Errors:     errors,
+		Hosts:      hosts,
+		Skipped:    skipped,
 		GraphDelta: GraphDelta{Nodes: nodes, Edges: edges},
 	}
+	if t.statusGetter != nil {
+		frame.Status = t.statusGetter()
+	}
 
 	t.mu.Lock()
 	for _, ch := range t.subscribers {
@@ -295,3 +417,78 @@ func (t *Telemetry) emitFrame(interval time.Duration) {
 	}
 	t.mu.Unlock()
 }
+
+func (t *Telemetry) hostStatsSnapshot(byHost map[string]*hostMetrics, bucketStart time.Time) []HostStat {
+	hostSnapshot := map[string]HostSnapshot{}
+	if t.hostGetter != nil {
+		hostSnapshot = t.hostGetter()
+	}
+	out := make([]HostStat, 0, len(byHost))
+	for host, stats := range byHost {
+		st := HostStat{
+			Host:        host,
+			BucketStart: bucketStart,
+			Requests:    stats.reqs,
+			Errors:      stats.errs,
+			Bytes:       stats.bytes,
+			ReuseRate:   stats.reuseRate(),
+			Latency:     stats.latency(),
+			Phases:      stats.phases(),
+			Circuit:     hostSnapshot[host].Circuit,
+		}
+		if t.robots != nil {
+			st.RobotsState = string(t.robots.State(host))
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+func (m *hostMetrics) reuseRate() float64 {
+	if m.reqs == 0 {
+		return 0
+	}
+	return float64(m.reuse) / float64(m.reqs)
+}
+
+func (m *hostMetrics) latency() Percentiles {
+	if len(m.latencies) == 0 {
+		return Percentiles{}
+	}
+	lat := append([]int(nil), m.latencies...)
+	sort.Ints(lat)
+	return Percentiles{P50Ms: lat[int(float64(len(lat)-1)*0.5)], P95Ms: lat[int(float64(len(lat)-1)*0.95)]}
+}
+
+func (m *hostMetrics) phases() Phases {
+	return Phases{
+		DNS:      durationPercentiles(m.dns),
+		Connect:  durationPercentiles(m.connect),
+		TLS:      durationPercentiles(m.tls),
+		TTFB:     durationPercentiles(m.ttfb),
+		Transfer: durationPercentiles(m.transfer),
+	}
+}
+
+func appendSample(window []time.Duration, d time.Duration) []time.Duration {
+	if d <= 0 {
+		return window
+	}
+	window = append(window, d)
+	if len(window) > latencyWindow {
+		window = window[len(window)-latencyWindow:]
+	}
+	return window
+}
+
+func durationPercentiles(window []time.Duration) Percentiles {
+	if len(window) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), window...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p50 := sorted[int(float64(len(sorted)-1)*0.5)]
+	p95 := sorted[int(float64(len(sorted)-1)*0.95)]
+	return Percentiles{P50Ms: int(p50.Milliseconds()), P95Ms: int(p95.Milliseconds())}
+}
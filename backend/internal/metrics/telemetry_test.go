@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTelemetryFlushesHostBuckets(t *testing.T) {
+	tel := NewTelemetry()
+	var flushed [][]HostStat
+	tel.SetHostStatSink(func(stats []HostStat) { flushed = append(flushed, stats) })
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tel.bucketStart = start
+
+	tel.onFetch(FetchEvent{Host: "a.test", LatencyMS: 10, Bytes: 100, ReusedConn: true})
+	tel.onFetch(FetchEvent{Host: "a.test", LatencyMS: 30, ErrClass: "timeout"})
+	tel.onFetch(FetchEvent{Host: "b.test", LatencyMS: 5})
+	tel.rollBucket(start.Add(HostStatBucket - time.Millisecond))
+	if len(flushed) != 0 {
+		t.Fatalf("bucket flushed before it closed: %+v", flushed)
+	}
+
+	next := start.Add(HostStatBucket + time.Second)
+	tel.rollBucket(next)
+	if len(flushed) != 1 || len(flushed[0]) != 2 {
+		t.Fatalf("expected one flush with two hosts, got %+v", flushed)
+	}
+	a := flushed[0][0]
+	if a.Host != "a.test" || !a.BucketStart.Equal(start) || a.Requests != 2 || a.Errors != 1 || a.Bytes != 100 || a.ReuseRate != 0.5 || a.Latency.P50Ms != 10 {
+		t.Fatalf("unexpected bucket for a.test: %+v", a)
+	}
+	if !tel.bucketStart.Equal(start.Add(HostStatBucket)) {
+		t.Fatalf("next bucket should be wall-clock aligned, got %v", tel.bucketStart)
+	}
+
+	// an idle bucket is not flushed; the run-wide stats keep accumulating
+	tel.rollBucket(next.Add(HostStatBucket))
+	tel.onFetch(FetchEvent{Host: "a.test", LatencyMS: 20})
+	tel.flushBucket(tel.bucketStart)
+	if len(flushed) != 2 || len(flushed[1]) != 1 || flushed[1][0].Requests != 1 {
+		t.Fatalf("expected only the active bucket to flush, got %+v", flushed)
+	}
+	if tel.hostStats["a.test"].reqs != 3 {
+		t.Fatalf("run-wide stats lost requests: %+v", tel.hostStats["a.test"])
+	}
+}
+
+func TestTelemetryFramesCarrySkipCounts(t *testing.T) {
+	tel := NewTelemetry()
+	frames, unsubscribe := tel.Subscribe()
+	defer unsubscribe()
+
+	tel.skipCounts["robots_denied"] += 2
+	tel.skipCounts["out_of_scope"]++
+	tel.emitFrame(time.Second)
+	tel.skipCounts["robots_denied"]++
+	tel.emitFrame(time.Second)
+
+	first, second := <-frames, <-frames
+	if first.Skipped["robots_denied"] != 2 || first.Skipped["out_of_scope"] != 1 {
+		t.Fatalf("unexpected skip counts in first frame: %+v", first.Skipped)
+	}
+	// counts are cumulative and each frame holds its own copy
+	if second.Skipped["robots_denied"] != 3 || first.Skipped["robots_denied"] != 2 {
+		t.Fatalf("unexpected skip counts in second frame: %+v", second.Skipped)
+	}
+}
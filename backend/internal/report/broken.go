@@ -0,0 +1,173 @@
+package report
+
+import (
+	"context"
+	"net/url"
+	"sort"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/storage"
+)
+
+const (
+	maxBrokenPages  = 5000
+	maxReferrers    = 500
+	redirectElement = "redirect"
+)
+
+type BrokenLink struct {
+	URL           string   `json:"url"`
+	StatusCode    int      `json:"status_code"`
+	ErrorClass    string   `json:"error_class"`
+	ErrorMessage  string   `json:"error_message"`
+	AnchorText    string   `json:"anchor_text"`
+	Element       string   `json:"element"`
+	Internal      bool     `json:"internal"`
+	RedirectChain []string `json:"redirect_chain"`
+}
+
+type BrokenSource struct {
+	SourceURL string       `json:"source_url"`
+	Links     []BrokenLink `json:"links"`
+}
+
+type BrokenLinks struct {
+	BrokenURLs int `json:"broken_urls"`
+	// Truncated is set when the run has more failed URLs than the report
+	// lists.
+	Truncated bool           `json:"truncated"`
+	Sources   []BrokenSource `json:"sources"`
+}
+
+// Broken lists every URL that failed with a 4xx/5xx status or a network
+// error, grouped by the pages that link to it. Crawled pages and link-check
+// results are both included. When the failing URL was only reached through
+// redirects, the referrers of the first hop are reported and the hops are
+// listed in RedirectChain. A link is internal when its target is within the
+// scope cfg was crawled with.
+func Broken(ctx context.Context, store storage.Store, runID uuid.UUID, cfg crawler.RunConfig) (BrokenLinks, error) {
+	failed, err := store.ListFailedPages(ctx, runID, maxBrokenPages+1)
+	if err != nil {
+		return BrokenLinks{}, err
+	}
+	checked, err := store.ListLinkStatuses(ctx, runID, true, maxBrokenPages+1)
+	if err != nil {
+		return BrokenLinks{}, err
+	}
+	var out BrokenLinks
+	if len(failed) > maxBrokenPages {
+		failed, out.Truncated = failed[:maxBrokenPages], true
+	}
+	if len(checked) > maxBrokenPages {
+		checked, out.Truncated = checked[:maxBrokenPages], true
+	}
+
+	seedHost := ""
+	if _, seed, err := crawler.Canonicalize(cfg.SeedURL); err == nil {
+		seedHost = crawler.HostKey(seed)
+	}
+	internal := func(canonical string) bool {
+		u, err := url.Parse(canonical)
+		return err == nil && crawler.InScope(cfg.Scope, seedHost, crawler.HostKey(u))
+	}
+
+	// broken URLs are collected first so their referrers can be fetched in
+	// one batch, keyed by the URL the referrers linked to
+	type brokenURL struct {
+		link   BrokenLink
+		origin string
+	}
+	var broken []brokenURL
+	add := func(canonical string, base BrokenLink, origin string) {
+		base.Internal = internal(canonical)
+		broken = append(broken, brokenURL{link: base, origin: origin})
+	}
+
+	for _, page := range failed {
+		if !IsBroken(page) {
+			continue
+		}
+		chain, origin := []string{}, page.CanonicalURL
+		if len(page.RedirectChain) > 0 {
+			for _, hop := range page.RedirectChain {
+				chain = append(chain, hop.URL)
+			}
+			chain = append(chain, page.CanonicalURL)
+			origin = page.RedirectChain[0].URL
+		}
+		add(page.CanonicalURL, BrokenLink{
+			URL:           page.URL,
+			StatusCode:    page.StatusCode,
+			ErrorClass:    page.ErrorClass,
+			ErrorMessage:  page.ErrorMessage,
+			RedirectChain: chain,
+		}, origin)
+	}
+	for _, status := range checked {
+		if status.StatusCode < 400 && !crawler.Unreachable(status.ErrorClass) {
+			continue
+		}
+		chain := []string{}
+		if status.FinalURL != "" {
+			chain = []string{status.URL, status.FinalURL}
+		}
+		add(status.CanonicalURL, BrokenLink{
+			URL:           status.URL,
+			StatusCode:    status.StatusCode,
+			ErrorClass:    status.ErrorClass,
+			ErrorMessage:  status.ErrorMessage,
+			RedirectChain: chain,
+		}, status.CanonicalURL)
+	}
+
+	origins := make([]string, 0, len(broken))
+	seen := make(map[string]bool, len(broken))
+	for _, b := range broken {
+		if !seen[b.origin] {
+			seen[b.origin] = true
+			origins = append(origins, b.origin)
+		}
+	}
+	inlinks, err := store.ListInlinksTo(ctx, runID, origins, maxReferrers)
+	if err != nil {
+		return BrokenLinks{}, err
+	}
+	referrers := make(map[string][]storage.LinkRow, len(origins))
+	for _, link := range inlinks {
+		if link.Element != redirectElement {
+			referrers[link.DstURL] = append(referrers[link.DstURL], link)
+		}
+	}
+
+	out.BrokenURLs = len(broken)
+	bySource := make(map[string][]BrokenLink)
+	for _, b := range broken {
+		links := referrers[b.origin]
+		if len(links) == 0 {
+			// seeds and pages whose referrers were not recorded
+			bySource[""] = append(bySource[""], b.link)
+			continue
+		}
+		for _, link := range links {
+			item := b.link
+			item.AnchorText = link.AnchorText
+			item.Element = link.Element
+			bySource[link.SrcURL] = append(bySource[link.SrcURL], item)
+		}
+	}
+
+	out.Sources = make([]BrokenSource, 0, len(bySource))
+	for src, links := range bySource {
+		out.Sources = append(out.Sources, BrokenSource{SourceURL: src, Links: links})
+	}
+	sort.Slice(out.Sources, func(i, j int) bool { return out.Sources[i].SourceURL < out.Sources[j].SourceURL })
+	return out, nil
+}
+
+func IsBroken(page storage.PageRow) bool {
+	if page.StatusCode >= 400 {
+		return true
+	}
+	return crawler.Unreachable(page.ErrorClass)
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/storage"
+)
+
+func TestBrokenGroupsBySourceWithRedirectChain(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://site.test/"})
+	now := time.Now()
+	pages := []storage.PageRecord{
+		{RunID: runID, URL: "https://site.test/", CanonicalURL: "https://site.test/", Host: "site.test", StatusCode: 200},
+		{RunID: runID, URL: "https://site.test/old", CanonicalURL: "https://site.test/old", Host: "site.test", StatusCode: 301},
+		{RunID: runID, URL: "https://site.test/gone", CanonicalURL: "https://site.test/gone", Host: "site.test", StatusCode: 404, ErrClass: "status",
+			OriginURL: "https://site.test/old", RedirectChain: []storage.RedirectHop{{URL: "https://site.test/old", StatusCode: 301}}},
+		{RunID: runID, URL: "https://other.test/x", CanonicalURL: "https://other.test/x", Host: "other.test", ErrClass: "dns"},
+		{RunID: runID, URL: "https://site.test/big", CanonicalURL: "https://site.test/big", Host: "site.test", StatusCode: 200, ErrClass: "size_limit"},
+	}
+	for _, p := range pages {
+		p.FetchedAt = &now
+		_ = store.InsertPage(ctx, p)
+	}
+	_ = store.InsertLinks(ctx, []storage.LinkRecord{
+		{RunID: runID, SrcURL: "https://site.test/", DstURL: "https://site.test/old", AnchorText: "Old page", Element: "a", Followed: true},
+		{RunID: runID, SrcURL: "https://site.test/old", DstURL: "https://site.test/gone", Element: "redirect", Followed: true},
+		{RunID: runID, SrcURL: "https://site.test/", DstURL: "https://other.test/x", AnchorText: "Partner", Element: "a", Followed: true},
+		{RunID: runID, SrcURL: "https://site.test/about", DstURL: "https://other.test/x", AnchorText: "Partner site", Element: "a", Followed: true},
+	})
+
+	r, err := Broken(ctx, store, runID, crawler.RunConfig{SeedURL: "https://site.test/", Scope: crawler.ScopeHost})
+	if err != nil {
+		t.Fatalf("broken: %v", err)
+	}
+	if r.BrokenURLs != 2 {
+		t.Fatalf("expected 2 broken urls, got %d", r.BrokenURLs)
+	}
+	if len(r.Sources) != 2 || r.Sources[0].SourceURL != "https://site.test/" || r.Sources[1].SourceURL != "https://site.test/about" {
+		t.Fatalf("unexpected sources: %+v", r.Sources)
+	}
+	home := r.Sources[0].Links
+	if len(home) != 2 {
+		t.Fatalf("expected 2 broken links on home, got %+v", home)
+	}
+	for _, link := range home {
+		switch link.URL {
+		case "https://site.test/gone":
+			if !link.Internal || link.AnchorText != "Old page" || len(link.RedirectChain) != 2 {
+				t.Fatalf("unexpected redirect link: %+v", link)
+			}
+		case "https://other.test/x":
+			if link.Internal || link.ErrorClass != "dns" {
+				t.Fatalf("unexpected external link: %+v", link)
+			}
+		default:
+			t.Fatalf("unexpected link %s", link.URL)
+		}
+	}
+}
+
+func TestBrokenClassifiesInternalByScope(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://www.site.test/"})
+	now := time.Now()
+	for _, p := range []storage.PageRecord{
+		{RunID: runID, URL: "https://site.test/gone", CanonicalURL: "https://site.test/gone", Host: "site.test", StatusCode: 404, ErrClass: "status"},
+		{RunID: runID, URL: "https://other.test/gone", CanonicalURL: "https://other.test/gone", Host: "other.test", StatusCode: 500, ErrClass: "status"},
+	} {
+		p.FetchedAt = &now
+		_ = store.InsertPage(ctx, p)
+	}
+	_ = store.InsertLinks(ctx, []storage.LinkRecord{
+		{RunID: runID, SrcURL: "https://www.site.test/", DstURL: "https://site.test/gone", Element: "a"},
+		{RunID: runID, SrcURL: "https://www.site.test/", DstURL: "https://other.test/gone", Element: "a"},
+	})
+
+	r, err := Broken(ctx, store, runID, crawler.RunConfig{SeedURL: "https://www.site.test/", Scope: crawler.ScopeDomain})
+	if err != nil {
+		t.Fatalf("broken: %v", err)
+	}
+	if r.BrokenURLs != 2 || r.Truncated || len(r.Sources) != 1 {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	for _, link := range r.Sources[0].Links {
+		if want := link.URL == "https://site.test/gone"; link.Internal != want {
+			t.Fatalf("%s: internal = %v, want %v", link.URL, link.Internal, want)
+		}
+	}
+}
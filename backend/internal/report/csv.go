@@ -0,0 +1,39 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func WriteBrokenCSV(w io.Writer, r BrokenLinks) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"source_url", "url", "status_code", "error_class", "error_message", "anchor_text", "element", "internal", "redirect_chain"}); err != nil {
+		return err
+	}
+	for _, src := range r.Sources {
+		for _, link := range src.Links {
+			status := ""
+			if link.StatusCode > 0 {
+				status = strconv.Itoa(link.StatusCode)
+			}
+			record := []string{
+				src.SourceURL,
+				link.URL,
+				status,
+				link.ErrorClass,
+				link.ErrorMessage,
+				link.AnchorText,
+				link.Element,
+				strconv.FormatBool(link.Internal),
+				strings.Join(link.RedirectChain, " -> "),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
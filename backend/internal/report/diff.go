@@ -0,0 +1,289 @@
+package report
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+// Diff categories. A URL stored in both runs can change in several ways at
+// once and then appears once per category.
+const (
+	DiffAdded           = "added"
+	DiffRemoved         = "removed"
+	DiffStatusChanged   = "status_changed"
+	DiffRedirectChanged = "redirect_changed"
+	DiffContentChanged  = "content_changed"
+	DiffTitleChanged    = "title_changed"
+	DiffLinkAdded       = "link_added"
+	DiffLinkRemoved     = "link_removed"
+)
+
+var DiffCategories = []string{DiffAdded, DiffRemoved, DiffStatusChanged, DiffRedirectChanged, DiffContentChanged, DiffTitleChanged, DiffLinkAdded, DiffLinkRemoved}
+
+const diffBatchSize = 1000
+
+// PageSnapshot is what a diff compares of one stored page.
+type PageSnapshot struct {
+	StatusCode  int    `json:"status_code"`
+	ErrorClass  string `json:"error_class,omitempty"`
+	RedirectURL string `json:"redirect_url,omitempty"`
+	Title       string `json:"title,omitempty"`
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+type DiffChange struct {
+	Category string `json:"category"`
+	URL      string `json:"url"`
+	// Link is the target of an added or removed link whose source is URL.
+	Link string        `json:"link,omitempty"`
+	A    *PageSnapshot `json:"a,omitempty"`
+	B    *PageSnapshot `json:"b,omitempty"`
+}
+
+type DiffSummary struct {
+	PagesA int `json:"pages_a"`
+	PagesB int `json:"pages_b"`
+	// Unchanged counts URLs stored in both runs without a page-level change.
+	Unchanged int            `json:"unchanged"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// Diff compares run b against run a by canonical URL and passes every change
+// to emit: page changes in URL order, then link changes in (source, target)
+// order. Pages are merged from both runs in keyset-paged batches; only the
+// set of URLs fetched without error in both runs is kept in memory, to limit
+// link changes to pages both runs could parse. When a URL was stored more
+// than once in a run, its latest row is compared.
+func Diff(ctx context.Context, store storage.Store, a, b uuid.UUID, emit func(DiffChange) error) (DiffSummary, error) {
+	summary := DiffSummary{Counts: make(map[string]int, len(DiffCategories))}
+	for _, category := range DiffCategories {
+		summary.Counts[category] = 0
+	}
+	send := func(c DiffChange) error {
+		summary.Counts[c.Category]++
+		return emit(c)
+	}
+
+	common := map[string]struct{}{}
+	left := newPageStream(ctx, store, a)
+	right := newPageStream(ctx, store, b)
+	pa, err := left.next()
+	if err != nil {
+		return summary, err
+	}
+	pb, err := right.next()
+	if err != nil {
+		return summary, err
+	}
+	for pa != nil || pb != nil {
+		switch {
+		case pb == nil || (pa != nil && pa.CanonicalURL < pb.CanonicalURL):
+			summary.PagesA++
+			if err := send(DiffChange{Category: DiffRemoved, URL: pa.CanonicalURL, A: snapshot(pa)}); err != nil {
+				return summary, err
+			}
+			if pa, err = left.next(); err != nil {
+				return summary, err
+			}
+		case pa == nil || pb.CanonicalURL < pa.CanonicalURL:
+			summary.PagesB++
+			if err := send(DiffChange{Category: DiffAdded, URL: pb.CanonicalURL, B: snapshot(pb)}); err != nil {
+				return summary, err
+			}
+			if pb, err = right.next(); err != nil {
+				return summary, err
+			}
+		default:
+			summary.PagesA++
+			summary.PagesB++
+			changes := comparePages(pa, pb)
+			if len(changes) == 0 {
+				summary.Unchanged++
+			}
+			for _, c := range changes {
+				if err := send(c); err != nil {
+					return summary, err
+				}
+			}
+			if pa.ErrorClass == "" && pb.ErrorClass == "" {
+				common[pa.CanonicalURL] = struct{}{}
+			}
+			if pa, err = left.next(); err != nil {
+				return summary, err
+			}
+			if pb, err = right.next(); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	err = diffLinks(ctx, store, a, b, func(category string, e storage.LinkEdge) error {
+		if _, ok := common[e.Src]; !ok {
+			return nil
+		}
+		return send(DiffChange{Category: category, URL: e.Src, Link: e.Dst})
+	})
+	return summary, err
+}
+
+func snapshot(p *storage.PageRow) *PageSnapshot {
+	return &PageSnapshot{StatusCode: p.StatusCode, ErrorClass: p.ErrorClass, RedirectURL: p.RedirectURL, Title: p.Title, ContentHash: p.ContentHash}
+}
+
+// comparePages only compares titles and hashes when both runs recorded one,
+// so pages stored before they were captured do not all show as changed.
+func comparePages(a, b *storage.PageRow) []DiffChange {
+	var out []DiffChange
+	change := func(category string) {
+		out = append(out, DiffChange{Category: category, URL: a.CanonicalURL, A: snapshot(a), B: snapshot(b)})
+	}
+	if a.StatusCode != b.StatusCode || a.ErrorClass != b.ErrorClass {
+		change(DiffStatusChanged)
+	}
+	if a.RedirectURL != b.RedirectURL {
+		change(DiffRedirectChanged)
+	}
+	if a.ContentHash != "" && b.ContentHash != "" {
+		if a.ContentHash != b.ContentHash {
+			change(DiffContentChanged)
+		}
+		if a.Title != b.Title {
+			change(DiffTitleChanged)
+		}
+	}
+	return out
+}
+
+// pageStream reads a run's pages in canonical URL order, one row per URL.
+type pageStream struct {
+	ctx   context.Context
+	store storage.Store
+	runID uuid.UUID
+	q     storage.PageQuery
+	buf   []storage.PageRow
+	done  bool
+}
+
+func newPageStream(ctx context.Context, store storage.Store, runID uuid.UUID) *pageStream {
+	return &pageStream{ctx: ctx, store: store, runID: runID, q: storage.PageQuery{Sort: storage.PageSortURL, Limit: diffBatchSize}}
+}
+
+func (s *pageStream) peek() (*storage.PageRow, error) {
+	if len(s.buf) == 0 && !s.done {
+		rows, err := s.store.QueryPages(s.ctx, s.runID, s.q)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < s.q.Limit {
+			s.done = true
+		} else {
+			cur := storage.PageCursorFor(rows[len(rows)-1], s.q.Sort)
+			s.q.After = &cur
+		}
+		s.buf = rows
+	}
+	if len(s.buf) == 0 {
+		return nil, nil
+	}
+	return &s.buf[0], nil
+}
+
+// next returns the URL's last row; rows of one URL are ordered by id.
+func (s *pageStream) next() (*storage.PageRow, error) {
+	row, err := s.peek()
+	if row == nil || err != nil {
+		return nil, err
+	}
+	latest := *row
+	s.buf = s.buf[1:]
+	for {
+		dup, err := s.peek()
+		if err != nil {
+			return nil, err
+		}
+		if dup == nil || dup.CanonicalURL != latest.CanonicalURL {
+			return &latest, nil
+		}
+		latest = *dup
+		s.buf = s.buf[1:]
+	}
+}
+
+// diffLinks merges the (source, target) link pairs of both runs. How often a
+// pair is linked is not compared.
+func diffLinks(ctx context.Context, store storage.Store, a, b uuid.UUID, emit func(category string, e storage.LinkEdge) error) error {
+	left := newLinkStream(ctx, store, a)
+	right := newLinkStream(ctx, store, b)
+	la, err := left.next()
+	if err != nil {
+		return err
+	}
+	lb, err := right.next()
+	if err != nil {
+		return err
+	}
+	for la != nil || lb != nil {
+		switch {
+		case lb == nil || (la != nil && linkBefore(*la, *lb)):
+			if err := emit(DiffLinkRemoved, *la); err != nil {
+				return err
+			}
+			la, err = left.next()
+		case la == nil || linkBefore(*lb, *la):
+			if err := emit(DiffLinkAdded, *lb); err != nil {
+				return err
+			}
+			lb, err = right.next()
+		default:
+			if la, err = left.next(); err != nil {
+				return err
+			}
+			lb, err = right.next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func linkBefore(x, y storage.LinkEdge) bool {
+	return x.Src < y.Src || (x.Src == y.Src && x.Dst < y.Dst)
+}
+
+type linkStream struct {
+	ctx   context.Context
+	store storage.Store
+	runID uuid.UUID
+	q     storage.LinkEdgeQuery
+	buf   []storage.LinkEdge
+	done  bool
+}
+
+func newLinkStream(ctx context.Context, store storage.Store, runID uuid.UUID) *linkStream {
+	return &linkStream{ctx: ctx, store: store, runID: runID, q: storage.LinkEdgeQuery{Limit: diffBatchSize}}
+}
+
+func (s *linkStream) next() (*storage.LinkEdge, error) {
+	if len(s.buf) == 0 && !s.done {
+		edges, err := s.store.ListLinkEdges(s.ctx, s.runID, s.q)
+		if err != nil {
+			return nil, err
+		}
+		if len(edges) < s.q.Limit {
+			s.done = true
+		} else {
+			last := edges[len(edges)-1]
+			s.q.After = &last
+		}
+		s.buf = edges
+	}
+	if len(s.buf) == 0 {
+		return nil, nil
+	}
+	e := s.buf[0]
+	s.buf = s.buf[1:]
+	return &e, nil
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+func TestDiffCategorizesChanges(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	a, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://a.test/"})
+	b, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://a.test/"})
+	now := time.Now()
+	page := func(run uuid.UUID, u string, status int, title, hash string) storage.PageRecord {
+		return storage.PageRecord{RunID: run, URL: u, CanonicalURL: u, Host: "a.test", StatusCode: status, Title: title, ContentHash: hash, DiscoveredAt: now, FetchedAt: &now}
+	}
+	moved := page(a, "https://a.test/moved", 301, "", "")
+	moved.RedirectURL = "https://a.test/one"
+	movedAgain := page(b, "https://a.test/moved", 301, "", "")
+	movedAgain.RedirectURL = "https://a.test/two"
+	broken := page(b, "https://a.test/broken", 404, "", "")
+	broken.ErrClass = "status"
+	_ = store.InsertPages(ctx, []storage.PageRecord{
+		page(a, "https://a.test/", 200, "Home", "h1"),
+		page(a, "https://a.test/gone", 200, "Gone", "g"),
+		page(a, "https://a.test/same", 200, "Same", "s"),
+		page(a, "https://a.test/retitled", 200, "Old", "r1"),
+		moved,
+		page(a, "https://a.test/broken", 200, "Broken", "b"),
+	})
+	_ = store.InsertPages(ctx, []storage.PageRecord{
+		page(b, "https://a.test/", 200, "Home", "h2"),
+		broken,
+		page(b, "https://a.test/fresh", 200, "Fresh", "f"),
+		page(b, "https://a.test/same", 200, "Same", "s"),
+		page(b, "https://a.test/retitled", 200, "Stale", "stale"),
+		// stored twice, the later row is compared
+		page(b, "https://a.test/retitled", 200, "New", "r2"),
+		movedAgain,
+	})
+	link := func(run uuid.UUID, src, dst string) storage.LinkRecord {
+		return storage.LinkRecord{RunID: run, SrcURL: src, DstURL: dst, Element: "a"}
+	}
+	_ = store.InsertLinks(ctx, []storage.LinkRecord{
+		link(a, "https://a.test/", "https://a.test/gone"),
+		link(a, "https://a.test/", "https://a.test/same"),
+		link(a, "https://a.test/broken", "https://a.test/"),
+		link(a, "https://a.test/gone", "https://a.test/"),
+	})
+	_ = store.InsertLinks(ctx, []storage.LinkRecord{
+		link(b, "https://a.test/", "https://a.test/fresh"),
+		link(b, "https://a.test/", "https://a.test/same"),
+		link(b, "https://a.test/", "https://a.test/same"),
+		link(b, "https://a.test/fresh", "https://a.test/"),
+	})
+
+	type key struct{ category, url, link string }
+	var got []key
+	summary, err := Diff(ctx, store, a, b, func(c DiffChange) error {
+		got = append(got, key{c.Category, c.URL, c.Link})
+		if c.Category == DiffStatusChanged && (c.A.StatusCode != 200 || c.B.StatusCode != 404) {
+			t.Fatalf("expected 200 -> 404, got %+v %+v", c.A, c.B)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := []key{
+		{DiffContentChanged, "https://a.test/", ""},
+		{DiffStatusChanged, "https://a.test/broken", ""},
+		{DiffAdded, "https://a.test/fresh", ""},
+		{DiffRemoved, "https://a.test/gone", ""},
+		{DiffRedirectChanged, "https://a.test/moved", ""},
+		{DiffContentChanged, "https://a.test/retitled", ""},
+		{DiffTitleChanged, "https://a.test/retitled", ""},
+		// links of /broken and /gone are left out: the pages failed or are gone
+		{DiffLinkAdded, "https://a.test/", "https://a.test/fresh"},
+		{DiffLinkRemoved, "https://a.test/", "https://a.test/gone"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes:\n got %+v\nwant %+v", got, want)
+	}
+	if summary.PagesA != 6 || summary.PagesB != 6 || summary.Unchanged != 1 || summary.Counts[DiffContentChanged] != 2 || summary.Counts[DiffLinkAdded] != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
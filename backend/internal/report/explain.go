@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/storage"
+)
+
+const maxExplainEvents = 1000
+
+var ErrURLNotSeen = errors.New("url was not seen in this run")
+
+type FetchAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code"`
+	ErrorClass   string    `json:"error_class"`
+	FetchMS      int64     `json:"fetch_ms"`
+	RetryDelayMS int64     `json:"retry_delay_ms"`
+	At           time.Time `json:"at"`
+}
+
+type Explanation struct {
+	URL            string                `json:"url"`
+	DiscoveredFrom string                `json:"discovered_from"`
+	Depth          int                   `json:"depth"`
+	InScope        *bool                 `json:"in_scope"`
+	Robots         string                `json:"robots"`
+	QueuedMS       int64                 `json:"queued_ms"`
+	Attempts       []FetchAttempt        `json:"attempts"`
+	OriginURL      string                `json:"origin_url"`
+	RedirectHops   []storage.RedirectHop `json:"redirect_hops"`
+	RedirectedTo   string                `json:"redirected_to"`
+	Parsed         bool                  `json:"parsed"`
+	LinksFound     int                   `json:"links_found"`
+	LinksEnqueued  int                   `json:"links_enqueued"`
+	Skipped        []string              `json:"skipped"`
+	Events         []storage.URLEvent    `json:"events"`
+}
+
+// Explain replays the recorded lifecycle of one canonical URL. Hops that led
+// to the URL come from its page row; the events only describe what happened
+// to the URL itself.
+func Explain(ctx context.Context, store storage.Store, runID uuid.UUID, canonical string) (Explanation, error) {
+	events, err := store.ListURLEvents(ctx, runID, canonical, maxExplainEvents)
+	if err != nil {
+		return Explanation{}, err
+	}
+	page, err := store.GetPage(ctx, runID, canonical)
+	if err != nil {
+		return Explanation{}, err
+	}
+	if len(events) == 0 && page == nil {
+		return Explanation{}, ErrURLNotSeen
+	}
+	out := Explanation{
+		URL:          canonical,
+		Robots:       "not_checked",
+		Attempts:     []FetchAttempt{},
+		RedirectHops: []storage.RedirectHop{},
+		Skipped:      []string{},
+		Events:       events,
+	}
+	if out.Events == nil {
+		out.Events = []storage.URLEvent{}
+	}
+	if page != nil {
+		out.Depth = page.Depth
+		out.DiscoveredFrom = page.Referrer
+		out.OriginURL = page.OriginURL
+		if len(page.RedirectChain) > 0 {
+			out.RedirectHops = page.RedirectChain
+		}
+	}
+	for _, ev := range events {
+		d := ev.Detail
+		switch ev.Event {
+		case crawler.EventDiscovered:
+			out.DiscoveredFrom = detailString(d, "source")
+			out.Depth = int(detailInt(d, "depth"))
+			inScope, _ := d["in_scope"].(bool)
+			out.InScope = &inScope
+		case crawler.EventFetch:
+			out.Robots = detailString(d, "robots")
+			out.QueuedMS += detailInt(d, "queued_ms")
+			out.Attempts = append(out.Attempts, FetchAttempt{
+				Attempt:    int(detailInt(d, "attempt")),
+				StatusCode: int(detailInt(d, "status_code")),
+				ErrorClass: detailString(d, "error_class"),
+				FetchMS:    detailInt(d, "fetch_ms"),
+				At:         ev.At,
+			})
+		case crawler.EventRetry:
+			// a retried attempt never reaches recordFetch, so the retry
+			// event stands in for it
+			out.Robots = detailString(d, "robots")
+			out.QueuedMS += detailInt(d, "queued_ms")
+			out.Attempts = append(out.Attempts, FetchAttempt{
+				Attempt:      int(detailInt(d, "attempt")),
+				ErrorClass:   detailString(d, "error_class"),
+				RetryDelayMS: detailInt(d, "delay_ms"),
+				At:           ev.At,
+			})
+		case crawler.EventRedirect:
+			out.RedirectedTo = detailString(d, "target")
+		case crawler.EventParsed:
+			out.Parsed = true
+			out.LinksFound = int(detailInt(d, "links"))
+			out.LinksEnqueued = int(detailInt(d, "enqueued"))
+		case crawler.EventSkipped:
+			reason := detailString(d, "reason")
+			if reason == crawler.SkipRobotsDenied {
+				out.Robots = "disallowed"
+			}
+			out.Skipped = append(out.Skipped, reason)
+		}
+	}
+	return out, nil
+}
+
+func detailString(d map[string]any, key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+// detailInt accepts both in-memory integers and numbers decoded from JSON.
+func detailInt(d map[string]any, key string) int64 {
+	switch v := d[key].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
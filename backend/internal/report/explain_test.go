@@ -0,0 +1,63 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"webcrawler/internal/crawler"
+	"webcrawler/internal/storage"
+)
+
+func TestExplainSummarizesEvents(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://a.test/"})
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// numbers are float64 as they would be after a round trip through jsonb
+	for i, ev := range []storage.URLEvent{
+		{Event: crawler.EventDiscovered, Detail: map[string]any{"source": "https://a.test/", "depth": 1.0, "in_scope": true}},
+		{Event: crawler.EventRetry, Detail: map[string]any{"attempt": 1.0, "queued_ms": 40.0, "robots": "allowed", "error_class": crawler.ErrTimeout, "delay_ms": 500.0}},
+		{Event: crawler.EventFetch, Detail: map[string]any{"attempt": 2.0, "queued_ms": 510.0, "robots": "allowed", "status_code": 200.0, "fetch_ms": 12.0}},
+		{Event: crawler.EventParsed, Detail: map[string]any{"links": 7.0, "enqueued": 4.0}},
+	} {
+		ev.RunID = runID
+		ev.URL = "https://a.test/page"
+		ev.At = at.Add(time.Duration(i) * time.Second)
+		_ = store.InsertURLEvent(ctx, ev)
+	}
+	_ = store.InsertPage(ctx, storage.PageRecord{
+		RunID:         runID,
+		URL:           "https://a.test/page",
+		CanonicalURL:  "https://a.test/page",
+		Depth:         1,
+		StatusCode:    200,
+		OriginURL:     "https://a.test/old",
+		RedirectChain: []storage.RedirectHop{{URL: "https://a.test/old", StatusCode: 301}},
+	})
+
+	got, err := Explain(ctx, store, runID, "https://a.test/page")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if got.DiscoveredFrom != "https://a.test/" || got.Depth != 1 || got.InScope == nil || !*got.InScope {
+		t.Fatalf("unexpected discovery: %+v", got)
+	}
+	if got.Robots != "allowed" || got.QueuedMS != 550 {
+		t.Fatalf("unexpected scheduling: robots=%s queued=%d", got.Robots, got.QueuedMS)
+	}
+	if len(got.Attempts) != 2 || got.Attempts[0].RetryDelayMS != 500 || got.Attempts[1].StatusCode != 200 {
+		t.Fatalf("unexpected attempts: %+v", got.Attempts)
+	}
+	if !got.Parsed || got.LinksFound != 7 || got.LinksEnqueued != 4 {
+		t.Fatalf("unexpected parse: %+v", got)
+	}
+	if got.OriginURL != "https://a.test/old" || len(got.RedirectHops) != 1 || len(got.Events) != 4 {
+		t.Fatalf("unexpected redirects/events: %+v", got)
+	}
+
+	if _, err := Explain(ctx, store, runID, "https://a.test/never"); !errors.Is(err, ErrURLNotSeen) {
+		t.Fatalf("expected ErrURLNotSeen, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package report
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+type HostPhases struct {
+	DNS      storage.Percentiles `json:"dns"`
+	Connect  storage.Percentiles `json:"connect"`
+	TLS      storage.Percentiles `json:"tls"`
+	TTFB     storage.Percentiles `json:"ttfb"`
+	Transfer storage.Percentiles `json:"transfer"`
+}
+
+type Host struct {
+	storage.HostCounts
+	Requests      int                 `json:"requests"`
+	RequestErrors int                 `json:"request_errors"`
+	ErrorRate     float64             `json:"error_rate"`
+	ReuseRate     float64             `json:"reuse_rate"`
+	Latency       storage.Percentiles `json:"latency"`
+	Phases        HostPhases          `json:"phases"`
+	RobotsState   string              `json:"robots_state"`
+	CircuitState  string              `json:"circuit_state"`
+}
+
+type HostsReport struct {
+	Hosts int    `json:"hosts"`
+	Items []Host `json:"items"`
+}
+
+// Hosts joins the per-host page counts with the persisted fetch telemetry
+// and robots/circuit state, busiest hosts first. A host that only shows up
+// in one of the sources still gets a row. When telemetry spans several
+// buckets the percentiles are request-weighted means of the buckets', which
+// approximates but does not equal the run-wide percentile.
+func Hosts(ctx context.Context, store storage.Store, runID uuid.UUID, limit int) (HostsReport, error) {
+	counts, err := store.HostPageCounts(ctx, runID)
+	if err != nil {
+		return HostsReport{}, err
+	}
+	stats, err := store.ListHostStats(ctx, runID)
+	if err != nil {
+		return HostsReport{}, err
+	}
+	states, err := store.ListHostStates(ctx, runID)
+	if err != nil {
+		return HostsReport{}, err
+	}
+	byHost := map[string]*Host{}
+	get := func(name string) *Host {
+		h := byHost[name]
+		if h == nil {
+			h = &Host{HostCounts: storage.HostCounts{Host: name}}
+			byHost[name] = h
+		}
+		return h
+	}
+	for _, c := range counts {
+		get(c.Host).HostCounts = c
+	}
+	buckets := map[string][]storage.HostStatRecord{}
+	for _, st := range stats {
+		buckets[st.Host] = append(buckets[st.Host], st)
+	}
+	for name, recs := range buckets {
+		mergeHostStats(get(name), recs)
+	}
+	for _, st := range states {
+		h := get(st.Host)
+		h.RobotsState, h.CircuitState = st.RobotsState, st.CircuitState
+	}
+
+	out := HostsReport{Hosts: len(byHost), Items: make([]Host, 0, len(byHost))}
+	for _, h := range byHost {
+		out.Items = append(out.Items, *h)
+	}
+	sort.Slice(out.Items, func(i, j int) bool {
+		a, b := out.Items[i], out.Items[j]
+		if a.Pages != b.Pages {
+			return a.Pages > b.Pages
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Host < b.Host
+	})
+	if limit > 0 && len(out.Items) > limit {
+		out.Items = out.Items[:limit]
+	}
+	return out, nil
+}
+
+func mergeHostStats(h *Host, recs []storage.HostStatRecord) {
+	var reused float64
+	var lat, dns, connect, tls, ttfb, transfer weightedPercentiles
+	for _, rec := range recs {
+		h.Requests += rec.Requests
+		h.RequestErrors += rec.Errors
+		reused += rec.ReuseRate * float64(rec.Requests)
+		lat.add(rec.Latency, rec.Requests)
+		dns.add(rec.DNS, rec.Requests)
+		connect.add(rec.Connect, rec.Requests)
+		tls.add(rec.TLS, rec.Requests)
+		ttfb.add(rec.TTFB, rec.Requests)
+		transfer.add(rec.Transfer, rec.Requests)
+	}
+	if h.Requests > 0 {
+		h.ErrorRate = float64(h.RequestErrors) / float64(h.Requests)
+		h.ReuseRate = reused / float64(h.Requests)
+	}
+	h.Latency = lat.value()
+	h.Phases = HostPhases{DNS: dns.value(), Connect: connect.value(), TLS: tls.value(), TTFB: ttfb.value(), Transfer: transfer.value()}
+}
+
+// weightedPercentiles averages bucket percentiles by request count, skipping
+// buckets that never saw the phase.
+type weightedPercentiles struct {
+	p50, p95 float64
+	weight   int
+}
+
+func (w *weightedPercentiles) add(p storage.Percentiles, requests int) {
+	if requests <= 0 || (p.P50MS == 0 && p.P95MS == 0) {
+		return
+	}
+	w.p50 += float64(p.P50MS * requests)
+	w.p95 += float64(p.P95MS * requests)
+	w.weight += requests
+}
+
+func (w *weightedPercentiles) value() storage.Percentiles {
+	if w.weight == 0 {
+		return storage.Percentiles{}
+	}
+	return storage.Percentiles{P50MS: int(w.p50/float64(w.weight) + 0.5), P95MS: int(w.p95/float64(w.weight) + 0.5)}
+}
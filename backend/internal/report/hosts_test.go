@@ -0,0 +1,56 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"webcrawler/internal/storage"
+)
+
+func TestHostsMergesCountsTelemetryAndState(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://a.test/"})
+	now := time.Now()
+	_ = store.InsertPages(ctx, []storage.PageRecord{
+		{RunID: runID, URL: "https://a.test/", CanonicalURL: "https://a.test/", Host: "a.test", StatusCode: 200, SizeBytes: 10, DiscoveredAt: now, FetchedAt: &now},
+		{RunID: runID, URL: "https://a.test/x", CanonicalURL: "https://a.test/x", Host: "a.test", StatusCode: 500, ErrClass: "http_5xx", DiscoveredAt: now, FetchedAt: &now},
+		{RunID: runID, URL: "https://b.test/", CanonicalURL: "https://b.test/", Host: "b.test", StatusCode: 200, DiscoveredAt: now, FetchedAt: &now},
+	})
+	_ = store.UpsertHostStat(ctx, storage.HostStatRecord{RunID: runID, Host: "a.test", BucketStart: now, Requests: 1, Errors: 0, ReuseRate: 0,
+		Latency: storage.Percentiles{P50MS: 10, P95MS: 20}, DNS: storage.Percentiles{P50MS: 4, P95MS: 4}})
+	_ = store.UpsertHostStat(ctx, storage.HostStatRecord{RunID: runID, Host: "a.test", BucketStart: now.Add(time.Second), Requests: 3, Errors: 1, ReuseRate: 1,
+		Latency: storage.Percentiles{P50MS: 30, P95MS: 40}})
+	_ = store.UpsertHostState(ctx, storage.HostStateRecord{RunID: runID, Host: "a.test", RobotsState: "ready", CircuitState: "open"})
+	_ = store.UpsertHostState(ctx, storage.HostStateRecord{RunID: runID, Host: "c.test", RobotsState: "error"})
+
+	r, err := Hosts(ctx, store, runID, 0)
+	if err != nil {
+		t.Fatalf("hosts: %v", err)
+	}
+	if r.Hosts != 3 || len(r.Items) != 3 {
+		t.Fatalf("expected three hosts, got %+v", r)
+	}
+	a := r.Items[0]
+	if a.Host != "a.test" || a.Pages != 2 || a.Failed != 1 || a.Status5xx != 1 || a.Bytes != 10 {
+		t.Fatalf("unexpected counts for a.test: %+v", a.HostCounts)
+	}
+	if a.Requests != 4 || a.RequestErrors != 1 || a.ErrorRate != 0.25 || a.ReuseRate != 0.75 {
+		t.Fatalf("unexpected telemetry for a.test: %+v", a)
+	}
+	if a.Latency.P50MS != 25 || a.Latency.P95MS != 35 || a.Phases.DNS.P50MS != 4 {
+		t.Fatalf("expected request-weighted percentiles, got %+v %+v", a.Latency, a.Phases.DNS)
+	}
+	if a.RobotsState != "ready" || a.CircuitState != "open" {
+		t.Fatalf("unexpected state for a.test: %+v", a)
+	}
+	if c := r.Items[2]; c.Host != "c.test" || c.Pages != 0 || c.RobotsState != "error" {
+		t.Fatalf("expected state-only host last, got %+v", c)
+	}
+
+	limited, _ := Hosts(ctx, store, runID, 1)
+	if limited.Hosts != 3 || len(limited.Items) != 1 {
+		t.Fatalf("limit should trim items only, got %+v", limited)
+	}
+}
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"webcrawler/internal/storage"
+)
+
+type SecurityHost struct {
+	storage.HostSecurityRecord
+	ExpiresInDays *int     `json:"expires_in_days"`
+	Issues        []string `json:"issues"`
+}
+
+type SecurityReport struct {
+	ExpiringWithinDays int            `json:"expiring_within_days"`
+	Hosts              int            `json:"hosts"`
+	ExpiringCerts      int            `json:"expiring_certs"`
+	UnverifiedCerts    int            `json:"unverified_certs"`
+	MissingHSTS        int            `json:"missing_hsts"`
+	Items              []SecurityHost `json:"items"`
+}
+
+const (
+	IssueCertExpired    = "cert_expired"
+	IssueCertExpiring   = "cert_expiring"
+	IssueCertUnverified = "cert_unverified"
+	IssueMissingHSTS    = "missing_hsts"
+)
+
+// Security flags HTTPS hosts whose certificate expires within the given
+// number of days (or already has), failed verification, or that answered
+// over a verified session without Strict-Transport-Security. When
+// flaggedOnly is set, hosts without issues are left out of Items but still
+// counted in Hosts.
+func Security(ctx context.Context, store storage.Store, runID uuid.UUID, withinDays int, flaggedOnly bool, now time.Time) (SecurityReport, error) {
+	hosts, err := store.ListHostSecurity(ctx, runID)
+	if err != nil {
+		return SecurityReport{}, err
+	}
+	out := SecurityReport{ExpiringWithinDays: withinDays, Hosts: len(hosts), Items: []SecurityHost{}}
+	horizon := now.Add(time.Duration(withinDays) * 24 * time.Hour)
+	for _, h := range hosts {
+		item := SecurityHost{HostSecurityRecord: h, Issues: []string{}}
+		if h.CertNotAfter != nil {
+			days := int(h.CertNotAfter.Sub(now).Hours() / 24)
+			item.ExpiresInDays = &days
+			switch {
+			case h.CertNotAfter.Before(now):
+				item.Issues = append(item.Issues, IssueCertExpired)
+				out.ExpiringCerts++
+			case h.CertNotAfter.Before(horizon):
+				item.Issues = append(item.Issues, IssueCertExpiring)
+				out.ExpiringCerts++
+			}
+		}
+		if !h.CertVerified {
+			item.Issues = append(item.Issues, IssueCertUnverified)
+			out.UnverifiedCerts++
+		}
+		// headers are only captured from responses over a verified session
+		if h.CertVerified && h.HSTS == "" {
+			item.Issues = append(item.Issues, IssueMissingHSTS)
+			out.MissingHSTS++
+		}
+		if flaggedOnly && len(item.Issues) == 0 {
+			continue
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
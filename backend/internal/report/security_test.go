@@ -0,0 +1,46 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"webcrawler/internal/storage"
+)
+
+func TestSecurityFlagsExpiringAndMissingHSTS(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	runID, _ := store.CreateRun(ctx, storage.RunConfig{SeedURL: "https://a.test/"})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	soon := now.Add(10 * 24 * time.Hour)
+	later := now.Add(200 * 24 * time.Hour)
+	for _, rec := range []storage.HostSecurityRecord{
+		{RunID: runID, Host: "a.test", CertVerified: true, CertNotAfter: &later, HSTS: "max-age=31536000"},
+		{RunID: runID, Host: "b.test", CertVerified: true, CertNotAfter: &soon, HSTS: "max-age=31536000"},
+		{RunID: runID, Host: "c.test", CertVerified: true, CertNotAfter: &later},
+		{RunID: runID, Host: "d.test", CertNotAfter: &later, CertError: "x509: certificate signed by unknown authority"},
+	} {
+		_ = store.UpsertHostSecurity(ctx, rec)
+	}
+
+	r, err := Security(ctx, store, runID, 30, true, now)
+	if err != nil {
+		t.Fatalf("security: %v", err)
+	}
+	if r.Hosts != 4 || r.ExpiringCerts != 1 || r.MissingHSTS != 1 || r.UnverifiedCerts != 1 {
+		t.Fatalf("unexpected counts: %+v", r)
+	}
+	if len(r.Items) != 3 {
+		t.Fatalf("expected 3 flagged hosts, got %+v", r.Items)
+	}
+	want := map[string]string{"b.test": IssueCertExpiring, "c.test": IssueMissingHSTS, "d.test": IssueCertUnverified}
+	for _, item := range r.Items {
+		if len(item.Issues) != 1 || item.Issues[0] != want[item.Host] {
+			t.Fatalf("unexpected issues for %s: %v", item.Host, item.Issues)
+		}
+	}
+	if days := *r.Items[0].ExpiresInDays; days != 10 {
+		t.Fatalf("expected b.test to expire in 10 days, got %d", days)
+	}
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const maxTextBytes = 64 << 10
+
+var boilerplateTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"iframe":   true,
+	"nav":      true,
+	"header":   true,
+	"footer":   true,
+	"aside":    true,
+	"form":     true,
+	"button":   true,
+	"select":   true,
+}
+
+var mainTags = map[string]bool{
+	"main":    true,
+	"article": true,
+}
+
+type Document struct {
+	Title string
+	Text  string
+}
+
+// Extract strips boilerplate elements and returns the readable text of an
+// HTML page. Text inside <main> or <article> wins over the rest of the body
+// when present.
+func Extract(body []byte) Document {
+	tok := html.NewTokenizer(bytes.NewReader(body))
+	var title strings.Builder
+	var all, main textBuffer
+	skipTag := ""
+	skipNest := 0
+	mainDepth := 0
+	inTitle := false
+
+	for {
+		tt := tok.Next()
+		switch tt {
+		case html.ErrorToken:
+			text := main.String()
+			if len(text) < 200 {
+				text = all.String()
+			}
+			return Document{Title: collapse(title.String()), Text: text}
+		case html.StartTagToken:
+			name, hasAttr := tok.TagName()
+			tag := string(name)
+			if skipNest > 0 {
+				if tag == skipTag {
+					skipNest++
+				}
+				continue
+			}
+			if tag == "title" {
+				inTitle = true
+				continue
+			}
+			if boilerplateTags[tag] || (hasAttr && isBoilerplateRole(tok)) {
+				skipTag = tag
+				skipNest = 1
+				continue
+			}
+			if mainTags[tag] {
+				mainDepth++
+			}
+		case html.EndTagToken:
+			name, _ := tok.TagName()
+			tag := string(name)
+			if skipNest > 0 {
+				if tag == skipTag {
+					skipNest--
+				}
+				continue
+			}
+			if tag == "title" {
+				inTitle = false
+				continue
+			}
+			if mainTags[tag] && mainDepth > 0 {
+				mainDepth--
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tok.Text())
+				continue
+			}
+			if skipNest > 0 {
+				continue
+			}
+			text := tok.Text()
+			all.write(text)
+			if mainDepth > 0 {
+				main.write(text)
+			}
+		}
+	}
+}
+
+func isBoilerplateRole(tok *html.Tokenizer) bool {
+	for {
+		key, val, more := tok.TagAttr()
+		if string(key) == "role" {
+			switch strings.ToLower(string(val)) {
+			case "navigation", "banner", "contentinfo", "complementary":
+				return true
+			}
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+type textBuffer struct {
+	b strings.Builder
+}
+
+func (t *textBuffer) write(raw []byte) {
+	for _, word := range strings.Fields(string(raw)) {
+		if t.b.Len() >= maxTextBytes {
+			return
+		}
+		if t.b.Len() > 0 {
+			t.b.WriteByte(' ')
+		}
+		t.b.WriteString(word)
+	}
+}
+
+func (t *textBuffer) String() string {
+	return t.b.String()
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
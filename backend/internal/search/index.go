@@ -0,0 +1,218 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	bm25K1      = 1.2
+	bm25B       = 0.75
+	titleBoost  = 2
+	snippetSize = 160
+)
+
+type Index struct {
+	mu       sync.RWMutex
+	docs     []doc
+	byURL    map[string]int
+	postings map[string][]posting
+	totalLen int
+}
+
+type doc struct {
+	URL    string
+	Title  string
+	Text   string
+	Length int
+}
+
+type posting struct {
+	Doc int
+	TF  int
+}
+
+type Hit struct {
+	URL     string  `json:"url"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+func NewIndex() *Index {
+	return &Index{
+		byURL:    make(map[string]int),
+		postings: make(map[string][]posting),
+	}
+}
+
+func (idx *Index) Add(url string, d Document) {
+	if url == "" || (d.Title == "" && d.Text == "") {
+		return
+	}
+	freqs := make(map[string]int)
+	length := 0
+	for _, term := range tokenize(d.Title) {
+		freqs[term] += titleBoost
+		length += titleBoost
+	}
+	for _, term := range tokenize(d.Text) {
+		freqs[term]++
+		length++
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.byURL[url]; ok {
+		return
+	}
+	id := len(idx.docs)
+	idx.docs = append(idx.docs, doc{URL: url, Title: d.Title, Text: d.Text, Length: length})
+	idx.byURL[url] = id
+	idx.totalLen += length
+	for term, tf := range freqs {
+		idx.postings[term] = append(idx.postings[term], posting{Doc: id, TF: tf})
+	}
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search ranks documents with BM25 and returns the top hits with a
+// highlighted snippet around the first query term match.
+func (idx *Index) Search(query string, limit int) ([]Hit, int) {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return nil, 0
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := len(idx.docs)
+	if n == 0 {
+		return nil, 0
+	}
+	avgLen := float64(idx.totalLen) / float64(n)
+	scores := make(map[int]float64)
+	for _, term := range terms {
+		plist := idx.postings[term]
+		if len(plist) == 0 {
+			continue
+		}
+		idf := math.Log(1 + (float64(n)-float64(len(plist))+0.5)/(float64(len(plist))+0.5))
+		for _, p := range plist {
+			tf := float64(p.TF)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.docs[p.Doc].Length)/avgLen)
+			scores[p.Doc] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	ranked := make([]int, 0, len(scores))
+	for id := range scores {
+		ranked = append(ranked, id)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i]] == scores[ranked[j]] {
+			return idx.docs[ranked[i]].URL < idx.docs[ranked[j]].URL
+		}
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	total := len(ranked)
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	hits := make([]Hit, 0, len(ranked))
+	for _, id := range ranked {
+		d := idx.docs[id]
+		hits = append(hits, Hit{URL: d.URL, Title: d.Title, Score: scores[id], Snippet: snippet(d.Text, terms)})
+	}
+	return hits, total
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]struct{}, len(terms))
+	out := terms[:0]
+	for _, t := range terms {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// snippet returns an HTML-escaped window of text around the earliest query
+// term, with every term occurrence wrapped in <mark>.
+func snippet(text string, terms []string) string {
+	if text == "" {
+		return ""
+	}
+	words := strings.Fields(text)
+	match := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		match[t] = true
+	}
+	first := -1
+	for i, w := range words {
+		if wordMatches(w, match) {
+			first = i
+			break
+		}
+	}
+	start := 0
+	if first > 8 {
+		start = first - 8
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	end := start
+	for i := start; i < len(words) && b.Len() < snippetSize*2; i++ {
+		if i > start {
+			b.WriteByte(' ')
+		}
+		w := html.EscapeString(words[i])
+		if wordMatches(words[i], match) {
+			b.WriteString("<mark>")
+			b.WriteString(w)
+			b.WriteString("</mark>")
+		} else {
+			b.WriteString(w)
+		}
+		end = i + 1
+		if end-start >= 30 {
+			break
+		}
+	}
+	if end < len(words) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+func wordMatches(word string, match map[string]bool) bool {
+	for _, t := range tokenize(word) {
+		if match[t] {
+			return true
+		}
+	}
+	return false
+}
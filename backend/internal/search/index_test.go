@@ -0,0 +1,97 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestExtractSkipsBoilerplate(t *testing.T) {
+	page := `<html><head><title>Gophers</title><script>var x = "hidden";</script></head>
+	<body><nav><a href="/">Home</a></nav>
+	<div role="navigation"><div>menu</div> links</div>
+	<main><h1>Burrows</h1><p>Gophers dig &amp; live in burrows.</p></main>
+	<footer>copyright</footer></body></html>`
+	d := Extract([]byte(page))
+	if d.Title != "Gophers" {
+		t.Fatalf("title=%q", d.Title)
+	}
+	for _, bad := range []string{"hidden", "Home", "menu", "links", "copyright"} {
+		if strings.Contains(d.Text, bad) {
+			t.Fatalf("text %q should not contain %q", d.Text, bad)
+		}
+	}
+	if !strings.Contains(d.Text, "Gophers dig & live in burrows.") {
+		t.Fatalf("missing body text: %q", d.Text)
+	}
+}
+
+func TestIndexSearchRanksAndHighlights(t *testing.T) {
+	idx := NewIndex()
+	idx.Add("https://a.test/", Document{Title: "Home", Text: "welcome to the site about gardening"})
+	idx.Add("https://a.test/tomatoes", Document{Title: "Tomatoes", Text: "tomatoes need sun. grow tomatoes in summer"})
+	idx.Add("https://a.test/soil", Document{Title: "Soil", Text: "good soil helps tomatoes"})
+
+	hits, total := idx.Search("Tomatoes", 10)
+	if total != 2 || len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d/%d", len(hits), total)
+	}
+	if hits[0].URL != "https://a.test/tomatoes" {
+		t.Fatalf("unexpected top hit %s", hits[0].URL)
+	}
+	if !strings.Contains(hits[0].Snippet, "<mark>tomatoes</mark>") {
+		t.Fatalf("snippet not highlighted: %q", hits[0].Snippet)
+	}
+	if hits, _ := idx.Search("   ", 10); hits != nil {
+		t.Fatal("blank query should return nothing")
+	}
+}
+
+func TestManagerPersistsIndex(t *testing.T) {
+	dir := t.TempDir()
+	runID := uuid.New()
+	m := NewManager(dir)
+	m.Open(runID).Add("https://a.test/", Document{Title: "Hello", Text: "persisted words"})
+	if err := m.Save(runID); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := NewManager(dir).Get(runID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if hits, _ := reloaded.Search("persisted", 5); len(hits) != 1 {
+		t.Fatalf("expected reloaded hit, got %d", len(hits))
+	}
+	if _, err := NewManager(dir).Get(uuid.New()); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex, got %v", err)
+	}
+}
+
+func TestManagerEvictsSavedIndexes(t *testing.T) {
+	m := NewManager(t.TempDir())
+	runs := make([]uuid.UUID, maxLoaded+2)
+	for i := range runs {
+		runs[i] = uuid.New()
+		m.Open(runs[i]).Add("https://a.test/", Document{Text: "saved words"})
+		if err := m.Save(runs[i]); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	if len(m.open) != 0 {
+		t.Fatalf("expected saved indexes to leave memory, %d still open", len(m.open))
+	}
+	for _, runID := range runs {
+		idx, err := m.Get(runID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if hits, _ := idx.Search("saved", 5); len(hits) != 1 {
+			t.Fatalf("expected a hit from the reloaded index, got %d", len(hits))
+		}
+	}
+	if len(m.loaded) != maxLoaded || m.loaded[0].runID != runs[2] {
+		t.Fatalf("expected the %d most recent indexes loaded, got %d", maxLoaded, len(m.loaded))
+	}
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoIndex = errors.New("search index not found")
+
+// maxLoaded bounds the saved indexes kept in memory for searching.
+const maxLoaded = 8
+
+// Manager keeps one index per run. When dir is set, indexes are written to
+// <dir>/<run id>.idx on Save and dropped from memory; Get loads them back
+// and keeps the most recently searched ones. Without dir every index stays
+// in memory.
+type Manager struct {
+	dir string
+	mu  sync.Mutex
+	// open holds the indexes still being written to, and every index when
+	// there is no dir to save them to.
+	open map[uuid.UUID]*Index
+	// loaded holds indexes read back by Get, least recently used first.
+	loaded []loadedIndex
+}
+
+type loadedIndex struct {
+	runID uuid.UUID
+	idx   *Index
+}
+
+type snapshot struct {
+	Docs []doc
+}
+
+func NewManager(dir string) *Manager {
+	return &Manager{dir: dir, open: make(map[uuid.UUID]*Index)}
+}
+
+func (m *Manager) Open(runID uuid.UUID) *Index {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if idx, ok := m.open[runID]; ok {
+		return idx
+	}
+	idx := m.takeLoaded(runID)
+	if idx == nil {
+		var err error
+		if idx, err = m.load(runID); err != nil {
+			idx = NewIndex()
+		}
+	}
+	m.open[runID] = idx
+	return idx
+}
+
+func (m *Manager) Get(runID uuid.UUID) (*Index, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if idx, ok := m.open[runID]; ok {
+		return idx, nil
+	}
+	idx := m.takeLoaded(runID)
+	if idx == nil {
+		var err error
+		if idx, err = m.load(runID); err != nil {
+			return nil, err
+		}
+	}
+	m.loaded = append(m.loaded, loadedIndex{runID: runID, idx: idx})
+	if len(m.loaded) > maxLoaded {
+		m.loaded = m.loaded[1:]
+	}
+	return idx, nil
+}
+
+// takeLoaded removes runID's index from the loaded list and returns it, or
+// nil. Callers hold m.mu.
+func (m *Manager) takeLoaded(runID uuid.UUID) *Index {
+	for i, l := range m.loaded {
+		if l.runID == runID {
+			m.loaded = append(m.loaded[:i], m.loaded[i+1:]...)
+			return l.idx
+		}
+	}
+	return nil
+}
+
+func (m *Manager) Save(runID uuid.UUID) error {
+	if m.dir == "" {
+		return nil
+	}
+	m.mu.Lock()
+	idx, ok := m.open[runID]
+	m.mu.Unlock()
+	if !ok {
+		return ErrNoIndex
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	idx.mu.RLock()
+	snap := snapshot{Docs: idx.docs}
+	idx.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(m.dir, runID.String()+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	zw := gzip.NewWriter(tmp)
+	if err := gob.NewEncoder(zw).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), m.path(runID)); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	if m.open[runID] == idx {
+		delete(m.open, runID)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) load(runID uuid.UUID) (*Index, error) {
+	if m.dir == "" {
+		return nil, ErrNoIndex
+	}
+	f, err := os.Open(m.path(runID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoIndex
+		}
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var snap snapshot
+	if err := gob.NewDecoder(zr).Decode(&snap); err != nil {
+		return nil, err
+	}
+	idx := NewIndex()
+	for _, d := range snap.Docs {
+		idx.Add(d.URL, Document{Title: d.Title, Text: d.Text})
+	}
+	return idx, nil
+}
+
+func (m *Manager) path(runID uuid.UUID) string {
+	return filepath.Join(m.dir, runID.String()+".idx")
+}
@@ -0,0 +1,913 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The conformance suite runs the same checks against every Store so the
+// backends stay interchangeable. Postgres runs only when TEST_DATABASE_URL
+// points at a database the tests may write to.
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) Store { return NewMemory() })
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) Store {
+		store, err := OpenSQLite(filepath.Join(t.TempDir(), "crawler.db"))
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { store.DB().Close() })
+		if err := store.Migrate(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return store
+	})
+}
+
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	runConformance(t, func(t *testing.T) Store {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Fatalf("open postgres: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		store := NewSQL(db)
+		if err := store.Migrate(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return store
+	})
+}
+
+func runConformance(t *testing.T, open func(t *testing.T) Store) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, store Store, runID uuid.UUID)
+	}{
+		{"runs", conformRuns},
+		{"list_runs", conformListRuns},
+		{"pages", conformPages},
+		{"query_pages", conformQueryPages},
+		{"links", conformLinks},
+		{"link_status", conformLinkStatus},
+		{"redirect_chains", conformRedirectChains},
+		{"host_stats", conformHostStats},
+		{"host_security", conformHostSecurity},
+		{"host_summaries", conformHostSummaries},
+		{"error_groups", conformErrorGroups},
+		{"errors", conformErrors},
+		{"edges", conformEdges},
+		{"skipped", conformSkipped},
+		{"url_events", conformURLEvents},
+		{"run_analytics", conformRunAnalytics},
+		{"schedules", conformSchedules},
+		{"run_events", conformRunEvents},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := open(t)
+			runID, err := store.CreateRun(context.Background(), RunConfig{SeedURL: "https://a.test/", MaxDepth: 2, RespectRobots: true})
+			if err != nil {
+				t.Fatalf("create run: %v", err)
+			}
+			tc.fn(t, store, runID)
+		})
+	}
+}
+
+func conformRuns(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	started := time.Now().UTC().Truncate(time.Millisecond)
+	if err := store.UpdateRunStatus(ctx, runID, "running", &started, nil, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	reason := "manual"
+	stopped := started.Add(time.Second)
+	if err := store.UpdateRunStatus(ctx, runID, "stopped", nil, &stopped, &reason); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	run, err := store.GetRun(ctx, runID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if run.Status != "stopped" || run.SeedURL != "https://a.test/" || run.MaxDepth != 2 || !run.RespectRobots {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if !run.StartedAt.Valid || !run.StartedAt.Time.Equal(started) || run.StopReason.String != reason {
+		t.Fatalf("started/stop reason not kept: %+v", run)
+	}
+	if _, err := store.GetRun(ctx, uuid.New()); err == nil {
+		t.Fatal("expected error for unknown run")
+	}
+	if len(run.Config) != 0 || run.ConfigVersion.Valid {
+		t.Fatalf("config set on a run created without one: %+v", run)
+	}
+
+	// jsonb may reorder keys, so compare decoded values
+	cfg := []byte(`{"seed_url":"https://a.test/","retry_max":4,"robots_ttl":3600000000000}`)
+	withConfig, err := store.CreateRun(ctx, RunConfig{SeedURL: "https://a.test/", Config: cfg, ConfigVersion: 1})
+	if err != nil {
+		t.Fatalf("create with config: %v", err)
+	}
+	run, err = store.GetRun(ctx, withConfig)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var want, got map[string]any
+	_ = json.Unmarshal(cfg, &want)
+	if err := json.Unmarshal(run.Config, &got); err != nil || !reflect.DeepEqual(got, want) || run.ConfigVersion.Int64 != 1 {
+		t.Fatalf("config not kept: %s (version %v, err %v)", run.Config, run.ConfigVersion, err)
+	}
+}
+
+func conformListRuns(t *testing.T, store Store, _ uuid.UUID) {
+	ctx := context.Background()
+	// a host unique to this test keeps a shared Postgres database isolated
+	host := uuid.NewString()[:8] + ".test"
+	other := uuid.NewString()[:8] + ".test"
+	var ids []uuid.UUID
+	for i := 0; i < 5; i++ {
+		seedHost := host
+		if i == 4 {
+			seedHost = other
+		}
+		id, err := store.CreateRun(ctx, RunConfig{SeedURL: "https://" + seedHost + "/" + strconv.Itoa(i), SeedHost: seedHost})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	reason := "time_budget"
+	stopped := time.Now()
+	if err := store.UpdateRunStatus(ctx, ids[1], "stopped", nil, &stopped, &reason); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	var pages [][]RunRow
+	q := RunQuery{SeedHost: host, Desc: true, Limit: 2}
+	for {
+		rows, err := store.ListRuns(ctx, q)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		pages = append(pages, rows)
+		cur := RunCursorFor(rows[len(rows)-1], q.Sort)
+		q.After = &cur
+	}
+	var got []uuid.UUID
+	for _, page := range pages {
+		for _, row := range page {
+			got = append(got, row.ID)
+		}
+	}
+	if len(pages) != 2 || len(got) != 4 {
+		t.Fatalf("expected 4 runs over 2 pages, got %d over %d", len(got), len(pages))
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range got {
+		if seen[id] || id == ids[4] {
+			t.Fatalf("unexpected or repeated run %s in %v", id, got)
+		}
+		seen[id] = true
+	}
+
+	rows, err := store.ListRuns(ctx, RunQuery{SeedHost: host, Sort: RunSortSeedURL, Limit: 10})
+	if err != nil || len(rows) != 4 {
+		t.Fatalf("sort by seed_url: %d rows, err %v", len(rows), err)
+	}
+	for i, row := range rows {
+		if row.ID != ids[i] || row.SeedHost != host {
+			t.Fatalf("seed_url order: row %d is %s (%s)", i, row.SeedURL, row.ID)
+		}
+	}
+	cur := RunCursorFor(rows[1], RunSortSeedURL)
+	rows, _ = store.ListRuns(ctx, RunQuery{SeedHost: host, Sort: RunSortSeedURL, After: &cur, Limit: 10})
+	if len(rows) != 2 || rows[0].ID != ids[2] {
+		t.Fatalf("seed_url cursor: %+v", rows)
+	}
+
+	rows, _ = store.ListRuns(ctx, RunQuery{SeedHost: host, Statuses: []string{"stopped"}, StopReason: reason})
+	if len(rows) != 1 || rows[0].ID != ids[1] {
+		t.Fatalf("status filter: %+v", rows)
+	}
+	rows, _ = store.ListRuns(ctx, RunQuery{SeedHost: host, Statuses: []string{"created", "running"}})
+	if len(rows) != 3 {
+		t.Fatalf("multi-status filter: %d rows", len(rows))
+	}
+	future := time.Now().Add(time.Hour)
+	rows, _ = store.ListRuns(ctx, RunQuery{SeedHost: host, CreatedAfter: &future})
+	if len(rows) != 0 {
+		t.Fatalf("created_after filter: %d rows", len(rows))
+	}
+	rows, _ = store.ListRuns(ctx, RunQuery{SeedHost: host, CreatedBefore: &future})
+	if len(rows) != 4 {
+		t.Fatalf("created_before filter: %d rows", len(rows))
+	}
+
+	now := time.Now().UTC()
+	if err := store.InsertPages(ctx, []PageRecord{
+		{RunID: ids[0], URL: "https://" + host + "/a", CanonicalURL: "https://" + host + "/a", Host: host, StatusCode: 200, SizeBytes: 10, DiscoveredAt: now, FetchedAt: &now},
+		{RunID: ids[0], URL: "https://" + host + "/b", CanonicalURL: "https://" + host + "/b", Host: host, ErrClass: "timeout", DiscoveredAt: now},
+		{RunID: ids[2], URL: "https://" + host + "/c", CanonicalURL: "https://" + host + "/c", Host: host, StatusCode: 200, SizeBytes: 5, DiscoveredAt: now, FetchedAt: &now},
+	}); err != nil {
+		t.Fatalf("insert pages: %v", err)
+	}
+	summaries, err := store.RunSummaries(ctx, []uuid.UUID{ids[0], ids[2], ids[3]})
+	if err != nil {
+		t.Fatalf("summaries: %v", err)
+	}
+	first := summaries[ids[0]]
+	if first.PagesFetched != 1 || first.PagesFailed != 1 || first.UniqueHosts != 1 || first.TotalBytes != 10 || first.LastFetchedAt == nil {
+		t.Fatalf("summary for run 0: %+v", first)
+	}
+	if summaries[ids[2]].PagesFetched != 1 || summaries[ids[3]].PagesFetched != 0 {
+		t.Fatalf("summaries: %+v", summaries)
+	}
+}
+
+func conformPages(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	earlier := now.Add(-time.Minute)
+	err := store.InsertPages(ctx, []PageRecord{
+		{RunID: runID, URL: "https://a.test/", CanonicalURL: "https://a.test/", Host: "a.test", StatusCode: 200, SizeBytes: 100, DiscoveredAt: earlier, FetchedAt: &earlier,
+			Timing: FetchTiming{DNSMS: 3, TTFBMS: 20}, Title: "Home", ContentHash: "ab12"},
+		{RunID: runID, URL: "https://a.test/new", CanonicalURL: "https://a.test/new", Host: "a.test", Depth: 1, StatusCode: 200, SizeBytes: 50, DiscoveredAt: now, FetchedAt: &now,
+			OriginURL: "https://a.test/old", RedirectChain: []RedirectHop{{URL: "https://a.test/old", StatusCode: 301}}},
+		{RunID: runID, URL: "https://b.test/x", CanonicalURL: "https://b.test/x", Host: "b.test", Depth: 1, ErrClass: "timeout", ErrMessage: "deadline", DiscoveredAt: now},
+	})
+	if err != nil {
+		t.Fatalf("insert pages: %v", err)
+	}
+	if err := store.InsertErrors(ctx, []ErrorRecord{{RunID: runID, Host: "b.test", URL: "https://b.test/x", Class: "timeout", At: now}}); err != nil {
+		t.Fatalf("insert errors: %v", err)
+	}
+	if err := store.UpsertEdges(ctx, []EdgeRecord{{RunID: runID, Src: "a.test", Dst: "b.test", Count: 2}}); err != nil {
+		t.Fatalf("upsert edges: %v", err)
+	}
+	if err := store.UpsertEdge(ctx, runID, "a.test", "b.test", 1); err != nil {
+		t.Fatalf("upsert edge: %v", err)
+	}
+
+	pages, err := store.ListPages(ctx, runID, 10)
+	if err != nil {
+		t.Fatalf("list pages: %v", err)
+	}
+	if len(pages) != 3 || pages[0].URL != "https://a.test/new" || pages[2].URL != "https://b.test/x" {
+		t.Fatalf("expected newest first and unfetched last, got %+v", pages)
+	}
+	failed, err := store.ListFailedPages(ctx, runID, 10)
+	if err != nil || len(failed) != 1 || failed[0].ErrorClass != "timeout" || failed[0].ErrorMessage != "deadline" {
+		t.Fatalf("unexpected failed pages: %+v (%v)", failed, err)
+	}
+	page, err := store.GetPage(ctx, runID, "https://a.test/new")
+	if err != nil || page == nil {
+		t.Fatalf("get page: %+v (%v)", page, err)
+	}
+	if page.OriginURL != "https://a.test/old" || len(page.RedirectChain) != 1 || page.RedirectChain[0].StatusCode != 301 || page.Depth != 1 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if root, _ := store.GetPage(ctx, runID, "https://a.test/"); root == nil || root.Timing.DNSMS != 3 || root.Timing.TTFBMS != 20 {
+		t.Fatalf("timing not kept: %+v", root)
+	} else if root.Title != "Home" || root.ContentHash != "ab12" {
+		t.Fatalf("title and content hash not kept: %+v", root)
+	}
+	if missing, err := store.GetPage(ctx, runID, "https://a.test/missing"); err != nil || missing != nil {
+		t.Fatalf("expected nil for unknown page, got %+v (%v)", missing, err)
+	}
+
+	summary, err := store.GetRunSummary(ctx, runID)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.PagesFetched != 2 || summary.PagesFailed != 1 || summary.UniqueHosts != 2 || summary.TotalBytes != 150 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if summary.LastFetchedAt == nil || !summary.LastFetchedAt.Equal(now) {
+		t.Fatalf("expected last fetched %v, got %v", now, summary.LastFetchedAt)
+	}
+}
+
+func conformQueryPages(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Millisecond)
+	at := func(sec int) *time.Time {
+		t := base.Add(time.Duration(sec) * time.Second)
+		return &t
+	}
+	recs := []PageRecord{
+		{URL: "https://a.test/", Host: "a.test", Depth: 0, StatusCode: 200, ContentType: "text/html; charset=utf-8", SizeBytes: 900, FetchMS: 40, FetchedAt: at(1)},
+		{URL: "https://a.test/docs/one", Host: "a.test", Depth: 1, StatusCode: 200, ContentType: "text/html", SizeBytes: 300, FetchMS: 120, FetchedAt: at(2)},
+		{URL: "https://a.test/docs/two", Host: "a.test", Depth: 1, StatusCode: 404, ContentType: "text/html", SizeBytes: 50, FetchMS: 15, FetchedAt: at(3)},
+		{URL: "https://a.test/img/logo.png", Host: "a.test", Depth: 2, StatusCode: 200, ContentType: "image/png", SizeBytes: 4000, FetchMS: 300, FetchedAt: at(3)},
+		{URL: "https://b.test/", Host: "b.test", Depth: 1, StatusCode: 500, ContentType: "text/html", SizeBytes: 10, FetchMS: 900, FetchedAt: at(4)},
+		{URL: "https://b.test/slow", Host: "b.test", Depth: 2, ErrClass: "timeout", ErrMessage: "deadline"},
+		{URL: "https://c.test/100%_off", Host: "c.test", Depth: 2, StatusCode: 301, SizeBytes: 0, FetchMS: 5, FetchedAt: at(5)},
+		{URL: "https://c.test/dns", Host: "c.test", Depth: 3, ErrClass: "dns"},
+	}
+	for i := range recs {
+		recs[i].RunID = runID
+		recs[i].CanonicalURL = recs[i].URL
+		recs[i].DiscoveredAt = base
+	}
+	if err := store.InsertPages(ctx, recs); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	intp := func(n int) *int { return &n }
+	int64p := func(n int64) *int64 { return &n }
+	urls := func(rows []PageRow) []string {
+		out := make([]string, len(rows))
+		for i, row := range rows {
+			out[i] = row.URL
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	filters := []struct {
+		name string
+		q    PageQuery
+		want []string
+	}{
+		{"host", PageQuery{Host: "b.test"}, []string{"https://b.test/", "https://b.test/slow"}},
+		{"status range", PageQuery{StatusMin: 400, StatusMax: 499}, []string{"https://a.test/docs/two"}},
+		{"error class", PageQuery{ErrorClass: "dns"}, []string{"https://c.test/dns"}},
+		{"any error", PageQuery{ErrorClass: ErrorClassAny}, []string{"https://b.test/slow", "https://c.test/dns"}},
+		{"content type prefix", PageQuery{ContentType: "text/html", Host: "a.test"}, []string{"https://a.test/", "https://a.test/docs/one", "https://a.test/docs/two"}},
+		{"depth", PageQuery{DepthMin: intp(2), DepthMax: intp(2)}, []string{"https://a.test/img/logo.png", "https://b.test/slow", "https://c.test/100%_off"}},
+		{"size", PageQuery{SizeMin: int64p(300), SizeMax: int64p(1000)}, []string{"https://a.test/", "https://a.test/docs/one"}},
+		{"latency", PageQuery{FetchMSMin: int64p(200)}, []string{"https://a.test/img/logo.png", "https://b.test/"}},
+		{"url prefix", PageQuery{URLPrefix: "https://a.test/docs/"}, []string{"https://a.test/docs/one", "https://a.test/docs/two"}},
+		{"url prefix is literal", PageQuery{URLPrefix: "https://c.test/100%_"}, []string{"https://c.test/100%_off"}},
+		{"url prefix wildcard", PageQuery{URLPrefix: "https://c.test/_"}, nil},
+		{"url contains", PageQuery{URLContains: "/docs/t"}, []string{"https://a.test/docs/two"}},
+		{"no error", PageQuery{ErrorClass: ErrorClassNone, Host: "c.test"}, []string{"https://c.test/100%_off"}},
+	}
+	for _, f := range filters {
+		f.q.Limit = 100
+		rows, err := store.QueryPages(ctx, runID, f.q)
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if got := urls(rows); !reflect.DeepEqual(got, f.want) && !(len(got) == 0 && len(f.want) == 0) {
+			t.Errorf("%s: got %v, want %v", f.name, got, f.want)
+		}
+	}
+
+	sorts := []string{PageSortFetched, PageSortURL, PageSortHost, PageSortStatus, PageSortDepth, PageSortSize, PageSortFetchMS, PageSortContentType}
+	for _, sortKey := range sorts {
+		for _, desc := range []bool{false, true} {
+			all, err := store.QueryPages(ctx, runID, PageQuery{Sort: sortKey, Desc: desc, Limit: 100})
+			if err != nil {
+				t.Fatalf("sort %s: %v", sortKey, err)
+			}
+			if len(all) != len(recs) {
+				t.Fatalf("sort %s: got %d rows", sortKey, len(all))
+			}
+			for i := 1; i < len(all); i++ {
+				a, b := PageCursorFor(all[i-1], sortKey), PageCursorFor(all[i], sortKey)
+				if c := cmpCursorKey(a, b); (desc && c < 0) || (!desc && c > 0) {
+					t.Fatalf("sort %s desc=%v out of order at %d: %s then %s", sortKey, desc, i, all[i-1].URL, all[i].URL)
+				}
+			}
+			var paged []PageRow
+			q := PageQuery{Sort: sortKey, Desc: desc, Limit: 3}
+			for {
+				rows, err := store.QueryPages(ctx, runID, q)
+				if err != nil {
+					t.Fatalf("sort %s page: %v", sortKey, err)
+				}
+				if len(rows) == 0 {
+					break
+				}
+				paged = append(paged, rows...)
+				cur := PageCursorFor(rows[len(rows)-1], sortKey)
+				q.After = &cur
+			}
+			if len(paged) != len(all) {
+				t.Fatalf("sort %s desc=%v: paging returned %d rows, want %d", sortKey, desc, len(paged), len(all))
+			}
+			for i := range all {
+				if paged[i].ID != all[i].ID {
+					t.Fatalf("sort %s desc=%v: paging diverged at %d", sortKey, desc, i)
+				}
+			}
+		}
+	}
+}
+
+func cmpCursorKey(a, b PageCursor) int {
+	if c := a.Time.Compare(b.Time); c != 0 {
+		return c
+	}
+	if a.Num != b.Num {
+		if a.Num < b.Num {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.Text, b.Text)
+}
+
+func conformLinks(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	err := store.InsertLinks(ctx, []LinkRecord{
+		{RunID: runID, SrcURL: "https://a.test/", DstURL: "https://a.test/x", AnchorText: "x", Element: "a", Followed: true},
+		{RunID: runID, SrcURL: "https://a.test/y", DstURL: "https://a.test/x", Element: "a"},
+		{RunID: runID, SrcURL: "https://a.test/", DstURL: "https://b.test/", Rel: "nofollow", Element: "a"},
+	})
+	if err != nil {
+		t.Fatalf("insert links: %v", err)
+	}
+	in, err := store.ListInlinks(ctx, runID, "https://a.test/x", 10)
+	if err != nil || len(in) != 2 || in[0].SrcURL != "https://a.test/" || !in[0].Followed || in[0].AnchorText != "x" {
+		t.Fatalf("unexpected inlinks: %+v (%v)", in, err)
+	}
+	batch, err := store.ListInlinksTo(ctx, runID, []string{"https://a.test/x", "https://b.test/", "https://c.test/"}, 1)
+	if err != nil || len(batch) != 2 || batch[0].DstURL != "https://a.test/x" || batch[0].SrcURL != "https://a.test/" || batch[1].DstURL != "https://b.test/" {
+		t.Fatalf("unexpected batched inlinks: %+v (%v)", batch, err)
+	}
+	out, err := store.ListOutlinks(ctx, runID, "https://a.test/", 1)
+	if err != nil || len(out) != 1 || out[0].DstURL != "https://a.test/x" {
+		t.Fatalf("unexpected outlinks: %+v (%v)", out, err)
+	}
+
+	// a second anchor to the same target adds weight rather than an edge
+	if err := store.InsertLinks(ctx, []LinkRecord{{RunID: runID, SrcURL: "https://a.test/", DstURL: "https://a.test/x", Element: "a"}}); err != nil {
+		t.Fatalf("insert links: %v", err)
+	}
+	edges, err := store.ListLinkEdges(ctx, runID, LinkEdgeQuery{Limit: 2})
+	want := []LinkEdge{{Src: "https://a.test/", Dst: "https://a.test/x", Count: 2}, {Src: "https://a.test/", Dst: "https://b.test/", Count: 1}}
+	if err != nil || !reflect.DeepEqual(edges, want) {
+		t.Fatalf("unexpected link edges: %+v (%v)", edges, err)
+	}
+	rest, err := store.ListLinkEdges(ctx, runID, LinkEdgeQuery{After: &edges[1], Limit: 2})
+	if err != nil || len(rest) != 1 || rest[0].Src != "https://a.test/y" {
+		t.Fatalf("unexpected link edges after cursor: %+v (%v)", rest, err)
+	}
+	heavy, err := store.ListLinkEdges(ctx, runID, LinkEdgeQuery{MinCount: 2})
+	if err != nil || len(heavy) != 1 || heavy[0].Dst != "https://a.test/x" {
+		t.Fatalf("unexpected link edges with min count: %+v (%v)", heavy, err)
+	}
+}
+
+func conformLinkStatus(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if err := store.UpsertLinkStatus(ctx, LinkStatusRecord{RunID: runID, URL: "https://b.test/", CanonicalURL: "https://b.test/", Host: "b.test", StatusCode: 404, Method: "HEAD", CheckedAt: now}); err != nil {
+		t.Fatalf("upsert link status: %v", err)
+	}
+	// a batch may name the same URL twice; the later record wins
+	err := store.UpsertLinkStatuses(ctx, []LinkStatusRecord{
+		{RunID: runID, URL: "https://b.test/", CanonicalURL: "https://b.test/", Host: "b.test", StatusCode: 500, Method: "HEAD", CheckedAt: now},
+		{RunID: runID, URL: "https://c.test/", CanonicalURL: "https://c.test/", Host: "c.test", ErrClass: "dns_nxdomain", CheckedAt: now},
+		{RunID: runID, URL: "https://b.test/", CanonicalURL: "https://b.test/", Host: "b.test", StatusCode: 200, Method: "GET", CheckedAt: now},
+	})
+	if err != nil {
+		t.Fatalf("upsert link statuses: %v", err)
+	}
+	all, err := store.ListLinkStatuses(ctx, runID, false, 10)
+	if err != nil || len(all) != 2 || all[0].StatusCode != 200 || all[0].Method != "GET" {
+		t.Fatalf("expected upsert to keep the latest row per URL, got %+v (%v)", all, err)
+	}
+	failed, err := store.ListLinkStatuses(ctx, runID, true, 10)
+	if err != nil || len(failed) != 1 || failed[0].ErrorClass != "dns_nxdomain" {
+		t.Fatalf("unexpected failed statuses: %+v (%v)", failed, err)
+	}
+}
+
+func conformRedirectChains(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	if err := store.InsertRedirectChain(ctx, RedirectChainRecord{RunID: runID, OriginURL: "https://a.test/1", FinalURL: "https://a.test/2", FinalStatus: 200, Hops: []RedirectHop{{URL: "https://a.test/1", StatusCode: 301}}, Outcome: "ok"}); err != nil {
+		t.Fatalf("insert chain: %v", err)
+	}
+	err := store.InsertRedirectChains(ctx, []RedirectChainRecord{
+		{RunID: runID, OriginURL: "https://a.test/3", FinalURL: "https://a.test/5", FinalStatus: 200, Hops: []RedirectHop{{URL: "https://a.test/3", StatusCode: 302}, {URL: "https://a.test/4", StatusCode: 301}}, Outcome: "ok"},
+		{RunID: runID, OriginURL: "https://a.test/6", FinalURL: "https://a.test/7", Hops: []RedirectHop{{URL: "https://a.test/6", StatusCode: 301}}, Outcome: "out_of_scope"},
+	})
+	if err != nil {
+		t.Fatalf("insert chains: %v", err)
+	}
+	rows, err := store.ListRedirectChains(ctx, runID, 2, 10)
+	if err != nil || len(rows) != 1 || rows[0].HopCount != 2 || len(rows[0].Hops) != 2 || rows[0].Hops[1].StatusCode != 301 {
+		t.Fatalf("unexpected chains: %+v (%v)", rows, err)
+	}
+	if all, err := store.ListRedirectChains(ctx, runID, 0, 10); err != nil || len(all) != 3 {
+		t.Fatalf("expected three chains, got %+v (%v)", all, err)
+	}
+}
+
+func conformHostStats(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	bucket := time.Now().UTC().Truncate(time.Second)
+	rec := HostStatRecord{RunID: runID, Host: "a.test", BucketStart: bucket, Requests: 1, Latency: Percentiles{P50MS: 10, P95MS: 30}}
+	if err := store.UpsertHostStat(ctx, rec); err != nil {
+		t.Fatalf("upsert host stat: %v", err)
+	}
+	rec.Requests = 5
+	rec.ReuseRate = 0.5
+	rec.TTFB = Percentiles{P50MS: 7, P95MS: 9}
+	if err := store.UpsertHostStat(ctx, rec); err != nil {
+		t.Fatalf("upsert host stat: %v", err)
+	}
+	rows, err := store.ListHostStats(ctx, runID)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("expected one bucket, got %+v (%v)", rows, err)
+	}
+	got := rows[0]
+	if got.Requests != 5 || got.ReuseRate != 0.5 || got.TTFB.P95MS != 9 || !got.BucketStart.Equal(bucket) {
+		t.Fatalf("unexpected host stat: %+v", got)
+	}
+
+	next := bucket.Add(10 * time.Second)
+	err = store.UpsertHostStats(ctx, []HostStatRecord{
+		{RunID: runID, Host: "a.test", BucketStart: next, Requests: 1},
+		{RunID: runID, Host: "a.test", BucketStart: next.Add(10 * time.Second), Requests: 3},
+		{RunID: runID, Host: "b.test", BucketStart: next, Requests: 7},
+		{RunID: runID, Host: "a.test", BucketStart: next, Requests: 2},
+	})
+	if err != nil {
+		t.Fatalf("upsert host stats: %v", err)
+	}
+	series, err := store.QueryHostStats(ctx, runID, HostStatQuery{Host: "a.test"})
+	if err != nil || len(series) != 3 || series[0].Requests != 5 || series[2].Requests != 3 {
+		t.Fatalf("expected a.test buckets in time order, got %+v (%v)", series, err)
+	}
+	to := next.Add(10 * time.Second)
+	series, err = store.QueryHostStats(ctx, runID, HostStatQuery{Host: "a.test", From: &next, To: &to})
+	if err != nil || len(series) != 1 || !series[0].BucketStart.Equal(next) || series[0].Requests != 2 {
+		t.Fatalf("expected one bucket in [from, to), got %+v (%v)", series, err)
+	}
+}
+
+func conformHostSecurity(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	notAfter := time.Now().UTC().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	rec := HostSecurityRecord{RunID: runID, Host: "a.test", TLSVersion: "TLS 1.3", CertSANs: []string{"a.test", "www.a.test"}, CertNotAfter: &notAfter, CertVerified: true, HSTS: "max-age=1", InspectedAt: time.Now().UTC()}
+	if err := store.UpsertHostSecurity(ctx, rec); err != nil {
+		t.Fatalf("upsert security: %v", err)
+	}
+	rows, err := store.ListHostSecurity(ctx, runID)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("unexpected security rows: %+v (%v)", rows, err)
+	}
+	got := rows[0]
+	if !got.CertVerified || len(got.CertSANs) != 2 || got.CertNotAfter == nil || !got.CertNotAfter.Equal(notAfter) || got.HSTS != "max-age=1" {
+		t.Fatalf("unexpected security row: %+v", got)
+	}
+}
+
+func conformHostSummaries(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	err := store.InsertPages(ctx, []PageRecord{
+		{RunID: runID, URL: "https://a.test/", CanonicalURL: "https://a.test/", Host: "a.test", StatusCode: 200, SizeBytes: 100, DiscoveredAt: now, FetchedAt: &now},
+		{RunID: runID, URL: "https://a.test/gone", CanonicalURL: "https://a.test/gone", Host: "a.test", Depth: 1, StatusCode: 404, SizeBytes: 10, DiscoveredAt: now, FetchedAt: &now},
+		{RunID: runID, URL: "https://b.test/", CanonicalURL: "https://b.test/", Host: "b.test", Depth: 2, StatusCode: 503, ErrClass: "http_5xx", DiscoveredAt: now, FetchedAt: &now},
+		{RunID: runID, URL: "https://b.test/x", CanonicalURL: "https://b.test/x", Host: "b.test", Depth: 1, ErrClass: "timeout", DiscoveredAt: now},
+	})
+	if err != nil {
+		t.Fatalf("insert pages: %v", err)
+	}
+	counts, err := store.HostPageCounts(ctx, runID)
+	if err != nil || len(counts) != 2 {
+		t.Fatalf("unexpected host counts: %+v (%v)", counts, err)
+	}
+	want := []HostCounts{
+		{Host: "a.test", Pages: 2, Fetched: 2, Status2xx: 1, Status4xx: 1, Bytes: 110},
+		{Host: "b.test", Pages: 2, Fetched: 1, Failed: 2, Status5xx: 1, MinDepth: 1},
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("host counts:\n got %+v\nwant %+v", counts, want)
+	}
+
+	// state and security share the hosts row without clobbering each other
+	if err := store.UpsertHostSecurity(ctx, HostSecurityRecord{RunID: runID, Host: "a.test", TLSVersion: "TLS 1.3", CertVerified: true, InspectedAt: now}); err != nil {
+		t.Fatalf("upsert security: %v", err)
+	}
+	if err := store.UpsertHostState(ctx, HostStateRecord{RunID: runID, Host: "a.test", RobotsState: "fetching"}); err != nil {
+		t.Fatalf("upsert host state: %v", err)
+	}
+	err = store.UpsertHostStates(ctx, []HostStateRecord{
+		{RunID: runID, Host: "a.test", RobotsState: "fetching", CircuitState: "closed"},
+		{RunID: runID, Host: "b.test", RobotsState: "error", CircuitState: "open"},
+		{RunID: runID, Host: "a.test", RobotsState: "ready", CircuitState: "closed"},
+	})
+	if err != nil {
+		t.Fatalf("upsert host states: %v", err)
+	}
+	states, err := store.ListHostStates(ctx, runID)
+	if err != nil || len(states) != 2 {
+		t.Fatalf("unexpected host states: %+v (%v)", states, err)
+	}
+	if states[0].Host != "a.test" || states[0].RobotsState != "ready" || states[1].CircuitState != "open" {
+		t.Fatalf("unexpected host states: %+v", states)
+	}
+	security, err := store.ListHostSecurity(ctx, runID)
+	if err != nil || len(security) != 1 || security[0].TLSVersion != "TLS 1.3" {
+		t.Fatalf("host state overwrote security: %+v (%v)", security, err)
+	}
+}
+
+func conformErrorGroups(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err := store.InsertErrors(ctx, []ErrorRecord{
+		{RunID: runID, Host: "a.test", URL: "https://a.test/1", Class: "timeout", At: now},
+		{RunID: runID, Host: "a.test", URL: "https://a.test/2", Class: "timeout", At: now.Add(time.Second)},
+		{RunID: runID, Host: "a.test", URL: "https://a.test/1", Class: "timeout", At: now.Add(2 * time.Second)},
+		{RunID: runID, Host: "a.test", URL: "https://a.test/3", Class: "timeout", At: now.Add(-time.Second)},
+		{RunID: runID, Host: "b.test", URL: "https://b.test/", Class: "timeout", At: now},
+		{RunID: runID, Host: "a.test", URL: "https://a.test/4", Class: "http_5xx", At: now},
+		{RunID: runID, Class: "storage", At: now},
+	})
+	if err != nil {
+		t.Fatalf("insert errors: %v", err)
+	}
+	groups, err := store.ListErrorGroups(ctx, runID, ErrorGroupQuery{Samples: 2, Limit: 10})
+	if err != nil || len(groups) != 4 {
+		t.Fatalf("unexpected groups: %+v (%v)", groups, err)
+	}
+	top := groups[0]
+	if top.Class != "timeout" || top.Host != "a.test" || top.Count != 4 {
+		t.Fatalf("expected timeout on a.test first, got %+v", top)
+	}
+	if !top.FirstAt.Equal(now.Add(-time.Second)) || !top.LastAt.Equal(now.Add(2*time.Second)) {
+		t.Fatalf("unexpected group span: %v - %v", top.FirstAt, top.LastAt)
+	}
+	if !reflect.DeepEqual(top.SampleURLs, []string{"https://a.test/1", "https://a.test/2"}) {
+		t.Fatalf("expected the latest distinct urls as samples, got %v", top.SampleURLs)
+	}
+	for _, g := range groups {
+		if g.Class == "storage" && (g.Host != "" || len(g.SampleURLs) != 0) {
+			t.Fatalf("unexpected hostless group: %+v", g)
+		}
+	}
+
+	filtered, err := store.ListErrorGroups(ctx, runID, ErrorGroupQuery{Class: "timeout", Host: "b.test", Samples: 2})
+	if err != nil || len(filtered) != 1 || filtered[0].Count != 1 || len(filtered[0].SampleURLs) != 1 {
+		t.Fatalf("unexpected filtered groups: %+v (%v)", filtered, err)
+	}
+	limited, err := store.ListErrorGroups(ctx, runID, ErrorGroupQuery{Limit: 1})
+	if err != nil || len(limited) != 1 || len(limited[0].SampleURLs) != 0 {
+		t.Fatalf("unexpected limited groups: %+v (%v)", limited, err)
+	}
+}
+
+func conformErrors(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var recs []ErrorRecord
+	for i := 0; i < 5; i++ {
+		class := "timeout"
+		if i%2 == 1 {
+			class = "status"
+		}
+		recs = append(recs, ErrorRecord{RunID: runID, Host: "a.test", URL: "https://a.test/" + strconv.Itoa(i), Class: class, Message: "m", At: now})
+	}
+	if err := store.InsertErrors(ctx, recs); err != nil {
+		t.Fatalf("insert errors: %v", err)
+	}
+	if err := store.InsertError(ctx, runID, "", "", "storage", "disk full"); err != nil {
+		t.Fatalf("insert error: %v", err)
+	}
+	var got []ErrorRecord
+	var after int64
+	for {
+		page, err := store.QueryErrors(ctx, runID, ErrorQuery{AfterID: after, Limit: 2})
+		if err != nil {
+			t.Fatalf("query errors: %v", err)
+		}
+		got = append(got, page...)
+		if len(page) < 2 {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+	if len(got) != 6 || got[0].URL != "https://a.test/0" || got[5].Class != "storage" || got[5].Message != "disk full" || !got[0].At.Equal(now) {
+		t.Fatalf("expected all errors in insertion order, got %+v", got)
+	}
+	timeouts, err := store.QueryErrors(ctx, runID, ErrorQuery{Class: "timeout", Host: "a.test"})
+	if err != nil || len(timeouts) != 3 {
+		t.Fatalf("unexpected filtered errors: %+v (%v)", timeouts, err)
+	}
+}
+
+func conformEdges(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	err := store.UpsertEdges(ctx, []EdgeRecord{
+		{RunID: runID, Src: "a.test", Dst: "b.test", Count: 2},
+		{RunID: runID, Src: "a.test", Dst: "c.test", Count: 5},
+		{RunID: runID, Src: "c.test", Dst: "d.test", Count: 1},
+	})
+	if err != nil {
+		t.Fatalf("upsert edges: %v", err)
+	}
+	if err := store.UpsertEdge(ctx, runID, "a.test", "b.test", 4); err != nil {
+		t.Fatalf("upsert edge: %v", err)
+	}
+	edges, err := store.ListEdges(ctx, runID, EdgeQuery{Limit: 10})
+	if err != nil || len(edges) != 3 {
+		t.Fatalf("unexpected edges: %+v (%v)", edges, err)
+	}
+	if edges[0].Src != "a.test" || edges[0].Dst != "b.test" || edges[0].Count != 6 || edges[2].Count != 1 {
+		t.Fatalf("expected heaviest edge first with summed counts, got %+v", edges)
+	}
+	touching, err := store.ListEdges(ctx, runID, EdgeQuery{Host: "c.test", Limit: 10})
+	if err != nil || len(touching) != 2 || touching[0].Dst != "c.test" || touching[1].Src != "c.test" {
+		t.Fatalf("unexpected edges for c.test: %+v (%v)", touching, err)
+	}
+	first, _ := store.ListEdges(ctx, runID, EdgeQuery{Limit: 2})
+	last := first[len(first)-1]
+	rest, err := store.ListEdges(ctx, runID, EdgeQuery{After: &EdgeCursor{Count: last.Count, Src: last.Src, Dst: last.Dst}, Limit: 2})
+	if err != nil || len(first) != 2 || len(rest) != 1 || rest[0].Src != "c.test" || rest[0].Dst != "d.test" {
+		t.Fatalf("unexpected edge pages: %+v then %+v (%v)", first, rest, err)
+	}
+}
+
+func conformSkipped(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	err := store.InsertSkips(ctx, []SkipRecord{
+		{RunID: runID, URL: "https://a.test/private", Host: "a.test", Reason: "robots_denied", At: now},
+		{RunID: runID, URL: "https://b.test/", Host: "b.test", Reason: "out_of_scope", At: now.Add(time.Second)},
+	})
+	if err != nil {
+		t.Fatalf("insert skips: %v", err)
+	}
+	if err := store.InsertSkip(ctx, SkipRecord{RunID: runID, URL: "https://c.test/", Reason: "out_of_scope", At: now.Add(2 * time.Second)}); err != nil {
+		t.Fatalf("insert skip: %v", err)
+	}
+	rows, err := store.ListSkipped(ctx, runID, "out_of_scope", 10)
+	if err != nil || len(rows) != 2 || rows[0].URL != "https://c.test/" {
+		t.Fatalf("expected newest out_of_scope first, got %+v (%v)", rows, err)
+	}
+	summary, err := store.GetRunSummary(ctx, runID)
+	if err != nil || summary.Skipped["out_of_scope"] != 2 || summary.Skipped["robots_denied"] != 1 {
+		t.Fatalf("unexpected skip counts: %v (%v)", summary.Skipped, err)
+	}
+}
+
+func conformURLEvents(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	err := store.InsertURLEvents(ctx, []URLEvent{
+		{RunID: runID, URL: "https://a.test/", Event: "fetch", Detail: map[string]any{"status_code": 200}, At: now.Add(time.Second)},
+		{RunID: runID, URL: "https://a.test/", Event: "discovered", Detail: map[string]any{"dedup": "new"}, At: now},
+		{RunID: runID, URL: "https://a.test/other", Event: "discovered", At: now},
+	})
+	if err != nil {
+		t.Fatalf("insert events: %v", err)
+	}
+	events, err := store.ListURLEvents(ctx, runID, "https://a.test/", 10)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("unexpected events: %+v (%v)", events, err)
+	}
+	if events[0].Event != "discovered" || events[0].Detail["dedup"] != "new" || events[1].Event != "fetch" {
+		t.Fatalf("expected events in time order with details, got %+v", events)
+	}
+}
+
+func conformRunAnalytics(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	if rec, err := store.GetRunAnalytics(ctx, runID); err != nil || rec != nil {
+		t.Fatalf("expected no analytics before the job ran, got %+v (%v)", rec, err)
+	}
+	at := time.Now().UTC().Truncate(time.Second)
+	if err := store.UpsertRunAnalytics(ctx, RunAnalyticsRecord{RunID: runID, Version: 1, Result: []byte(`{"pages":1}`), ComputedAt: at}); err != nil {
+		t.Fatalf("upsert analytics: %v", err)
+	}
+	if err := store.UpsertRunAnalytics(ctx, RunAnalyticsRecord{RunID: runID, Version: 2, Result: []byte(`{"pages":2}`), ComputedAt: at.Add(time.Minute)}); err != nil {
+		t.Fatalf("replace analytics: %v", err)
+	}
+	rec, err := store.GetRunAnalytics(ctx, runID)
+	if err != nil || rec == nil {
+		t.Fatalf("get analytics: %+v (%v)", rec, err)
+	}
+	var result map[string]int
+	if err := json.Unmarshal(rec.Result, &result); err != nil || result["pages"] != 2 || rec.Version != 2 || !rec.ComputedAt.Equal(at.Add(time.Minute)) {
+		t.Fatalf("expected the replaced analytics, got %+v %s (%v)", rec, rec.Result, err)
+	}
+}
+
+func conformSchedules(t *testing.T, store Store, _ uuid.UUID) {
+	ctx := context.Background()
+	if rec, err := store.GetSchedule(ctx, uuid.New()); err != nil || rec != nil {
+		t.Fatalf("expected no schedule for an unknown id, got %+v (%v)", rec, err)
+	}
+	created := time.Now().UTC().Truncate(time.Millisecond)
+	rec := ScheduleRecord{Name: "nightly", Cron: "0 2 * * *", Timezone: "UTC", Overlap: OverlapSkip, Enabled: true, Config: []byte(`{"seed_url":"https://a.test/"}`), ConfigVersion: 1, CreatedAt: created, UpdatedAt: created}
+	id, err := store.CreateSchedule(ctx, rec)
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	rec.ID = id
+	rec.Cron = "0 3 * * *"
+	rec.Overlap = OverlapQueue
+	rec.Enabled = false
+	rec.UpdatedAt = created.Add(time.Minute)
+	if err := store.UpdateSchedule(ctx, rec); err != nil {
+		t.Fatalf("update schedule: %v", err)
+	}
+	got, err := store.GetSchedule(ctx, id)
+	if err != nil || got == nil {
+		t.Fatalf("get schedule: %+v (%v)", got, err)
+	}
+	if got.Name != "nightly" || got.Cron != "0 3 * * *" || got.Overlap != OverlapQueue || got.Enabled || got.ConfigVersion != 1 ||
+		!got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(rec.UpdatedAt) || !strings.Contains(string(got.Config), "a.test") {
+		t.Fatalf("unexpected schedule: %+v %s", got, got.Config)
+	}
+	all, err := store.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("list schedules: %v", err)
+	}
+	found := false
+	for _, s := range all {
+		found = found || s.ID == id
+	}
+	if !found {
+		t.Fatalf("schedule %s missing from %+v", id, all)
+	}
+
+	runID, err := store.CreateRun(ctx, RunConfig{SeedURL: "https://a.test/", ScheduleID: id})
+	if err != nil {
+		t.Fatalf("create scheduled run: %v", err)
+	}
+	if _, err := store.CreateRun(ctx, RunConfig{SeedURL: "https://a.test/"}); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	runs, err := store.ListRuns(ctx, RunQuery{ScheduleID: id})
+	if err != nil || len(runs) != 1 || runs[0].ID != runID || runs[0].ScheduleID.UUID != id {
+		t.Fatalf("expected the scheduled run only, got %+v (%v)", runs, err)
+	}
+
+	if err := store.DeleteSchedule(ctx, id); err != nil {
+		t.Fatalf("delete schedule: %v", err)
+	}
+	if err := store.DeleteSchedule(ctx, id); !errors.Is(err, ErrScheduleNotFound) {
+		t.Fatalf("expected ErrScheduleNotFound deleting twice, got %v", err)
+	}
+	if err := store.UpdateSchedule(ctx, rec); !errors.Is(err, ErrScheduleNotFound) {
+		t.Fatalf("expected ErrScheduleNotFound updating a deleted schedule, got %v", err)
+	}
+	if run, err := store.GetRun(ctx, runID); err != nil || run.ScheduleID.UUID != id {
+		t.Fatalf("expected the run to keep its schedule, got %+v (%v)", run, err)
+	}
+}
+
+func conformRunEvents(t *testing.T, store Store, runID uuid.UUID) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	change := map[string]any{"max_pages": map[string]any{"from": 100, "to": 500}}
+	if err := store.InsertRunEvent(ctx, RunEvent{RunID: runID, Kind: RunEventConfig, Actor: "alice", Detail: change, At: now.Add(time.Second)}); err != nil {
+		t.Fatalf("insert config event: %v", err)
+	}
+	if err := store.InsertRunEvent(ctx, RunEvent{RunID: runID, Kind: RunEventPause, Actor: "bob", At: now}); err != nil {
+		t.Fatalf("insert pause event: %v", err)
+	}
+	events, err := store.ListRunEvents(ctx, runID, 10)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("unexpected events: %+v (%v)", events, err)
+	}
+	if events[0].Kind != RunEventPause || events[0].Actor != "bob" || events[0].Detail != nil {
+		t.Fatalf("expected the pause first, without detail, got %+v", events[0])
+	}
+	// SQL stores decode numbers as float64, the memory store keeps ints
+	detail, _ := json.Marshal(events[1].Detail)
+	if events[1].Actor != "alice" || string(detail) != `{"max_pages":{"from":100,"to":500}}` {
+		t.Fatalf("expected the config change with its detail, got %+v", events[1])
+	}
+	if other, err := store.ListRunEvents(ctx, uuid.New(), 10); err != nil || len(other) != 0 {
+		t.Fatalf("expected no events for another run, got %+v (%v)", other, err)
+	}
+
+	if err := store.UpdateRunConfig(ctx, runID, RunConfig{MaxPages: 500, TimeBudgetSeconds: 60, GlobalConcurrency: 16, PerHostConcurrency: 3, Config: []byte(`{"max_pages":500}`), ConfigVersion: 1}); err != nil {
+		t.Fatalf("update run config: %v", err)
+	}
+	row, err := store.GetRun(ctx, runID)
+	if err != nil || row.MaxPages != 500 || row.TimeBudgetSeconds != 60 || row.GlobalConcurrency != 16 || row.PerHostConcurrency != 3 || string(row.Config) != `{"max_pages":500}` {
+		t.Fatalf("expected the updated config, got %+v (%v)", row, err)
+	}
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"container/heap"
 	"context"
 	"database/sql"
 	"errors"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,24 +14,35 @@ import (
 )
 
 type MemoryStore struct {
-	mu    sync.Mutex
-	runs  map[uuid.UUID]RunRow
-	pages []PageRecord
-	edges map[string]int
-	errors []struct {
-		runID   uuid.UUID
-		host    string
-		url     string
-		class   string
-		message string
-		at      time.Time
-	}
+	mu         sync.Mutex
+	runs       map[uuid.UUID]RunRow
+	pages      map[uuid.UUID][]PageRecord // by run; a page's id is its index + 1
+	edges      map[uuid.UUID]map[[2]string]int
+	links      []LinkRecord
+	linkStatus map[string]LinkStatusRecord
+	chains     map[uuid.UUID][]RedirectChainRow
+	hostStats  []HostStatRecord
+	security   map[uuid.UUID]map[string]HostSecurityRecord
+	hostStates map[uuid.UUID]map[string]HostStateRecord
+	skipped    []SkipRecord
+	urlEvents  []URLEvent
+	runEvents  []RunEvent
+	errors     []ErrorRecord // an error's id is its index + 1
+	analytics  map[uuid.UUID]RunAnalyticsRecord
+	schedules  map[uuid.UUID]ScheduleRecord
 }
 
 func NewMemory() *MemoryStore {
 	return &MemoryStore{
-		runs:  make(map[uuid.UUID]RunRow),
-		edges: make(map[string]int),
+		runs:       make(map[uuid.UUID]RunRow),
+		pages:      make(map[uuid.UUID][]PageRecord),
+		edges:      make(map[uuid.UUID]map[[2]string]int),
+		chains:     make(map[uuid.UUID][]RedirectChainRow),
+		linkStatus: make(map[string]LinkStatusRecord),
+		security:   make(map[uuid.UUID]map[string]HostSecurityRecord),
+		hostStates: make(map[uuid.UUID]map[string]HostStateRecord),
+		analytics:  make(map[uuid.UUID]RunAnalyticsRecord),
+		schedules:  make(map[uuid.UUID]ScheduleRecord),
 	}
 }
 
@@ -44,6 +57,7 @@ func (m *MemoryStore) CreateRun(ctx context.Context, cfg RunConfig) (uuid.UUID,
 	m.runs[id] = RunRow{
 		ID:                 id,
 		SeedURL:            cfg.SeedURL,
+		SeedHost:           cfg.SeedHost,
 		Status:             "created",
 		CreatedAt:          time.Now(),
 		MaxDepth:           cfg.MaxDepth,
@@ -54,6 +68,13 @@ func (m *MemoryStore) CreateRun(ctx context.Context, cfg RunConfig) (uuid.UUID,
 		PerHostConcurrency: cfg.PerHostConcurrency,
 		UserAgent:          cfg.UserAgent,
 		RespectRobots:      cfg.RespectRobots,
+		ScheduleID:         uuid.NullUUID{UUID: cfg.ScheduleID, Valid: cfg.ScheduleID != uuid.Nil},
+	}
+	if cfg.Config != nil {
+		run := m.runs[id]
+		run.Config = append([]byte(nil), cfg.Config...)
+		run.ConfigVersion = sql.NullInt64{Int64: int64(cfg.ConfigVersion), Valid: true}
+		m.runs[id] = run
 	}
 	return id, nil
 }
@@ -79,6 +100,23 @@ func (m *MemoryStore) UpdateRunStatus(ctx context.Context, id uuid.UUID, status
 	return nil
 }
 
+func (m *MemoryStore) UpdateRunConfig(ctx context.Context, id uuid.UUID, cfg RunConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.runs[id]
+	if !ok {
+		return errors.New("run not found")
+	}
+	run.MaxPages = cfg.MaxPages
+	run.TimeBudgetSeconds = cfg.TimeBudgetSeconds
+	run.GlobalConcurrency = cfg.GlobalConcurrency
+	run.PerHostConcurrency = cfg.PerHostConcurrency
+	run.Config = append([]byte(nil), cfg.Config...)
+	run.ConfigVersion = sql.NullInt64{Int64: int64(cfg.ConfigVersion), Valid: cfg.Config != nil}
+	m.runs[id] = run
+	return nil
+}
+
 func (m *MemoryStore) GetRun(ctx context.Context, id uuid.UUID) (RunRow, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -89,16 +127,75 @@ func (m *MemoryStore) GetRun(ctx context.Context, id uuid.UUID) (RunRow, error)
 	return run, nil
 }
 
-func (m *MemoryStore) GetRunSummary(ctx context.Context, id uuid.UUID) (RunSummary, error) {
+func (m *MemoryStore) ListRuns(ctx context.Context, q RunQuery) ([]RunRow, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	var summary RunSummary
-	hostSet := make(map[string]struct{})
-	var lastFetched *time.Time
-	for _, page := range m.pages {
-		if page.RunID != id {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	statuses := map[string]bool{}
+	for _, status := range q.Statuses {
+		statuses[status] = true
+	}
+	var out []RunRow
+	for _, run := range m.runs {
+		switch {
+		case len(statuses) > 0 && !statuses[run.Status],
+			q.SeedHost != "" && run.SeedHost != q.SeedHost,
+			q.StopReason != "" && run.StopReason.String != q.StopReason,
+			q.ScheduleID != uuid.Nil && run.ScheduleID.UUID != q.ScheduleID,
+			q.CreatedAfter != nil && run.CreatedAt.Before(*q.CreatedAfter),
+			q.CreatedBefore != nil && !run.CreatedAt.Before(*q.CreatedBefore):
 			continue
 		}
+		if q.After != nil {
+			c := compareRunCursor(RunCursorFor(run, q.Sort), *q.After)
+			if (q.Desc && c >= 0) || (!q.Desc && c <= 0) {
+				continue
+			}
+		}
+		out = append(out, run)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		c := compareRunCursor(RunCursorFor(out[i], q.Sort), RunCursorFor(out[j], q.Sort))
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func compareRunCursor(a, b RunCursor) int {
+	if c := strings.Compare(a.Key, b.Key); c != 0 {
+		return c
+	}
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID.String(), b.ID.String())
+}
+
+func (m *MemoryStore) RunSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]RunSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[uuid.UUID]RunSummary, len(ids))
+	for _, id := range ids {
+		if pages := m.pages[id]; len(pages) > 0 {
+			out[id] = summarizePages(pages)
+		}
+	}
+	return out, nil
+}
+
+func summarizePages(pages []PageRecord) RunSummary {
+	var summary RunSummary
+	hostSet := make(map[string]struct{})
+	for _, page := range pages {
 		if page.ErrClass == "" {
 			summary.PagesFetched++
 		} else {
@@ -108,94 +205,880 @@ func (m *MemoryStore) GetRunSummary(ctx context.Context, id uuid.UUID) (RunSumma
 			hostSet[page.Host] = struct{}{}
 		}
 		summary.TotalBytes += page.SizeBytes
-		if page.FetchedAt != nil {
-			if lastFetched == nil || page.FetchedAt.After(*lastFetched) {
-				lastFetched = page.FetchedAt
-			}
+		if page.FetchedAt != nil && (summary.LastFetchedAt == nil || page.FetchedAt.After(*summary.LastFetchedAt)) {
+			summary.LastFetchedAt = page.FetchedAt
 		}
 	}
 	summary.UniqueHosts = int64(len(hostSet))
-	summary.LastFetchedAt = lastFetched
+	return summary
+}
+
+func (m *MemoryStore) GetRunSummary(ctx context.Context, id uuid.UUID) (RunSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summary := summarizePages(m.pages[id])
+	summary.Skipped = map[string]int64{}
+	for _, rec := range m.skipped {
+		if rec.RunID == id {
+			summary.Skipped[rec.Reason]++
+		}
+	}
 	return summary, nil
 }
 
 func (m *MemoryStore) ListPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error) {
+	return m.QueryPages(ctx, id, PageQuery{Sort: PageSortFetched, Desc: true, Limit: limit})
+}
+
+// QueryPages filters one run's pages and keeps only the first Limit rows in
+// a bounded heap, so a page of results costs O(n log limit).
+func (m *MemoryStore) QueryPages(ctx context.Context, runID uuid.UUID, q PageQuery) ([]PageRow, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	limit := q.Limit
 	if limit <= 0 {
 		limit = 50
 	}
-	var rows []PageRow
-	for _, page := range m.pages {
-		if page.RunID != id {
-			continue
-		}
-		rows = append(rows, PageRow{
-			URL:          page.URL,
-			Host:         page.Host,
-			Depth:        page.Depth,
-			StatusCode:   page.StatusCode,
-			ContentType:  page.ContentType,
-			FetchMS:      page.FetchMS,
-			SizeBytes:    page.SizeBytes,
-			ErrorClass:   page.ErrClass,
-			ErrorMessage: page.ErrMessage,
-			FetchedAt:    page.FetchedAt,
-		})
+	sortKey := q.Sort
+	if !ValidPageSort(sortKey) {
+		sortKey = PageSortFetched
 	}
-	sort.Slice(rows, func(i, j int) bool {
-		ti := rows[i].FetchedAt
-		tj := rows[j].FetchedAt
-		if ti == nil && tj == nil {
-			return rows[i].URL > rows[j].URL
+	// before reports whether a belongs ahead of b in the result order
+	before := func(a, b PageCursor) bool {
+		if q.Desc {
+			return comparePageCursor(a, b) > 0
 		}
-		if ti == nil {
-			return false
+		return comparePageCursor(a, b) < 0
+	}
+	top := &pageHeap{before: before}
+	for i, page := range m.pages[runID] {
+		if !q.matches(page) {
+			continue
 		}
-		if tj == nil {
-			return true
+		row := page.row(int64(i + 1))
+		cur := PageCursorFor(row, sortKey)
+		if q.After != nil && !before(*q.After, cur) {
+			continue
 		}
-		return ti.After(*tj)
-	})
+		if top.Len() < limit {
+			heap.Push(top, pageEntry{row: row, cur: cur})
+		} else if before(cur, top.entries[0].cur) {
+			top.entries[0] = pageEntry{row: row, cur: cur}
+			heap.Fix(top, 0)
+		}
+	}
+	rows := make([]PageRow, top.Len())
+	for i := len(rows) - 1; i >= 0; i-- {
+		rows[i] = heap.Pop(top).(pageEntry).row
+	}
+	return rows, nil
+}
+
+func (q PageQuery) matches(page PageRecord) bool {
+	switch {
+	case q.Host != "" && page.Host != q.Host,
+		q.StatusMin > 0 && (page.StatusCode == 0 || page.StatusCode < q.StatusMin),
+		q.StatusMax > 0 && (page.StatusCode == 0 || page.StatusCode > q.StatusMax),
+		q.ContentType != "" && !strings.HasPrefix(page.ContentType, q.ContentType),
+		q.DepthMin != nil && page.Depth < *q.DepthMin,
+		q.DepthMax != nil && page.Depth > *q.DepthMax,
+		q.SizeMin != nil && page.SizeBytes < *q.SizeMin,
+		q.SizeMax != nil && page.SizeBytes > *q.SizeMax,
+		q.FetchMSMin != nil && page.FetchMS < *q.FetchMSMin,
+		q.FetchMSMax != nil && page.FetchMS > *q.FetchMSMax,
+		q.URLPrefix != "" && !strings.HasPrefix(page.CanonicalURL, q.URLPrefix),
+		q.URLContains != "" && !strings.Contains(page.CanonicalURL, q.URLContains):
+		return false
+	}
+	switch q.ErrorClass {
+	case "":
+		return true
+	case ErrorClassAny:
+		return page.ErrClass != ""
+	case ErrorClassNone:
+		return page.ErrClass == ""
+	}
+	return page.ErrClass == q.ErrorClass
+}
+
+func comparePageCursor(a, b PageCursor) int {
+	if c := a.Time.Compare(b.Time); c != 0 {
+		return c
+	}
+	if a.Num != b.Num {
+		if a.Num < b.Num {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(a.Text, b.Text); c != 0 {
+		return c
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+type pageEntry struct {
+	row PageRow
+	cur PageCursor
+}
+
+// pageHeap keeps the root at the entry that sorts last, so it is the one
+// evicted when a better row turns up.
+type pageHeap struct {
+	entries []pageEntry
+	before  func(a, b PageCursor) bool
+}
+
+func (h *pageHeap) Len() int           { return len(h.entries) }
+func (h *pageHeap) Less(i, j int) bool { return h.before(h.entries[j].cur, h.entries[i].cur) }
+func (h *pageHeap) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *pageHeap) Push(x any)         { h.entries = append(h.entries, x.(pageEntry)) }
+func (h *pageHeap) Pop() any {
+	last := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+func (m *MemoryStore) ListFailedPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 1000
+	}
+	var rows []PageRow
+	for i, page := range m.pages[id] {
+		if page.StatusCode < 400 && page.ErrClass == "" {
+			continue
+		}
+		rows = append(rows, page.row(int64(i+1)))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].CanonicalURL < rows[j].CanonicalURL })
 	if len(rows) > limit {
 		rows = rows[:limit]
 	}
 	return rows, nil
 }
 
+func (m *MemoryStore) GetPage(ctx context.Context, runID uuid.UUID, canonical string) (*PageRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pages := m.pages[runID]
+	for i := len(pages) - 1; i >= 0; i-- {
+		if pages[i].CanonicalURL == canonical {
+			row := pages[i].row(int64(i + 1))
+			return &row, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p PageRecord) row(id int64) PageRow {
+	return PageRow{
+		ID:            id,
+		URL:           p.URL,
+		CanonicalURL:  p.CanonicalURL,
+		Host:          p.Host,
+		Depth:         p.Depth,
+		StatusCode:    p.StatusCode,
+		ContentType:   p.ContentType,
+		FetchMS:       p.FetchMS,
+		SizeBytes:     p.SizeBytes,
+		ErrorClass:    p.ErrClass,
+		ErrorMessage:  p.ErrMessage,
+		Referrer:      p.Referrer,
+		RedirectURL:   p.RedirectURL,
+		OriginURL:     p.OriginURL,
+		RedirectChain: p.RedirectChain,
+		Timing:        p.Timing,
+		Title:         p.Title,
+		ContentHash:   p.ContentHash,
+		DiscoveredAt:  p.DiscoveredAt,
+		FetchedAt:     p.FetchedAt,
+	}
+}
+
 func (m *MemoryStore) InsertPage(ctx context.Context, rec PageRecord) error {
+	return m.InsertPages(ctx, []PageRecord{rec})
+}
+
+func (m *MemoryStore) InsertPages(ctx context.Context, recs []PageRecord) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.pages = append(m.pages, rec)
+	for _, rec := range recs {
+		m.pages[rec.RunID] = append(m.pages[rec.RunID], rec)
+	}
 	return nil
 }
 
 func (m *MemoryStore) InsertError(ctx context.Context, runID uuid.UUID, host, url, class, message string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.errors = append(m.errors, struct {
-		runID   uuid.UUID
-		host    string
-		url     string
-		class   string
-		message string
-		at      time.Time
-	}{runID: runID, host: host, url: url, class: class, message: message, at: time.Now()})
+	m.errors = append(m.errors, ErrorRecord{ID: int64(len(m.errors) + 1), RunID: runID, Host: host, URL: url, Class: class, Message: message, At: time.Now()})
+	return nil
+}
+
+func (m *MemoryStore) InsertErrors(ctx context.Context, recs []ErrorRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rec := range recs {
+		rec.ID = int64(len(m.errors) + 1)
+		m.errors = append(m.errors, rec)
+	}
+	return nil
+}
+
+func (m *MemoryStore) QueryErrors(ctx context.Context, runID uuid.UUID, q ErrorQuery) ([]ErrorRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	var out []ErrorRecord
+	for i := max(q.AfterID, 0); i < int64(len(m.errors)) && len(out) < q.Limit; i++ {
+		rec := m.errors[i]
+		if rec.RunID != runID || (q.Class != "" && rec.Class != q.Class) || (q.Host != "" && rec.Host != q.Host) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) ListErrorGroups(ctx context.Context, runID uuid.UUID, q ErrorGroupQuery) ([]ErrorGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	type sample struct {
+		url string
+		at  time.Time
+	}
+	groups := map[[2]string]*ErrorGroup{}
+	samples := map[[2]string]map[string]time.Time{}
+	for _, rec := range m.errors {
+		if rec.RunID != runID || (q.Class != "" && rec.Class != q.Class) || (q.Host != "" && rec.Host != q.Host) {
+			continue
+		}
+		key := [2]string{rec.Class, rec.Host}
+		g := groups[key]
+		if g == nil {
+			g = &ErrorGroup{Class: rec.Class, Host: rec.Host, FirstAt: rec.At, LastAt: rec.At, SampleURLs: []string{}}
+			groups[key] = g
+			samples[key] = map[string]time.Time{}
+		}
+		g.Count++
+		if rec.At.Before(g.FirstAt) {
+			g.FirstAt = rec.At
+		}
+		if rec.At.After(g.LastAt) {
+			g.LastAt = rec.At
+		}
+		if rec.URL != "" && rec.At.After(samples[key][rec.URL]) {
+			samples[key][rec.URL] = rec.At
+		}
+	}
+	out := make([]ErrorGroup, 0, len(groups))
+	for key, g := range groups {
+		urls := make([]sample, 0, len(samples[key]))
+		for url, at := range samples[key] {
+			urls = append(urls, sample{url, at})
+		}
+		sort.Slice(urls, func(i, j int) bool {
+			if !urls[i].at.Equal(urls[j].at) {
+				return urls[i].at.After(urls[j].at)
+			}
+			return urls[i].url < urls[j].url
+		})
+		for i := 0; i < len(urls) && i < q.Samples; i++ {
+			g.SampleURLs = append(g.SampleURLs, urls[i].url)
+		}
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if out[i].Class != out[j].Class {
+			return out[i].Class < out[j].Class
+		}
+		return out[i].Host < out[j].Host
+	})
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) UpsertEdges(ctx context.Context, recs []EdgeRecord) error {
+	for _, rec := range recs {
+		if err := m.UpsertEdge(ctx, rec.RunID, rec.Src, rec.Dst, rec.Count); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (m *MemoryStore) UpsertEdge(ctx context.Context, runID uuid.UUID, src, dst string, count int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	key := runID.String() + ":" + src + "->" + dst
-	m.edges[key] += count
+	if m.edges[runID] == nil {
+		m.edges[runID] = make(map[[2]string]int)
+	}
+	m.edges[runID][[2]string{src, dst}] += count
 	return nil
 }
 
-func (m *MemoryStore) UpsertHostStat(ctx context.Context, runID uuid.UUID, host string, bucket time.Time, req, errCount, p50, p95 int, bytes int64, reuse float64) error {
+func (m *MemoryStore) ListEdges(ctx context.Context, runID uuid.UUID, q EdgeQuery) ([]EdgeRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	var out []EdgeRecord
+	for key, count := range m.edges[runID] {
+		if q.Host != "" && key[0] != q.Host && key[1] != q.Host {
+			continue
+		}
+		rec := EdgeRecord{RunID: runID, Src: key[0], Dst: key[1], Count: count}
+		if q.After != nil && !edgeBefore(*q.After, rec) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return edgeBefore(EdgeCursor{Count: out[i].Count, Src: out[i].Src, Dst: out[i].Dst}, out[j])
+	})
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// edgeBefore reports whether the edge at cursor c sorts ahead of rec.
+func edgeBefore(c EdgeCursor, rec EdgeRecord) bool {
+	if c.Count != rec.Count {
+		return c.Count > rec.Count
+	}
+	if c.Src != rec.Src {
+		return c.Src < rec.Src
+	}
+	return c.Dst < rec.Dst
+}
+
+func (m *MemoryStore) InsertLinks(ctx context.Context, links []LinkRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links = append(m.links, links...)
 	return nil
 }
 
+func (m *MemoryStore) ListInlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error) {
+	return m.listLinks(runID, limit, func(l LinkRecord) bool { return l.DstURL == url })
+}
+
+func (m *MemoryStore) ListInlinksTo(ctx context.Context, runID uuid.UUID, urls []string, perURL int) ([]LinkRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if perURL <= 0 {
+		perURL = 100
+	}
+	counts := make(map[string]int, len(urls))
+	for _, u := range urls {
+		counts[u] = 0
+	}
+	var rows []LinkRow
+	for _, l := range m.links {
+		n, ok := counts[l.DstURL]
+		if l.RunID != runID || !ok || n >= perURL {
+			continue
+		}
+		counts[l.DstURL] = n + 1
+		rows = append(rows, LinkRow{SrcURL: l.SrcURL, DstURL: l.DstURL, AnchorText: l.AnchorText, Rel: l.Rel, Element: l.Element, Followed: l.Followed})
+	}
+	return rows, nil
+}
+
+func (m *MemoryStore) ListOutlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error) {
+	return m.listLinks(runID, limit, func(l LinkRecord) bool { return l.SrcURL == url })
+}
+
+func (m *MemoryStore) listLinks(runID uuid.UUID, limit int, match func(LinkRecord) bool) ([]LinkRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	var rows []LinkRow
+	for _, l := range m.links {
+		if l.RunID != runID || !match(l) {
+			continue
+		}
+		rows = append(rows, LinkRow{SrcURL: l.SrcURL, DstURL: l.DstURL, AnchorText: l.AnchorText, Rel: l.Rel, Element: l.Element, Followed: l.Followed})
+		if len(rows) >= limit {
+			break
+		}
+	}
+	return rows, nil
+}
+
+func (m *MemoryStore) ListLinkEdges(ctx context.Context, runID uuid.UUID, q LinkEdgeQuery) ([]LinkEdge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	counts := map[[2]string]int{}
+	for _, l := range m.links {
+		if l.RunID == runID {
+			counts[[2]string{l.SrcURL, l.DstURL}]++
+		}
+	}
+	var out []LinkEdge
+	for key, count := range counts {
+		if count < q.MinCount {
+			continue
+		}
+		if q.After != nil && (key[0] < q.After.Src || key[0] == q.After.Src && key[1] <= q.After.Dst) {
+			continue
+		}
+		out = append(out, LinkEdge{Src: key[0], Dst: key[1], Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Src != out[j].Src {
+			return out[i].Src < out[j].Src
+		}
+		return out[i].Dst < out[j].Dst
+	})
+	if len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) UpsertLinkStatuses(ctx context.Context, recs []LinkStatusRecord) error {
+	for _, rec := range recs {
+		if err := m.UpsertLinkStatus(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpsertLinkStatus(ctx context.Context, rec LinkStatusRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.linkStatus[rec.RunID.String()+":"+rec.CanonicalURL] = rec
+	return nil
+}
+
+func (m *MemoryStore) ListLinkStatuses(ctx context.Context, runID uuid.UUID, failedOnly bool, limit int) ([]LinkStatusRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	var rows []LinkStatusRow
+	for _, rec := range m.linkStatus {
+		if rec.RunID != runID {
+			continue
+		}
+		if failedOnly && rec.StatusCode < 400 && rec.ErrClass == "" {
+			continue
+		}
+		rows = append(rows, LinkStatusRow{
+			URL:          rec.URL,
+			CanonicalURL: rec.CanonicalURL,
+			Host:         rec.Host,
+			StatusCode:   rec.StatusCode,
+			Method:       rec.Method,
+			FinalURL:     rec.FinalURL,
+			FetchMS:      rec.FetchMS,
+			ErrorClass:   rec.ErrClass,
+			ErrorMessage: rec.ErrMessage,
+			CheckedAt:    rec.CheckedAt,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].CanonicalURL < rows[j].CanonicalURL })
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (m *MemoryStore) InsertRedirectChains(ctx context.Context, recs []RedirectChainRecord) error {
+	for _, rec := range recs {
+		if err := m.InsertRedirectChain(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) InsertRedirectChain(ctx context.Context, rec RedirectChainRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chains[rec.RunID] = append(m.chains[rec.RunID], RedirectChainRow{
+		OriginURL:   rec.OriginURL,
+		FinalURL:    rec.FinalURL,
+		FinalStatus: rec.FinalStatus,
+		HopCount:    len(rec.Hops),
+		Hops:        rec.Hops,
+		Outcome:     rec.Outcome,
+		At:          time.Now(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) ListRedirectChains(ctx context.Context, runID uuid.UUID, minHops, limit int) ([]RedirectChainRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	var rows []RedirectChainRow
+	for _, row := range m.chains[runID] {
+		if row.HopCount < minHops {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].HopCount != rows[j].HopCount {
+			return rows[i].HopCount > rows[j].HopCount
+		}
+		return rows[i].OriginURL < rows[j].OriginURL
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (m *MemoryStore) UpsertHostStats(ctx context.Context, recs []HostStatRecord) error {
+	for _, rec := range recs {
+		if err := m.UpsertHostStat(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpsertHostStat(ctx context.Context, rec HostStatRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.hostStats {
+		if existing.RunID == rec.RunID && existing.Host == rec.Host && existing.BucketStart.Equal(rec.BucketStart) {
+			m.hostStats[i] = rec
+			return nil
+		}
+	}
+	m.hostStats = append(m.hostStats, rec)
+	return nil
+}
+
+func (m *MemoryStore) UpsertHostSecurities(ctx context.Context, recs []HostSecurityRecord) error {
+	for _, rec := range recs {
+		if err := m.UpsertHostSecurity(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpsertHostSecurity(ctx context.Context, rec HostSecurityRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.security[rec.RunID] == nil {
+		m.security[rec.RunID] = make(map[string]HostSecurityRecord)
+	}
+	m.security[rec.RunID][rec.Host] = rec
+	return nil
+}
+
+func (m *MemoryStore) ListHostSecurity(ctx context.Context, runID uuid.UUID) ([]HostSecurityRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]HostSecurityRecord, 0, len(m.security[runID]))
+	for _, rec := range m.security[runID] {
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out, nil
+}
+
+func (m *MemoryStore) UpsertHostStates(ctx context.Context, recs []HostStateRecord) error {
+	for _, rec := range recs {
+		if err := m.UpsertHostState(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpsertHostState(ctx context.Context, rec HostStateRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hostStates[rec.RunID] == nil {
+		m.hostStates[rec.RunID] = make(map[string]HostStateRecord)
+	}
+	m.hostStates[rec.RunID][rec.Host] = rec
+	return nil
+}
+
+func (m *MemoryStore) ListHostStates(ctx context.Context, runID uuid.UUID) ([]HostStateRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []HostStateRecord
+	for _, rec := range m.hostStates[runID] {
+		if rec.RobotsState != "" || rec.CircuitState != "" {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out, nil
+}
+
+func (m *MemoryStore) HostPageCounts(ctx context.Context, runID uuid.UUID) ([]HostCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byHost := map[string]*HostCounts{}
+	for _, rec := range m.pages[runID] {
+		c := byHost[rec.Host]
+		if c == nil {
+			c = &HostCounts{Host: rec.Host}
+			byHost[rec.Host] = c
+		}
+		c.Pages++
+		if rec.FetchedAt != nil {
+			c.Fetched++
+		}
+		if rec.ErrClass != "" {
+			c.Failed++
+		}
+		switch {
+		case rec.StatusCode >= 200 && rec.StatusCode < 300:
+			c.Status2xx++
+		case rec.StatusCode >= 300 && rec.StatusCode < 400:
+			c.Status3xx++
+		case rec.StatusCode >= 400 && rec.StatusCode < 500:
+			c.Status4xx++
+		case rec.StatusCode >= 500 && rec.StatusCode < 600:
+			c.Status5xx++
+		}
+		c.Bytes += rec.SizeBytes
+		if c.Pages == 1 || rec.Depth < c.MinDepth {
+			c.MinDepth = rec.Depth
+		}
+	}
+	out := make([]HostCounts, 0, len(byHost))
+	for _, c := range byHost {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out, nil
+}
+
+func (m *MemoryStore) InsertSkip(ctx context.Context, rec SkipRecord) error {
+	return m.InsertSkips(ctx, []SkipRecord{rec})
+}
+
+func (m *MemoryStore) InsertSkips(ctx context.Context, recs []SkipRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.skipped = append(m.skipped, recs...)
+	return nil
+}
+
+func (m *MemoryStore) ListSkipped(ctx context.Context, runID uuid.UUID, reason string, limit int) ([]SkipRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	var out []SkipRecord
+	for i := len(m.skipped) - 1; i >= 0 && len(out) < limit; i-- {
+		rec := m.skipped[i]
+		if rec.RunID != runID || (reason != "" && rec.Reason != reason) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) ListHostStats(ctx context.Context, runID uuid.UUID) ([]HostStatRecord, error) {
+	return m.QueryHostStats(ctx, runID, HostStatQuery{})
+}
+
+func (m *MemoryStore) QueryHostStats(ctx context.Context, runID uuid.UUID, q HostStatQuery) ([]HostStatRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []HostStatRecord
+	for _, rec := range m.hostStats {
+		if rec.RunID != runID || (q.Host != "" && rec.Host != q.Host) {
+			continue
+		}
+		if (q.From != nil && rec.BucketStart.Before(*q.From)) || (q.To != nil && !rec.BucketStart.Before(*q.To)) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Host != out[j].Host {
+			return out[i].Host < out[j].Host
+		}
+		return out[i].BucketStart.Before(out[j].BucketStart)
+	})
+	return out, nil
+}
+
 func sqlNullTime(t time.Time) sql.NullTime {
 	return sql.NullTime{Time: t, Valid: true}
 }
+
+func (m *MemoryStore) UpsertRunAnalytics(ctx context.Context, rec RunAnalyticsRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.Result = append([]byte(nil), rec.Result...)
+	m.analytics[rec.RunID] = rec
+	return nil
+}
+
+func (m *MemoryStore) GetRunAnalytics(ctx context.Context, runID uuid.UUID) (*RunAnalyticsRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.analytics[runID]
+	if !ok {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (m *MemoryStore) InsertURLEvent(ctx context.Context, rec URLEvent) error {
+	return m.InsertURLEvents(ctx, []URLEvent{rec})
+}
+
+func (m *MemoryStore) InsertURLEvents(ctx context.Context, recs []URLEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.urlEvents = append(m.urlEvents, recs...)
+	return nil
+}
+
+func (m *MemoryStore) ListURLEvents(ctx context.Context, runID uuid.UUID, canonical string, limit int) ([]URLEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 500
+	}
+	var out []URLEvent
+	for _, rec := range m.urlEvents {
+		if rec.RunID != runID || rec.URL != canonical {
+			continue
+		}
+		out = append(out, rec)
+		if len(out) == limit {
+			break
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out, nil
+}
+
+func (m *MemoryStore) InsertRunEvent(ctx context.Context, rec RunEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runEvents = append(m.runEvents, rec)
+	return nil
+}
+
+func (m *MemoryStore) ListRunEvents(ctx context.Context, runID uuid.UUID, limit int) ([]RunEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		limit = 500
+	}
+	var out []RunEvent
+	for _, rec := range m.runEvents {
+		if rec.RunID == runID {
+			out = append(out, rec)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) CreateSchedule(ctx context.Context, rec ScheduleRecord) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.ID = uuid.New()
+	now := time.Now().UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = now
+	}
+	rec.Config = append([]byte(nil), rec.Config...)
+	m.schedules[rec.ID] = rec
+	return rec.ID, nil
+}
+
+func (m *MemoryStore) UpdateSchedule(ctx context.Context, rec ScheduleRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.schedules[rec.ID]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	rec.CreatedAt = old.CreatedAt
+	rec.Config = append([]byte(nil), rec.Config...)
+	m.schedules[rec.ID] = rec
+	return nil
+}
+
+func (m *MemoryStore) GetSchedule(ctx context.Context, id uuid.UUID) (*ScheduleRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.schedules[id]
+	if !ok {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (m *MemoryStore) ListSchedules(ctx context.Context) ([]ScheduleRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ScheduleRecord, 0, len(m.schedules))
+	for _, rec := range m.schedules {
+		out = append(out, rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+func (m *MemoryStore) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[id]; !ok {
+		return ErrScheduleNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
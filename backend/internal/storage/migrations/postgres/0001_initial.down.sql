@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS url_events;
+DROP TABLE IF EXISTS skipped;
+DROP TABLE IF EXISTS redirect_chains;
+DROP TABLE IF EXISTS link_status;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS errors;
+DROP TABLE IF EXISTS edges;
+DROP TABLE IF EXISTS host_stats;
+DROP TABLE IF EXISTS hosts;
+DROP TABLE IF EXISTS pages;
+DROP TABLE IF EXISTS runs;
@@ -0,0 +1,171 @@
+-- Baseline schema. Every statement is idempotent so databases created before
+-- versioned migrations existed adopt it without changes.
+CREATE TABLE IF NOT EXISTS runs (
+	id uuid PRIMARY KEY,
+	seed_url text NOT NULL,
+	status text NOT NULL,
+	created_at timestamptz NOT NULL,
+	started_at timestamptz,
+	stopped_at timestamptz,
+	stop_reason text,
+	max_depth int,
+	max_pages int,
+	time_budget_seconds int,
+	max_links_per_page int,
+	global_concurrency int,
+	per_host_concurrency int,
+	user_agent text,
+	respect_robots boolean
+);
+CREATE INDEX IF NOT EXISTS runs_status_idx ON runs(status);
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS stop_reason text;
+CREATE TABLE IF NOT EXISTS pages (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	url text NOT NULL,
+	canonical_url text NOT NULL,
+	host text NOT NULL,
+	depth int NOT NULL,
+	status_code int,
+	content_type text,
+	fetch_ms int,
+	size_bytes bigint,
+	error_class text,
+	error_message text,
+	discovered_at timestamptz NOT NULL,
+	fetched_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS pages_run_id_idx ON pages(run_id);
+CREATE INDEX IF NOT EXISTS pages_host_idx ON pages(run_id, host);
+CREATE INDEX IF NOT EXISTS pages_canonical_idx ON pages(run_id, canonical_url);
+CREATE TABLE IF NOT EXISTS hosts (
+	run_id uuid REFERENCES runs(id),
+	host text NOT NULL,
+	robots_state text,
+	circuit_state text,
+	inflight int,
+	last_error_at timestamptz,
+	last_429_at timestamptz,
+	PRIMARY KEY (run_id, host)
+);
+CREATE TABLE IF NOT EXISTS host_stats (
+	run_id uuid REFERENCES runs(id),
+	host text NOT NULL,
+	bucket_start timestamptz NOT NULL,
+	req_count int,
+	err_count int,
+	p50_ms int,
+	p95_ms int,
+	bytes bigint,
+	reuse_rate float,
+	PRIMARY KEY (run_id, host, bucket_start)
+);
+CREATE TABLE IF NOT EXISTS edges (
+	run_id uuid REFERENCES runs(id),
+	src_host text NOT NULL,
+	dst_host text NOT NULL,
+	count int NOT NULL,
+	PRIMARY KEY (run_id, src_host, dst_host)
+);
+CREATE TABLE IF NOT EXISTS errors (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	host text,
+	url text,
+	class text NOT NULL,
+	message text,
+	at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS errors_run_id_idx ON errors(run_id);
+CREATE INDEX IF NOT EXISTS errors_class_idx ON errors(run_id, class);
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS referrer_url text;
+CREATE TABLE IF NOT EXISTS links (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	src_url text NOT NULL,
+	dst_url text NOT NULL,
+	anchor_text text,
+	rel text,
+	element text NOT NULL,
+	followed boolean NOT NULL
+);
+CREATE INDEX IF NOT EXISTS links_src_idx ON links(run_id, src_url);
+CREATE INDEX IF NOT EXISTS links_dst_idx ON links(run_id, dst_url);
+CREATE TABLE IF NOT EXISTS link_status (
+	run_id uuid REFERENCES runs(id),
+	canonical_url text NOT NULL,
+	url text NOT NULL,
+	host text NOT NULL,
+	status_code int,
+	method text,
+	final_url text,
+	fetch_ms int,
+	error_class text,
+	error_message text,
+	checked_at timestamptz NOT NULL,
+	PRIMARY KEY (run_id, canonical_url)
+);
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS redirect_url text;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS origin_url text;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS redirect_chain jsonb;
+CREATE TABLE IF NOT EXISTS redirect_chains (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	origin_url text NOT NULL,
+	final_url text NOT NULL,
+	final_status int,
+	hop_count int NOT NULL,
+	hops jsonb NOT NULL,
+	outcome text NOT NULL,
+	at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS redirect_chains_hops_idx ON redirect_chains(run_id, hop_count);
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS dns_ms int;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS connect_ms int;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS tls_ms int;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS ttfb_ms int;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS transfer_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS dns_p50_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS dns_p95_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS connect_p50_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS connect_p95_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS tls_p50_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS tls_p95_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS ttfb_p50_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS ttfb_p95_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS transfer_p50_ms int;
+ALTER TABLE host_stats ADD COLUMN IF NOT EXISTS transfer_p95_ms int;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS tls_version text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS tls_cipher text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_subject text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_issuer text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_sans jsonb;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_not_after timestamptz;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_verified bool;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS cert_error text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS hsts text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS csp text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS x_frame_options text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS referrer_policy text;
+ALTER TABLE hosts ADD COLUMN IF NOT EXISTS inspected_at timestamptz;
+CREATE TABLE IF NOT EXISTS skipped (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	url text NOT NULL,
+	host text,
+	depth int,
+	reason text NOT NULL,
+	detail text,
+	referrer_url text,
+	at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS skipped_reason_idx ON skipped(run_id, reason);
+CREATE TABLE IF NOT EXISTS url_events (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	url text NOT NULL,
+	event text NOT NULL,
+	detail jsonb,
+	at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS url_events_url_idx ON url_events(run_id, url);
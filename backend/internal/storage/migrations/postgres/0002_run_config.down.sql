@@ -0,0 +1,2 @@
+ALTER TABLE runs DROP COLUMN IF EXISTS config_version;
+ALTER TABLE runs DROP COLUMN IF EXISTS config;
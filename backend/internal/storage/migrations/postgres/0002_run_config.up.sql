@@ -0,0 +1,2 @@
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS config jsonb;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS config_version int;
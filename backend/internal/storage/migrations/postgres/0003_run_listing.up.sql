@@ -0,0 +1,6 @@
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS seed_host text;
+-- matches url.Hostname(): no userinfo, port or IPv6 brackets
+UPDATE runs SET seed_host = lower(btrim(substring(seed_url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?(\[[^]/?#]*\]|[^/:?#]+)'), '[]'))
+WHERE seed_host IS NULL;
+CREATE INDEX IF NOT EXISTS runs_created_idx ON runs(created_at, id);
+CREATE INDEX IF NOT EXISTS runs_seed_host_idx ON runs(seed_host, created_at);
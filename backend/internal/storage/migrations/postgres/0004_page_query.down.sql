@@ -0,0 +1,11 @@
+CREATE INDEX IF NOT EXISTS pages_host_idx ON pages(run_id, host);
+CREATE INDEX IF NOT EXISTS pages_canonical_idx ON pages(run_id, canonical_url);
+DROP INDEX IF EXISTS pages_content_type_sort_idx;
+DROP INDEX IF EXISTS pages_fetch_ms_sort_idx;
+DROP INDEX IF EXISTS pages_size_sort_idx;
+DROP INDEX IF EXISTS pages_depth_sort_idx;
+DROP INDEX IF EXISTS pages_status_sort_idx;
+DROP INDEX IF EXISTS pages_host_sort_idx;
+DROP INDEX IF EXISTS pages_url_prefix_idx;
+DROP INDEX IF EXISTS pages_url_sort_idx;
+DROP INDEX IF EXISTS pages_fetched_sort_idx;
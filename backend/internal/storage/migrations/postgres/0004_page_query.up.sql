@@ -0,0 +1,14 @@
+-- One index per sortable column of GET /runs/{id}/pages, each ending in id
+-- for keyset pagination. Expressions must match SQLStore.pageSortExpr.
+CREATE INDEX IF NOT EXISTS pages_fetched_sort_idx ON pages(run_id, (COALESCE(fetched_at, TIMESTAMPTZ '0001-01-01 00:00:00+00')), id);
+CREATE INDEX IF NOT EXISTS pages_url_sort_idx ON pages(run_id, canonical_url, id);
+CREATE INDEX IF NOT EXISTS pages_url_prefix_idx ON pages(run_id, canonical_url text_pattern_ops);
+CREATE INDEX IF NOT EXISTS pages_host_sort_idx ON pages(run_id, host, id);
+CREATE INDEX IF NOT EXISTS pages_status_sort_idx ON pages(run_id, (COALESCE(status_code, 0)), id);
+CREATE INDEX IF NOT EXISTS pages_depth_sort_idx ON pages(run_id, depth, id);
+CREATE INDEX IF NOT EXISTS pages_size_sort_idx ON pages(run_id, (COALESCE(size_bytes, 0)), id);
+CREATE INDEX IF NOT EXISTS pages_fetch_ms_sort_idx ON pages(run_id, (COALESCE(fetch_ms, 0)), id);
+CREATE INDEX IF NOT EXISTS pages_content_type_sort_idx ON pages(run_id, (COALESCE(content_type, '')), id);
+-- superseded by the host and url sort indexes
+DROP INDEX IF EXISTS pages_host_idx;
+DROP INDEX IF EXISTS pages_canonical_idx;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS run_analytics (
+	run_id uuid PRIMARY KEY REFERENCES runs(id),
+	version int NOT NULL,
+	result jsonb NOT NULL,
+	computed_at timestamptz NOT NULL
+);
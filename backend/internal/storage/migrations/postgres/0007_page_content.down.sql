@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS links_src_idx;
+DROP INDEX IF EXISTS links_edge_idx;
+CREATE INDEX IF NOT EXISTS links_edge_idx ON links(run_id, src_url, dst_url);
+DROP INDEX IF EXISTS pages_canonical_idx;
+DROP INDEX IF EXISTS pages_url_sort_idx;
+CREATE INDEX IF NOT EXISTS pages_url_sort_idx ON pages(run_id, canonical_url, id);
+ALTER TABLE pages DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE pages DROP COLUMN IF EXISTS title;
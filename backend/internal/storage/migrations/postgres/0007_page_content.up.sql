@@ -0,0 +1,11 @@
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS title text;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS content_hash text;
+-- URL keysets compare in byte order (COLLATE "C") so runs can be
+-- merge-joined by canonical URL. Indexes only serve equality under their
+-- own collation, so the default-collation lookups get their indexes back.
+DROP INDEX IF EXISTS pages_url_sort_idx;
+CREATE INDEX IF NOT EXISTS pages_url_sort_idx ON pages(run_id, canonical_url COLLATE "C", id);
+CREATE INDEX IF NOT EXISTS pages_canonical_idx ON pages(run_id, canonical_url);
+DROP INDEX IF EXISTS links_edge_idx;
+CREATE INDEX IF NOT EXISTS links_edge_idx ON links(run_id, src_url COLLATE "C", dst_url COLLATE "C");
+CREATE INDEX IF NOT EXISTS links_src_idx ON links(run_id, src_url);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS schedules (
+	id uuid PRIMARY KEY,
+	name text NOT NULL,
+	cron text NOT NULL,
+	timezone text NOT NULL,
+	overlap text NOT NULL,
+	enabled boolean NOT NULL,
+	config jsonb NOT NULL,
+	config_version int NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL
+);
+-- no foreign key: runs outlive the schedule that started them
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS schedule_id uuid;
+CREATE INDEX IF NOT EXISTS runs_schedule_idx ON runs(schedule_id, created_at);
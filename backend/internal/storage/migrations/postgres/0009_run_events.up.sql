@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS run_events (
+	id bigserial PRIMARY KEY,
+	run_id uuid REFERENCES runs(id),
+	kind text NOT NULL,
+	actor text NOT NULL,
+	detail jsonb,
+	at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS run_events_run_idx ON run_events(run_id, at);
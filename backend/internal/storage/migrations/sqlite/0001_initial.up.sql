@@ -0,0 +1,170 @@
+-- Baseline schema; IF NOT EXISTS lets databases created before versioned
+-- migrations existed adopt it without changes.
+CREATE TABLE IF NOT EXISTS runs (
+	id text PRIMARY KEY,
+	seed_url text NOT NULL,
+	status text NOT NULL,
+	created_at timestamp NOT NULL,
+	started_at timestamp,
+	stopped_at timestamp,
+	stop_reason text,
+	max_depth int,
+	max_pages int,
+	time_budget_seconds int,
+	max_links_per_page int,
+	global_concurrency int,
+	per_host_concurrency int,
+	user_agent text,
+	respect_robots boolean
+);
+CREATE INDEX IF NOT EXISTS runs_status_idx ON runs(status);
+CREATE TABLE IF NOT EXISTS pages (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	url text NOT NULL,
+	canonical_url text NOT NULL,
+	host text NOT NULL,
+	depth int NOT NULL,
+	status_code int,
+	content_type text,
+	fetch_ms int,
+	size_bytes integer,
+	error_class text,
+	error_message text,
+	discovered_at timestamp NOT NULL,
+	fetched_at timestamp,
+	referrer_url text,
+	redirect_url text,
+	origin_url text,
+	redirect_chain text,
+	dns_ms int,
+	connect_ms int,
+	tls_ms int,
+	ttfb_ms int,
+	transfer_ms int
+);
+CREATE INDEX IF NOT EXISTS pages_run_id_idx ON pages(run_id);
+CREATE INDEX IF NOT EXISTS pages_host_idx ON pages(run_id, host);
+CREATE INDEX IF NOT EXISTS pages_canonical_idx ON pages(run_id, canonical_url);
+CREATE TABLE IF NOT EXISTS hosts (
+	run_id text REFERENCES runs(id),
+	host text NOT NULL,
+	robots_state text,
+	circuit_state text,
+	inflight int,
+	last_error_at timestamp,
+	last_429_at timestamp,
+	tls_version text,
+	tls_cipher text,
+	cert_subject text,
+	cert_issuer text,
+	cert_sans text,
+	cert_not_after timestamp,
+	cert_verified boolean,
+	cert_error text,
+	hsts text,
+	csp text,
+	x_frame_options text,
+	referrer_policy text,
+	inspected_at timestamp,
+	PRIMARY KEY (run_id, host)
+);
+CREATE TABLE IF NOT EXISTS host_stats (
+	run_id text REFERENCES runs(id),
+	host text NOT NULL,
+	bucket_start timestamp NOT NULL,
+	req_count int,
+	err_count int,
+	p50_ms int,
+	p95_ms int,
+	bytes integer,
+	reuse_rate real,
+	dns_p50_ms int,
+	dns_p95_ms int,
+	connect_p50_ms int,
+	connect_p95_ms int,
+	tls_p50_ms int,
+	tls_p95_ms int,
+	ttfb_p50_ms int,
+	ttfb_p95_ms int,
+	transfer_p50_ms int,
+	transfer_p95_ms int,
+	PRIMARY KEY (run_id, host, bucket_start)
+);
+CREATE TABLE IF NOT EXISTS edges (
+	run_id text REFERENCES runs(id),
+	src_host text NOT NULL,
+	dst_host text NOT NULL,
+	count int NOT NULL,
+	PRIMARY KEY (run_id, src_host, dst_host)
+);
+CREATE TABLE IF NOT EXISTS errors (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	host text,
+	url text,
+	class text NOT NULL,
+	message text,
+	at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS errors_run_id_idx ON errors(run_id);
+CREATE INDEX IF NOT EXISTS errors_class_idx ON errors(run_id, class);
+CREATE TABLE IF NOT EXISTS links (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	src_url text NOT NULL,
+	dst_url text NOT NULL,
+	anchor_text text,
+	rel text,
+	element text NOT NULL,
+	followed boolean NOT NULL
+);
+CREATE INDEX IF NOT EXISTS links_src_idx ON links(run_id, src_url);
+CREATE INDEX IF NOT EXISTS links_dst_idx ON links(run_id, dst_url);
+CREATE TABLE IF NOT EXISTS link_status (
+	run_id text REFERENCES runs(id),
+	canonical_url text NOT NULL,
+	url text NOT NULL,
+	host text NOT NULL,
+	status_code int,
+	method text,
+	final_url text,
+	fetch_ms int,
+	error_class text,
+	error_message text,
+	checked_at timestamp NOT NULL,
+	PRIMARY KEY (run_id, canonical_url)
+);
+CREATE TABLE IF NOT EXISTS redirect_chains (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	origin_url text NOT NULL,
+	final_url text NOT NULL,
+	final_status int,
+	hop_count int NOT NULL,
+	hops text NOT NULL,
+	outcome text NOT NULL,
+	at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS redirect_chains_hops_idx ON redirect_chains(run_id, hop_count);
+CREATE TABLE IF NOT EXISTS skipped (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	url text NOT NULL,
+	host text,
+	depth int,
+	reason text NOT NULL,
+	detail text,
+	referrer_url text,
+	at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS skipped_reason_idx ON skipped(run_id, reason);
+CREATE TABLE IF NOT EXISTS url_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	url text NOT NULL,
+	event text NOT NULL,
+	detail text,
+	at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS url_events_url_idx ON url_events(run_id, url);
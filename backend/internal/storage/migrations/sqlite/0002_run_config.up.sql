@@ -0,0 +1,2 @@
+ALTER TABLE runs ADD COLUMN config text;
+ALTER TABLE runs ADD COLUMN config_version int;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS runs_seed_host_idx;
+DROP INDEX IF EXISTS runs_created_idx;
+ALTER TABLE runs DROP COLUMN seed_host;
@@ -0,0 +1,14 @@
+ALTER TABLE runs ADD COLUMN seed_host text;
+-- no regex support: cut the authority out of the URL step by step so the
+-- result matches url.Hostname(). Each step leaves a bare host unchanged.
+UPDATE runs SET seed_host = substr(seed_url, instr(seed_url, '://') + 3)
+WHERE seed_host IS NULL AND instr(seed_url, '://') > 0;
+UPDATE runs SET seed_host = substr(seed_host, 1, instr(seed_host, '/') - 1) WHERE instr(seed_host, '/') > 0;
+UPDATE runs SET seed_host = substr(seed_host, 1, instr(seed_host, '?') - 1) WHERE instr(seed_host, '?') > 0;
+UPDATE runs SET seed_host = substr(seed_host, 1, instr(seed_host, '#') - 1) WHERE instr(seed_host, '#') > 0;
+UPDATE runs SET seed_host = substr(seed_host, instr(seed_host, '@') + 1) WHERE instr(seed_host, '@') > 0;
+UPDATE runs SET seed_host = substr(seed_host, 1, instr(seed_host, ':') - 1) WHERE instr(seed_host, ':') > 0 AND seed_host NOT LIKE '[%';
+UPDATE runs SET seed_host = substr(seed_host, 2, instr(seed_host, ']') - 2) WHERE seed_host LIKE '[%' AND instr(seed_host, ']') > 0;
+UPDATE runs SET seed_host = lower(seed_host) WHERE seed_host IS NOT NULL;
+CREATE INDEX IF NOT EXISTS runs_created_idx ON runs(created_at, id);
+CREATE INDEX IF NOT EXISTS runs_seed_host_idx ON runs(seed_host, created_at);
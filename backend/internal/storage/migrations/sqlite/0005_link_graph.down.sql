@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS links_src_idx ON links(run_id, src_url);
+DROP INDEX IF EXISTS links_edge_idx;
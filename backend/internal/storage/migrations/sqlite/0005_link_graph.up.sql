@@ -0,0 +1,4 @@
+-- Serves ListLinkEdges, which groups links by (src_url, dst_url) in key
+-- order; it also covers the src_url prefix used by outlink lookups.
+CREATE INDEX IF NOT EXISTS links_edge_idx ON links(run_id, src_url, dst_url);
+DROP INDEX IF EXISTS links_src_idx;
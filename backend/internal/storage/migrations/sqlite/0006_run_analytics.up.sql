@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS run_analytics (
+	run_id text PRIMARY KEY REFERENCES runs(id),
+	version int NOT NULL,
+	result text NOT NULL,
+	computed_at timestamp NOT NULL
+);
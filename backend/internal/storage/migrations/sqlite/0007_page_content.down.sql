@@ -0,0 +1,2 @@
+ALTER TABLE pages DROP COLUMN content_hash;
+ALTER TABLE pages DROP COLUMN title;
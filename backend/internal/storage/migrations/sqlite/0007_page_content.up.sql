@@ -0,0 +1,2 @@
+ALTER TABLE pages ADD COLUMN title text;
+ALTER TABLE pages ADD COLUMN content_hash text;
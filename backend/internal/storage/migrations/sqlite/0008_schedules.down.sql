@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS runs_schedule_idx;
+ALTER TABLE runs DROP COLUMN schedule_id;
+DROP TABLE IF EXISTS schedules;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS run_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	run_id text REFERENCES runs(id),
+	kind text NOT NULL,
+	actor text NOT NULL,
+	detail text,
+	at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS run_events_run_idx ON run_events(run_id, at);
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"database/sql"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is the embedded single-node backend. Queries are shared with
+// SQLStore; only the migrations differ, since SQLite has no uuid, jsonb or
+// timestamptz types and cannot add columns conditionally.
+type SQLiteStore struct {
+	*SQLStore
+}
+
+// OpenSQLite opens (or creates) the database file at path in WAL mode.
+// Transactions take the write lock up front so concurrent batch writers wait
+// on busy_timeout instead of failing to upgrade a read lock.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(on)")
+	params.Add("_pragma", "synchronous(normal)")
+	params.Set("_txlock", "immediate")
+	// sortable text timestamps instead of time.Time.String
+	params.Set("_time_format", "sqlite")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLite(db), nil
+}
+
+func NewSQLite(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{SQLStore: &SQLStore{db: db, maxParams: 32766, dialect: DialectSQLite}}
+}
@@ -3,6 +3,10 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,21 +17,72 @@ type Store interface {
 	Migrate(ctx context.Context) error
 	CreateRun(ctx context.Context, cfg RunConfig) (uuid.UUID, error)
 	UpdateRunStatus(ctx context.Context, id uuid.UUID, status string, startedAt, stoppedAt *time.Time, stopReason *string) error
+	UpdateRunConfig(ctx context.Context, id uuid.UUID, cfg RunConfig) error
 	GetRun(ctx context.Context, id uuid.UUID) (RunRow, error)
 	GetRunSummary(ctx context.Context, id uuid.UUID) (RunSummary, error)
+	ListRuns(ctx context.Context, q RunQuery) ([]RunRow, error)
+	RunSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]RunSummary, error)
 	ListPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error)
+	QueryPages(ctx context.Context, runID uuid.UUID, q PageQuery) ([]PageRow, error)
+	ListFailedPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error)
 	InsertPage(ctx context.Context, rec PageRecord) error
+	InsertPages(ctx context.Context, recs []PageRecord) error
 	InsertError(ctx context.Context, runID uuid.UUID, host, url, class, message string) error
+	InsertErrors(ctx context.Context, recs []ErrorRecord) error
+	ListErrorGroups(ctx context.Context, runID uuid.UUID, q ErrorGroupQuery) ([]ErrorGroup, error)
+	QueryErrors(ctx context.Context, runID uuid.UUID, q ErrorQuery) ([]ErrorRecord, error)
 	UpsertEdge(ctx context.Context, runID uuid.UUID, src, dst string, count int) error
-	UpsertHostStat(ctx context.Context, runID uuid.UUID, host string, bucket time.Time, req, errCount, p50, p95 int, bytes int64, reuse float64) error
+	UpsertEdges(ctx context.Context, recs []EdgeRecord) error
+	ListEdges(ctx context.Context, runID uuid.UUID, q EdgeQuery) ([]EdgeRecord, error)
+	InsertLinks(ctx context.Context, links []LinkRecord) error
+	ListInlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error)
+	ListInlinksTo(ctx context.Context, runID uuid.UUID, urls []string, perURL int) ([]LinkRow, error)
+	ListOutlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error)
+	ListLinkEdges(ctx context.Context, runID uuid.UUID, q LinkEdgeQuery) ([]LinkEdge, error)
+	UpsertLinkStatus(ctx context.Context, rec LinkStatusRecord) error
+	UpsertLinkStatuses(ctx context.Context, recs []LinkStatusRecord) error
+	ListLinkStatuses(ctx context.Context, runID uuid.UUID, failedOnly bool, limit int) ([]LinkStatusRow, error)
+	InsertRedirectChain(ctx context.Context, rec RedirectChainRecord) error
+	InsertRedirectChains(ctx context.Context, recs []RedirectChainRecord) error
+	ListRedirectChains(ctx context.Context, runID uuid.UUID, minHops, limit int) ([]RedirectChainRow, error)
+	UpsertHostStat(ctx context.Context, rec HostStatRecord) error
+	UpsertHostStats(ctx context.Context, recs []HostStatRecord) error
+	ListHostStats(ctx context.Context, runID uuid.UUID) ([]HostStatRecord, error)
+	QueryHostStats(ctx context.Context, runID uuid.UUID, q HostStatQuery) ([]HostStatRecord, error)
+	UpsertHostSecurity(ctx context.Context, rec HostSecurityRecord) error
+	UpsertHostSecurities(ctx context.Context, recs []HostSecurityRecord) error
+	ListHostSecurity(ctx context.Context, runID uuid.UUID) ([]HostSecurityRecord, error)
+	UpsertHostState(ctx context.Context, rec HostStateRecord) error
+	UpsertHostStates(ctx context.Context, recs []HostStateRecord) error
+	ListHostStates(ctx context.Context, runID uuid.UUID) ([]HostStateRecord, error)
+	HostPageCounts(ctx context.Context, runID uuid.UUID) ([]HostCounts, error)
+	InsertSkip(ctx context.Context, rec SkipRecord) error
+	InsertSkips(ctx context.Context, recs []SkipRecord) error
+	ListSkipped(ctx context.Context, runID uuid.UUID, reason string, limit int) ([]SkipRecord, error)
+	GetPage(ctx context.Context, runID uuid.UUID, canonical string) (*PageRow, error)
+	InsertURLEvent(ctx context.Context, rec URLEvent) error
+	InsertURLEvents(ctx context.Context, recs []URLEvent) error
+	ListURLEvents(ctx context.Context, runID uuid.UUID, canonical string, limit int) ([]URLEvent, error)
+	UpsertRunAnalytics(ctx context.Context, rec RunAnalyticsRecord) error
+	GetRunAnalytics(ctx context.Context, runID uuid.UUID) (*RunAnalyticsRecord, error)
+	CreateSchedule(ctx context.Context, rec ScheduleRecord) (uuid.UUID, error)
+	UpdateSchedule(ctx context.Context, rec ScheduleRecord) error
+	GetSchedule(ctx context.Context, id uuid.UUID) (*ScheduleRecord, error)
+	ListSchedules(ctx context.Context) ([]ScheduleRecord, error)
+	DeleteSchedule(ctx context.Context, id uuid.UUID) error
+	InsertRunEvent(ctx context.Context, rec RunEvent) error
+	ListRunEvents(ctx context.Context, runID uuid.UUID, limit int) ([]RunEvent, error)
 }
 
 type SQLStore struct {
 	db *sql.DB
+	// maxParams is the driver's limit on bind parameters per statement.
+	maxParams int
+	dialect   string
 }
 
 func NewSQL(db *sql.DB) *SQLStore {
-	return &SQLStore{db: db}
+	return &SQLStore{db: db, maxParams: 65535, dialect: DialectPostgres}
 }
 
 func (s *SQLStore) DB() *sql.DB {
@@ -35,96 +90,19 @@ func (s *SQLStore) DB() *sql.DB {
 }
 
 func (s *SQLStore) Migrate(ctx context.Context) error {
-	stmts := []string{
-		`CREATE TABLE IF NOT EXISTS runs (
-			id uuid PRIMARY KEY,
-			seed_url text NOT NULL,
-			status text NOT NULL,
-			created_at timestamptz NOT NULL,
-			started_at timestamptz,
-			stopped_at timestamptz,
-			stop_reason text,
-			max_depth int,
-			max_pages int,
-			time_budget_seconds int,
-			max_links_per_page int,
-			global_concurrency int,
-			per_host_concurrency int,
-			user_agent text,
-			respect_robots boolean
-		);`,
-		`CREATE INDEX IF NOT EXISTS runs_status_idx ON runs(status);`,
-		`ALTER TABLE runs ADD COLUMN IF NOT EXISTS stop_reason text;`,
-		`CREATE TABLE IF NOT EXISTS pages (
-			id bigserial PRIMARY KEY,
-			run_id uuid REFERENCES runs(id),
-			url text NOT NULL,
-			canonical_url text NOT NULL,
-			host text NOT NULL,
-			depth int NOT NULL,
-			status_code int,
-			content_type text,
-			fetch_ms int,
-			size_bytes bigint,
-			error_class text,
-			error_message text,
-			discovered_at timestamptz NOT NULL,
-			fetched_at timestamptz
-		);`,
-		`CREATE INDEX IF NOT EXISTS pages_run_id_idx ON pages(run_id);`,
-		`CREATE INDEX IF NOT EXISTS pages_host_idx ON pages(run_id, host);`,
-		`CREATE INDEX IF NOT EXISTS pages_canonical_idx ON pages(run_id, canonical_url);`,
-		`CREATE TABLE IF NOT EXISTS hosts (
-			run_id uuid REFERENCES runs(id),
-			host text NOT NULL,
-			robots_state text,
-			circuit_state text,
-			inflight int,
-			last_error_at timestamptz,
-			last_429_at timestamptz,
-			PRIMARY KEY (run_id, host)
-		);`,
-		`CREATE TABLE IF NOT EXISTS host_stats (
-			run_id uuid REFERENCES runs(id),
-			host text NOT NULL,
-			bucket_start timestamptz NOT NULL,
-			req_count int,
-			err_count int,
-			p50_ms int,
-			p95_ms int,
-			bytes bigint,
-			reuse_rate float,
-			PRIMARY KEY (run_id, host, bucket_start)
-		);`,
-		`CREATE TABLE IF NOT EXISTS edges (
-			run_id uuid REFERENCES runs(id),
-			src_host text NOT NULL,
-			dst_host text NOT NULL,
-			count int NOT NULL,
-			PRIMARY KEY (run_id, src_host, dst_host)
-		);`,
-		`CREATE TABLE IF NOT EXISTS errors (
-			id bigserial PRIMARY KEY,
-			run_id uuid REFERENCES runs(id),
-			host text,
-			url text,
-			class text NOT NULL,
-			message text,
-			at timestamptz NOT NULL
-		);`,
-		`CREATE INDEX IF NOT EXISTS errors_run_id_idx ON errors(run_id);`,
-		`CREATE INDEX IF NOT EXISTS errors_class_idx ON errors(run_id, class);`,
-	}
-	for _, stmt := range stmts {
-		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
-			return err
-		}
-	}
-	return nil
+	_, err := s.Migrator().Up(ctx)
+	return err
+}
+
+// Migrator returns a migrator over the embedded migrations for this store's
+// SQL dialect.
+func (s *SQLStore) Migrator() *Migrator {
+	return NewMigrator(s.db, s.dialect)
 }
 
 type RunConfig struct {
 	SeedURL            string
+	SeedHost           string
 	MaxDepth           int
 	MaxPages           int
 	TimeBudgetSeconds  int
@@ -133,14 +111,20 @@ type RunConfig struct {
 	PerHostConcurrency int
 	UserAgent          string
 	RespectRobots      bool
+	// Config is the complete run configuration as encoded by the crawler,
+	// tagged with ConfigVersion. The columns above stay for queries.
+	Config        []byte
+	ConfigVersion int
+	// ScheduleID is set for runs started by a schedule.
+	ScheduleID uuid.UUID
 }
 
 func (s *SQLStore) CreateRun(ctx context.Context, cfg RunConfig) (uuid.UUID, error) {
 	id := uuid.New()
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO runs (id, seed_url, status, created_at, max_depth, max_pages, time_budget_seconds, max_links_per_page, global_concurrency, per_host_concurrency, user_agent, respect_robots)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
-		id, cfg.SeedURL, "created", time.Now(), cfg.MaxDepth, cfg.MaxPages, cfg.TimeBudgetSeconds, cfg.MaxLinksPerPage, cfg.GlobalConcurrency, cfg.PerHostConcurrency, cfg.UserAgent, cfg.RespectRobots,
+		`INSERT INTO runs (id, seed_url, seed_host, status, created_at, max_depth, max_pages, time_budget_seconds, max_links_per_page, global_concurrency, per_host_concurrency, user_agent, respect_robots, config, config_version, schedule_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`,
+		id, cfg.SeedURL, nullableString(cfg.SeedHost), "created", time.Now().UTC(), cfg.MaxDepth, cfg.MaxPages, cfg.TimeBudgetSeconds, cfg.MaxLinksPerPage, cfg.GlobalConcurrency, cfg.PerHostConcurrency, cfg.UserAgent, cfg.RespectRobots, cfg.Config, nullableInt(cfg.ConfigVersion), nullableUUID(cfg.ScheduleID),
 	)
 	return id, err
 }
@@ -150,9 +134,19 @@ func (s *SQLStore) UpdateRunStatus(ctx context.Context, id uuid.UUID, status str
 	return err
 }
 
+// UpdateRunConfig stores the configuration of a run that was changed while it
+// was running. Only the settings that can change live are written to their
+// columns; the seed, depth and identity columns keep their original values.
+func (s *SQLStore) UpdateRunConfig(ctx context.Context, id uuid.UUID, cfg RunConfig) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE runs SET max_pages=$1, time_budget_seconds=$2, global_concurrency=$3, per_host_concurrency=$4, config=$5, config_version=$6 WHERE id=$7`,
+		cfg.MaxPages, cfg.TimeBudgetSeconds, cfg.GlobalConcurrency, cfg.PerHostConcurrency, cfg.Config, nullableInt(cfg.ConfigVersion), id)
+	return err
+}
+
 type RunRow struct {
 	ID                 uuid.UUID
 	SeedURL            string
+	SeedHost           string
 	Status             string
 	CreatedAt          time.Time
 	StartedAt          sql.NullTime
@@ -166,34 +160,201 @@ type RunRow struct {
 	PerHostConcurrency int
 	UserAgent          string
 	RespectRobots      bool
+	Config             []byte
+	ConfigVersion      sql.NullInt64
+	ScheduleID         uuid.NullUUID
 }
 
-func (s *SQLStore) GetRun(ctx context.Context, id uuid.UUID) (RunRow, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, seed_url, status, created_at, started_at, stopped_at, stop_reason, max_depth, max_pages, time_budget_seconds, max_links_per_page, global_concurrency, per_host_concurrency, user_agent, respect_robots FROM runs WHERE id=$1`, id)
+const runColumns = `id, seed_url, COALESCE(seed_host, ''), status, created_at, started_at, stopped_at, stop_reason, max_depth, max_pages, time_budget_seconds, max_links_per_page, global_concurrency, per_host_concurrency, user_agent, respect_robots, config, config_version, schedule_id`
+
+func scanRun(row interface{ Scan(...any) error }) (RunRow, error) {
 	var rr RunRow
-	err := row.Scan(&rr.ID, &rr.SeedURL, &rr.Status, &rr.CreatedAt, &rr.StartedAt, &rr.StoppedAt, &rr.StopReason, &rr.MaxDepth, &rr.MaxPages, &rr.TimeBudgetSeconds, &rr.MaxLinksPerPage, &rr.GlobalConcurrency, &rr.PerHostConcurrency, &rr.UserAgent, &rr.RespectRobots)
+	err := row.Scan(&rr.ID, &rr.SeedURL, &rr.SeedHost, &rr.Status, &rr.CreatedAt, &rr.StartedAt, &rr.StoppedAt, &rr.StopReason, &rr.MaxDepth, &rr.MaxPages, &rr.TimeBudgetSeconds, &rr.MaxLinksPerPage, &rr.GlobalConcurrency, &rr.PerHostConcurrency, &rr.UserAgent, &rr.RespectRobots, &rr.Config, &rr.ConfigVersion, &rr.ScheduleID)
 	return rr, err
 }
 
+func (s *SQLStore) GetRun(ctx context.Context, id uuid.UUID) (RunRow, error) {
+	return scanRun(s.db.QueryRowContext(ctx, `SELECT `+runColumns+` FROM runs WHERE id=$1`, id))
+}
+
+const (
+	RunSortCreated = "created_at"
+	RunSortSeedURL = "seed_url"
+	RunSortStatus  = "status"
+)
+
+type RunQuery struct {
+	Statuses      []string
+	SeedHost      string
+	StopReason    string
+	ScheduleID    uuid.UUID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+	Desc          bool
+	// After continues from the last row of the previous page.
+	After *RunCursor
+	Limit int
+}
+
+// RunCursor is the sort key and id of a run; Key is only used when sorting
+// by a text column.
+type RunCursor struct {
+	CreatedAt time.Time
+	Key       string
+	ID        uuid.UUID
+}
+
+func RunCursorFor(row RunRow, sort string) RunCursor {
+	cur := RunCursor{CreatedAt: row.CreatedAt, ID: row.ID}
+	switch sort {
+	case RunSortSeedURL:
+		cur.Key = row.SeedURL
+	case RunSortStatus:
+		cur.Key = row.Status
+	}
+	return cur
+}
+
+func (s *SQLStore) ListRuns(ctx context.Context, q RunQuery) ([]RunRow, error) {
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if len(q.Statuses) > 0 {
+		ph := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			ph[i] = arg(status)
+		}
+		where = append(where, "status IN ("+strings.Join(ph, ",")+")")
+	}
+	if q.SeedHost != "" {
+		where = append(where, "seed_host="+arg(q.SeedHost))
+	}
+	if q.StopReason != "" {
+		where = append(where, "stop_reason="+arg(q.StopReason))
+	}
+	if q.ScheduleID != uuid.Nil {
+		where = append(where, "schedule_id="+arg(q.ScheduleID))
+	}
+	if q.CreatedAfter != nil {
+		where = append(where, "created_at>="+arg(q.CreatedAfter.UTC()))
+	}
+	if q.CreatedBefore != nil {
+		where = append(where, "created_at<"+arg(q.CreatedBefore.UTC()))
+	}
+	col := RunSortCreated
+	switch q.Sort {
+	case RunSortSeedURL, RunSortStatus:
+		col = q.Sort
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		var key any = q.After.CreatedAt.UTC()
+		if col != RunSortCreated {
+			key = q.After.Key
+		}
+		where = append(where, "("+col+", id) "+cmp+" ("+arg(key)+", "+arg(q.After.ID)+")")
+	}
+	query := `SELECT ` + runColumns + ` FROM runs`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query += ` ORDER BY ` + col + ` ` + dir + `, id ` + dir + ` LIMIT ` + arg(limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RunRow
+	for rows.Next() {
+		rr, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rr)
+	}
+	return out, rows.Err()
+}
+
+// RunSummaries returns the page counts of several runs in one query. Skipped
+// counts are left nil; GetRunSummary has them for a single run.
+func (s *SQLStore) RunSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]RunSummary, error) {
+	out := make(map[uuid.UUID]RunSummary, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	ph := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		ph[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT run_id,
+		COUNT(*) FILTER (WHERE error_class IS NULL),
+		COUNT(*) FILTER (WHERE error_class IS NOT NULL),
+		COUNT(DISTINCT host),
+		COALESCE(SUM(size_bytes), 0),
+		MAX(fetched_at)
+		FROM pages WHERE run_id IN (`+strings.Join(ph, ",")+`) GROUP BY run_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var summary RunSummary
+		var lastFetched textTime
+		if err := rows.Scan(&id, &summary.PagesFetched, &summary.PagesFailed, &summary.UniqueHosts, &summary.TotalBytes, &lastFetched); err != nil {
+			return nil, err
+		}
+		if lastFetched.Valid {
+			summary.LastFetchedAt = &lastFetched.Time
+		}
+		out[id] = summary
+	}
+	return out, rows.Err()
+}
+
 type RunSummary struct {
 	PagesFetched  int64
 	PagesFailed   int64
 	UniqueHosts   int64
 	TotalBytes    int64
 	LastFetchedAt *time.Time
+	Skipped       map[string]int64
 }
 
 type PageRow struct {
-	URL          string
-	Host         string
-	Depth        int
-	StatusCode   int
-	ContentType  string
-	FetchMS      int64
-	SizeBytes    int64
-	ErrorClass   string
-	ErrorMessage string
-	FetchedAt    *time.Time
+	ID            int64
+	URL           string
+	CanonicalURL  string
+	Host          string
+	Depth         int
+	StatusCode    int
+	ContentType   string
+	FetchMS       int64
+	SizeBytes     int64
+	ErrorClass    string
+	ErrorMessage  string
+	Referrer      string
+	RedirectURL   string
+	OriginURL     string
+	RedirectChain []RedirectHop
+	Timing        FetchTiming
+	Title         string
+	ContentHash   string
+	DiscoveredAt  time.Time
+	FetchedAt     *time.Time
 }
 
 func (s *SQLStore) GetRunSummary(ctx context.Context, id uuid.UUID) (RunSummary, error) {
@@ -205,89 +366,409 @@ func (s *SQLStore) GetRunSummary(ctx context.Context, id uuid.UUID) (RunSummary,
 		MAX(fetched_at) AS last_fetched_at
 		FROM pages WHERE run_id=$1`, id)
 	var summary RunSummary
-	var lastFetched sql.NullTime
+	var lastFetched textTime
 	if err := row.Scan(&summary.PagesFetched, &summary.PagesFailed, &summary.UniqueHosts, &summary.TotalBytes, &lastFetched); err != nil {
 		return RunSummary{}, err
 	}
 	if lastFetched.Valid {
 		summary.LastFetchedAt = &lastFetched.Time
 	}
-	return summary, nil
+	rows, err := s.db.QueryContext(ctx, `SELECT reason, COUNT(*) FROM skipped WHERE run_id=$1 GROUP BY reason`, id)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	defer rows.Close()
+	summary.Skipped = map[string]int64{}
+	for rows.Next() {
+		var reason string
+		var count int64
+		if err := rows.Scan(&reason, &count); err != nil {
+			return RunSummary{}, err
+		}
+		summary.Skipped[reason] = count
+	}
+	return summary, rows.Err()
 }
 
+const pageColumns = `id, url, canonical_url, host, depth, status_code, content_type, fetch_ms, size_bytes, error_class, error_message, referrer_url, redirect_url, origin_url, redirect_chain, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, title, content_hash, discovered_at, fetched_at`
+
+// ListPages returns the most recently fetched pages first.
 func (s *SQLStore) ListPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error) {
+	return s.QueryPages(ctx, id, PageQuery{Sort: PageSortFetched, Desc: true, Limit: limit})
+}
+
+const (
+	PageSortFetched     = "fetched_at"
+	PageSortURL         = "url"
+	PageSortHost        = "host"
+	PageSortStatus      = "status_code"
+	PageSortDepth       = "depth"
+	PageSortSize        = "size_bytes"
+	PageSortFetchMS     = "fetch_ms"
+	PageSortContentType = "content_type"
+)
+
+// pageSortExprs must match the expressions of the pages_*_sort_idx indexes.
+// Nulls are folded into a value so keyset comparisons stay total. Pages that
+// were never fetched sort as the zero time, the same value PageCursorFor
+// gives them; the literal differs per dialect (see pageSortExpr). URLs sort
+// in byte order (see byteOrder).
+var pageSortExprs = map[string]string{
+	PageSortFetched:     "COALESCE(fetched_at, TIMESTAMPTZ '0001-01-01 00:00:00+00')",
+	PageSortURL:         `canonical_url COLLATE "C"`,
+	PageSortHost:        "host",
+	PageSortStatus:      "COALESCE(status_code, 0)",
+	PageSortDepth:       "depth",
+	PageSortSize:        "COALESCE(size_bytes, 0)",
+	PageSortFetchMS:     "COALESCE(fetch_ms, 0)",
+	PageSortContentType: "COALESCE(content_type, '')",
+}
+
+func ValidPageSort(sort string) bool {
+	_, ok := pageSortExprs[sort]
+	return ok
+}
+
+// Error class filters beyond an exact class.
+const (
+	ErrorClassAny  = "any"
+	ErrorClassNone = "none"
+)
+
+// PageQuery filters the pages of one run. Zero values and nil bounds don't
+// filter. URL filters match the canonical URL, case-sensitively.
+type PageQuery struct {
+	Host        string
+	StatusMin   int
+	StatusMax   int
+	ErrorClass  string
+	ContentType string // prefix, so "text/html" matches a charset suffix
+	DepthMin    *int
+	DepthMax    *int
+	SizeMin     *int64
+	SizeMax     *int64
+	FetchMSMin  *int64
+	FetchMSMax  *int64
+	URLContains string
+	URLPrefix   string
+	Sort        string
+	Desc        bool
+	After       *PageCursor
+	Limit       int
+}
+
+// PageCursor is the sort key and id of a page. Only the field for the
+// query's sort is set: Time for fetched_at, Num for numeric sorts, Text for
+// the rest.
+type PageCursor struct {
+	Time time.Time
+	Num  int64
+	Text string
+	ID   int64
+}
+
+func PageCursorFor(row PageRow, sort string) PageCursor {
+	cur := PageCursor{ID: row.ID}
+	switch sort {
+	case PageSortURL:
+		cur.Text = row.CanonicalURL
+	case PageSortHost:
+		cur.Text = row.Host
+	case PageSortContentType:
+		cur.Text = row.ContentType
+	case PageSortStatus:
+		cur.Num = int64(row.StatusCode)
+	case PageSortDepth:
+		cur.Num = int64(row.Depth)
+	case PageSortSize:
+		cur.Num = row.SizeBytes
+	case PageSortFetchMS:
+		cur.Num = row.FetchMS
+	default:
+		if row.FetchedAt != nil {
+			cur.Time = *row.FetchedAt
+		}
+	}
+	return cur
+}
+
+func (s *SQLStore) pageSortExpr(sort string) string {
+	if sort == PageSortFetched && s.dialect == DialectSQLite {
+		return "COALESCE(fetched_at, '0001-01-01 00:00:00+00:00')"
+	}
+	if sort == PageSortURL && s.dialect == DialectSQLite {
+		return "canonical_url"
+	}
+	return pageSortExprs[sort]
+}
+
+// byteOrder compares col in byte order, as SQLite and the memory store
+// always do, rather than by the Postgres database's locale. URL keysets use
+// it so that pages and links of two runs can be merge-joined in Go.
+func (s *SQLStore) byteOrder(col string) string {
+	if s.dialect == DialectPostgres {
+		return col + ` COLLATE "C"`
+	}
+	return col
+}
+
+func (s *SQLStore) QueryPages(ctx context.Context, runID uuid.UUID, q PageQuery) ([]PageRow, error) {
+	args := []any{runID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := []string{"run_id=$1"}
+	if q.Host != "" {
+		where = append(where, "host="+arg(q.Host))
+	}
+	if q.StatusMin > 0 {
+		where = append(where, "status_code>="+arg(q.StatusMin))
+	}
+	if q.StatusMax > 0 {
+		where = append(where, "status_code<="+arg(q.StatusMax))
+	}
+	switch q.ErrorClass {
+	case "":
+	case ErrorClassAny:
+		where = append(where, "error_class IS NOT NULL")
+	case ErrorClassNone:
+		where = append(where, "error_class IS NULL")
+	default:
+		where = append(where, "error_class="+arg(q.ErrorClass))
+	}
+	if q.ContentType != "" {
+		where = append(where, s.prefixMatch("content_type", q.ContentType, arg))
+	}
+	for _, bound := range []struct {
+		col, op string
+		v       *int64
+	}{
+		{"size_bytes", ">=", q.SizeMin}, {"size_bytes", "<=", q.SizeMax},
+		{"fetch_ms", ">=", q.FetchMSMin}, {"fetch_ms", "<=", q.FetchMSMax},
+	} {
+		if bound.v != nil {
+			where = append(where, bound.col+bound.op+arg(*bound.v))
+		}
+	}
+	if q.DepthMin != nil {
+		where = append(where, "depth>="+arg(*q.DepthMin))
+	}
+	if q.DepthMax != nil {
+		where = append(where, "depth<="+arg(*q.DepthMax))
+	}
+	if q.URLPrefix != "" {
+		where = append(where, s.prefixMatch("canonical_url", q.URLPrefix, arg))
+	}
+	if q.URLContains != "" {
+		fn := "strpos"
+		if s.dialect == DialectSQLite {
+			fn = "instr"
+		}
+		where = append(where, fn+"(canonical_url, "+arg(q.URLContains)+") > 0")
+	}
+	sortKey := q.Sort
+	if !ValidPageSort(sortKey) {
+		sortKey = PageSortFetched
+	}
+	expr := s.pageSortExpr(sortKey)
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		var key any
+		switch sortKey {
+		case PageSortFetched:
+			key = q.After.Time
+		case PageSortURL, PageSortHost, PageSortContentType:
+			key = q.After.Text
+		default:
+			key = q.After.Num
+		}
+		where = append(where, "("+expr+", id) "+cmp+" ("+arg(key)+", "+arg(q.After.ID)+")")
+	}
+	limit := q.Limit
 	if limit <= 0 {
 		limit = 50
 	}
-	rows, err := s.db.QueryContext(ctx, `SELECT url, host, depth, status_code, content_type, fetch_ms, size_bytes, error_class, error_message, fetched_at
-		FROM pages WHERE run_id=$1
-		ORDER BY fetched_at DESC NULLS LAST, discovered_at DESC
+	return s.queryPages(ctx, `SELECT `+pageColumns+` FROM pages WHERE `+strings.Join(where, " AND ")+
+		` ORDER BY `+expr+` `+dir+`, id `+dir+` LIMIT `+arg(limit), args...)
+}
+
+// prefixMatch builds an index-friendly prefix test: LIKE on Postgres (with
+// a text_pattern_ops index) and GLOB on SQLite, whose LIKE ignores case.
+func (s *SQLStore) prefixMatch(col, prefix string, arg func(any) string) string {
+	if s.dialect == DialectSQLite {
+		escaped := strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(prefix)
+		return col + " GLOB " + arg(escaped+"*")
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+	return col + " LIKE " + arg(escaped+"%")
+}
+
+func (s *SQLStore) ListFailedPages(ctx context.Context, id uuid.UUID, limit int) ([]PageRow, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	return s.queryPages(ctx, `SELECT `+pageColumns+`
+		FROM pages WHERE run_id=$1 AND (status_code >= 400 OR error_class IS NOT NULL)
+		ORDER BY canonical_url
 		LIMIT $2`, id, limit)
+}
+
+// GetPage returns the page stored under a canonical URL, or nil when the
+// URL was never fetched.
+func (s *SQLStore) GetPage(ctx context.Context, runID uuid.UUID, canonical string) (*PageRow, error) {
+	rows, err := s.queryPages(ctx, `SELECT `+pageColumns+`
+		FROM pages WHERE run_id=$1 AND canonical_url=$2
+		ORDER BY fetched_at DESC NULLS LAST
+		LIMIT 1`, runID, canonical)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+func (s *SQLStore) queryPages(ctx context.Context, query string, args ...any) ([]PageRow, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var out []PageRow
 	for rows.Next() {
-		var row PageRow
-		var status sql.NullInt32
-		var ct sql.NullString
-		var fetchMS sql.NullInt32
-		var size sql.NullInt64
-		var errClass sql.NullString
-		var errMsg sql.NullString
-		var fetched sql.NullTime
-		if err := rows.Scan(&row.URL, &row.Host, &row.Depth, &status, &ct, &fetchMS, &size, &errClass, &errMsg, &fetched); err != nil {
+		row, err := scanPageRow(rows)
+		if err != nil {
 			return nil, err
 		}
-		if status.Valid {
-			row.StatusCode = int(status.Int32)
-		}
-		if ct.Valid {
-			row.ContentType = ct.String
-		}
-		if fetchMS.Valid {
-			row.FetchMS = int64(fetchMS.Int32)
-		}
-		if size.Valid {
-			row.SizeBytes = size.Int64
-		}
-		if errClass.Valid {
-			row.ErrorClass = errClass.String
-		}
-		if errMsg.Valid {
-			row.ErrorMessage = errMsg.String
-		}
-		if fetched.Valid {
-			row.FetchedAt = &fetched.Time
-		}
 		out = append(out, row)
 	}
 	return out, rows.Err()
 }
 
+func scanPageRow(rows *sql.Rows) (PageRow, error) {
+	var row PageRow
+	var status sql.NullInt32
+	var ct sql.NullString
+	var fetchMS sql.NullInt32
+	var size sql.NullInt64
+	var errClass sql.NullString
+	var errMsg sql.NullString
+	var referrer sql.NullString
+	var redirectURL, originURL sql.NullString
+	var chain []byte
+	var dns, connect, tlsMS, ttfb, transfer sql.NullInt64
+	var title, contentHash sql.NullString
+	var fetched sql.NullTime
+	if err := rows.Scan(&row.ID, &row.URL, &row.CanonicalURL, &row.Host, &row.Depth, &status, &ct, &fetchMS, &size, &errClass, &errMsg, &referrer, &redirectURL, &originURL, &chain, &dns, &connect, &tlsMS, &ttfb, &transfer, &title, &contentHash, &row.DiscoveredAt, &fetched); err != nil {
+		return PageRow{}, err
+	}
+	row.Title = title.String
+	row.ContentHash = contentHash.String
+	row.Timing = FetchTiming{DNSMS: dns.Int64, ConnectMS: connect.Int64, TLSMS: tlsMS.Int64, TTFBMS: ttfb.Int64, TransferMS: transfer.Int64}
+	row.RedirectURL = redirectURL.String
+	row.OriginURL = originURL.String
+	if len(chain) > 0 {
+		if err := json.Unmarshal(chain, &row.RedirectChain); err != nil {
+			return PageRow{}, err
+		}
+	}
+	if status.Valid {
+		row.StatusCode = int(status.Int32)
+	}
+	if ct.Valid {
+		row.ContentType = ct.String
+	}
+	if fetchMS.Valid {
+		row.FetchMS = int64(fetchMS.Int32)
+	}
+	if size.Valid {
+		row.SizeBytes = size.Int64
+	}
+	if errClass.Valid {
+		row.ErrorClass = errClass.String
+	}
+	if errMsg.Valid {
+		row.ErrorMessage = errMsg.String
+	}
+	if referrer.Valid {
+		row.Referrer = referrer.String
+	}
+	if fetched.Valid {
+		row.FetchedAt = &fetched.Time
+	}
+	return row, nil
+}
+
 type PageRecord struct {
-	RunID        uuid.UUID
-	URL          string
-	CanonicalURL string
-	Host         string
-	Depth        int
-	StatusCode   int
-	ContentType  string
-	FetchMS      int64
-	SizeBytes    int64
-	ErrClass     string
-	ErrMessage   string
+	RunID         uuid.UUID
+	URL           string
+	CanonicalURL  string
+	Host          string
+	Depth         int
+	StatusCode    int
+	ContentType   string
+	FetchMS       int64
+	SizeBytes     int64
+	ErrClass      string
+	ErrMessage    string
+	Referrer      string
+	RedirectURL   string
+	OriginURL     string
+	RedirectChain []RedirectHop
+	Timing        FetchTiming
+	// ContentHash is the hex SHA-256 of a 2xx response body and Title the
+	// <title> of an HTML page; both are empty otherwise.
+	Title        string
+	ContentHash  string
 	DiscoveredAt time.Time
 	FetchedAt    *time.Time
 }
 
+// FetchTiming holds per-phase durations in milliseconds. Zero means the
+// phase was skipped (for example DNS on a reused connection).
+type FetchTiming struct {
+	DNSMS      int64 `json:"dns_ms"`
+	ConnectMS  int64 `json:"connect_ms"`
+	TLSMS      int64 `json:"tls_ms"`
+	TTFBMS     int64 `json:"ttfb_ms"`
+	TransferMS int64 `json:"transfer_ms"`
+}
+
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+}
+
 func (s *SQLStore) InsertPage(ctx context.Context, rec PageRecord) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO pages (run_id, url, canonical_url, host, depth, status_code, content_type, fetch_ms, size_bytes, error_class, error_message, discovered_at, fetched_at)
-	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
-		rec.RunID, rec.URL, rec.CanonicalURL, rec.Host, rec.Depth, nullableInt(rec.StatusCode), nullableString(rec.ContentType), nullableInt(int(rec.FetchMS)), nullableInt64(rec.SizeBytes), nullableString(rec.ErrClass), nullableString(rec.ErrMessage), rec.DiscoveredAt, rec.FetchedAt,
-	)
-	return err
+	return s.InsertPages(ctx, []PageRecord{rec})
+}
+
+func (s *SQLStore) InsertPages(ctx context.Context, recs []PageRecord) error {
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		chain, err := nullableJSON(rec.RedirectChain, len(rec.RedirectChain) == 0)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{
+			rec.RunID, rec.URL, rec.CanonicalURL, rec.Host, rec.Depth, nullableInt(rec.StatusCode), nullableString(rec.ContentType), nullableInt(int(rec.FetchMS)), nullableInt64(rec.SizeBytes), nullableString(rec.ErrClass), nullableString(rec.ErrMessage), nullableString(rec.Referrer), nullableString(rec.RedirectURL), nullableString(rec.OriginURL), chain,
+			nullableInt64(rec.Timing.DNSMS), nullableInt64(rec.Timing.ConnectMS), nullableInt64(rec.Timing.TLSMS), nullableInt64(rec.Timing.TTFBMS), nullableInt64(rec.Timing.TransferMS),
+			nullableString(rec.Title), nullableString(rec.ContentHash), rec.DiscoveredAt, rec.FetchedAt,
+		})
+	}
+	return s.insertRows(ctx, `INSERT INTO pages (run_id, url, canonical_url, host, depth, status_code, content_type, fetch_ms, size_bytes, error_class, error_message, referrer_url, redirect_url, origin_url, redirect_chain, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, title, content_hash, discovered_at, fetched_at) VALUES `, ``, rows)
+}
+
+type ErrorRecord struct {
+	ID      int64     `json:"id"`
+	RunID   uuid.UUID `json:"-"`
+	Host    string    `json:"host"`
+	URL     string    `json:"url"`
+	Class   string    `json:"class"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
 }
 
 func (s *SQLStore) InsertError(ctx context.Context, runID uuid.UUID, host, url, class, message string) error {
@@ -295,39 +776,1142 @@ func (s *SQLStore) InsertError(ctx context.Context, runID uuid.UUID, host, url,
 	return err
 }
 
-func (s *SQLStore) UpsertEdge(ctx context.Context, runID uuid.UUID, src, dst string, count int) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO edges (run_id, src_host, dst_host, count) VALUES ($1,$2,$3,$4)
-	ON CONFLICT (run_id, src_host, dst_host) DO UPDATE SET count = edges.count + EXCLUDED.count`, runID, src, dst, count)
-	return err
+func (s *SQLStore) InsertErrors(ctx context.Context, recs []ErrorRecord) error {
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, nullableString(rec.Host), nullableString(rec.URL), rec.Class, nullableString(rec.Message), rec.At})
+	}
+	return s.insertRows(ctx, `INSERT INTO errors (run_id, host, url, class, message, at) VALUES `, ``, rows)
 }
 
-func (s *SQLStore) UpsertHostStat(ctx context.Context, runID uuid.UUID, host string, bucket time.Time, req, errCount, p50, p95 int, bytes int64, reuse float64) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO host_stats (run_id, host, bucket_start, req_count, err_count, p50_ms, p95_ms, bytes, reuse_rate)
-	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
-	ON CONFLICT (run_id, host, bucket_start) DO UPDATE SET req_count=$4, err_count=$5, p50_ms=$6, p95_ms=$7, bytes=$8, reuse_rate=$9`,
-		runID, host, bucket, req, errCount, p50, p95, bytes, reuse)
-	return err
+type ErrorQuery struct {
+	Class   string
+	Host    string
+	AfterID int64
+	Limit   int
 }
 
-func nullableString(s string) sql.NullString {
-	if s == "" {
-		return sql.NullString{}
+// QueryErrors returns individual errors in insertion order, starting after
+// AfterID.
+func (s *SQLStore) QueryErrors(ctx context.Context, runID uuid.UUID, q ErrorQuery) ([]ErrorRecord, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
 	}
-	return sql.NullString{String: s, Valid: true}
+	query := `SELECT id, COALESCE(host, ''), COALESCE(url, ''), class, COALESCE(message, ''), at FROM errors WHERE run_id=$1 AND id>$2`
+	args := []any{runID, q.AfterID}
+	if q.Class != "" {
+		args = append(args, q.Class)
+		query += ` AND class=$` + strconv.Itoa(len(args))
+	}
+	if q.Host != "" {
+		args = append(args, q.Host)
+		query += ` AND host=$` + strconv.Itoa(len(args))
+	}
+	args = append(args, q.Limit)
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY id LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ErrorRecord
+	for rows.Next() {
+		rec := ErrorRecord{RunID: runID}
+		if err := rows.Scan(&rec.ID, &rec.Host, &rec.URL, &rec.Class, &rec.Message, &rec.At); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
 }
 
-func nullableStringPtr(s *string) sql.NullString {
-	if s == nil || *s == "" {
-		return sql.NullString{}
+// ErrorGroup aggregates a run's errors of one class on one host. SampleURLs
+// holds the most recently failing distinct URLs.
+type ErrorGroup struct {
+	Class      string    `json:"class"`
+	Host       string    `json:"host"`
+	Count      int64     `json:"count"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+	SampleURLs []string  `json:"sample_urls"`
+}
+
+type ErrorGroupQuery struct {
+	Class   string
+	Host    string
+	Samples int
+	Limit   int
+}
+
+// ListErrorGroups returns the largest groups first.
+func (s *SQLStore) ListErrorGroups(ctx context.Context, runID uuid.UUID, q ErrorGroupQuery) ([]ErrorGroup, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
 	}
-	return sql.NullString{String: *s, Valid: true}
+	where := ` WHERE run_id=$1`
+	args := []any{runID}
+	if q.Class != "" {
+		args = append(args, q.Class)
+		where += ` AND class=$` + strconv.Itoa(len(args))
+	}
+	if q.Host != "" {
+		args = append(args, q.Host)
+		where += ` AND host=$` + strconv.Itoa(len(args))
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT class, COALESCE(host, ''), COUNT(*), MIN(at), MAX(at) FROM errors`+where+`
+		GROUP BY class, COALESCE(host, '') ORDER BY COUNT(*) DESC, class, 2 LIMIT $`+strconv.Itoa(len(args)+1), append(args, q.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ErrorGroup
+	index := map[[2]string]int{}
+	for rows.Next() {
+		var g ErrorGroup
+		var first, last textTime
+		if err := rows.Scan(&g.Class, &g.Host, &g.Count, &first, &last); err != nil {
+			return nil, err
+		}
+		g.FirstAt, g.LastAt = first.Time, last.Time
+		g.SampleURLs = []string{}
+		index[[2]string{g.Class, g.Host}] = len(out)
+		out = append(out, g)
+	}
+	if err := rows.Err(); err != nil || q.Samples <= 0 || len(out) == 0 {
+		return out, err
+	}
+	samples, err := s.db.QueryContext(ctx, `SELECT class, host, url FROM (
+		SELECT class, COALESCE(host, '') AS host, url,
+			ROW_NUMBER() OVER (PARTITION BY class, COALESCE(host, '') ORDER BY MAX(at) DESC, url) AS rn
+		FROM errors`+where+` AND url IS NOT NULL
+		GROUP BY class, COALESCE(host, ''), url
+	) ranked WHERE rn <= $`+strconv.Itoa(len(args)+1)+` ORDER BY class, host, rn`, append(args, q.Samples)...)
+	if err != nil {
+		return nil, err
+	}
+	defer samples.Close()
+	for samples.Next() {
+		var class, host, url string
+		if err := samples.Scan(&class, &host, &url); err != nil {
+			return nil, err
+		}
+		if i, ok := index[[2]string{class, host}]; ok {
+			out[i].SampleURLs = append(out[i].SampleURLs, url)
+		}
+	}
+	return out, samples.Err()
 }
 
-func nullableInt(n int) sql.NullInt32 {
-	if n == 0 {
-		return sql.NullInt32{}
+type EdgeRecord struct {
+	RunID uuid.UUID `json:"-"`
+	Src   string    `json:"src"`
+	Dst   string    `json:"dst"`
+	Count int       `json:"count"`
+}
+
+func (s *SQLStore) UpsertEdge(ctx context.Context, runID uuid.UUID, src, dst string, count int) error {
+	return s.UpsertEdges(ctx, []EdgeRecord{{RunID: runID, Src: src, Dst: dst, Count: count}})
+}
+
+// UpsertEdges adds counts to existing edges. A (src, dst) pair may appear
+// only once per call; Postgres rejects a statement that updates the same row
+// twice.
+func (s *SQLStore) UpsertEdges(ctx context.Context, recs []EdgeRecord) error {
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, rec.Src, rec.Dst, rec.Count})
 	}
-	return sql.NullInt32{Int32: int32(n), Valid: true}
+	return s.insertRows(ctx, `INSERT INTO edges (run_id, src_host, dst_host, count) VALUES `,
+		` ON CONFLICT (run_id, src_host, dst_host) DO UPDATE SET count = edges.count + EXCLUDED.count`, rows)
+}
+
+type EdgeQuery struct {
+	// Host keeps edges that start or end there.
+	Host  string
+	After *EdgeCursor
+	Limit int
+}
+
+// EdgeCursor is the sort key of the last edge of a page.
+type EdgeCursor struct {
+	Count int
+	Src   string
+	Dst   string
+}
+
+// ListEdges returns the heaviest host-to-host edges first.
+func (s *SQLStore) ListEdges(ctx context.Context, runID uuid.UUID, q EdgeQuery) ([]EdgeRecord, error) {
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	query := `SELECT src_host, dst_host, count FROM edges WHERE run_id=$1`
+	args := []any{runID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if q.Host != "" {
+		p := arg(q.Host)
+		query += ` AND (src_host=` + p + ` OR dst_host=` + p + `)`
+	}
+	if q.After != nil {
+		c, src, dst := arg(q.After.Count), arg(q.After.Src), arg(q.After.Dst)
+		query += ` AND (count<` + c + ` OR (count=` + c + ` AND (src_host>` + src + ` OR (src_host=` + src + ` AND dst_host>` + dst + `))))`
+	}
+	query += ` ORDER BY count DESC, src_host, dst_host LIMIT ` + arg(q.Limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []EdgeRecord
+	for rows.Next() {
+		rec := EdgeRecord{RunID: runID}
+		if err := rows.Scan(&rec.Src, &rec.Dst, &rec.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// insertRows runs prefix + VALUES (...),(...) + suffix as multi-row inserts,
+// splitting rows so no statement exceeds the parameter limit. Chunks share a
+// transaction so a batch is stored entirely or not at all.
+func (s *SQLStore) insertRows(ctx context.Context, prefix, suffix string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	width := len(rows[0])
+	perStmt := s.maxParams / width
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for start := 0; start < len(rows); start += perStmt {
+		chunk := rows[start:min(start+perStmt, len(rows))]
+		var b strings.Builder
+		b.WriteString(prefix)
+		args := make([]any, 0, len(chunk)*width)
+		for i, row := range chunk {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('(')
+			for j := range row {
+				if j > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteByte('$')
+				b.WriteString(strconv.Itoa(len(args) + j + 1))
+			}
+			b.WriteByte(')')
+			args = append(args, row...)
+		}
+		b.WriteString(suffix)
+		if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// lastByKey keeps the last record for each key, in the order keys first
+// appear. Multi-row upserts need it because Postgres rejects a statement that
+// updates the same row twice.
+func lastByKey[T any, K comparable](recs []T, key func(T) K) []T {
+	index := make(map[K]int, len(recs))
+	out := make([]T, 0, len(recs))
+	for _, rec := range recs {
+		k := key(rec)
+		if i, ok := index[k]; ok {
+			out[i] = rec
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, rec)
+	}
+	return out
+}
+
+type LinkRecord struct {
+	RunID      uuid.UUID
+	SrcURL     string
+	DstURL     string
+	AnchorText string
+	Rel        string
+	Element    string
+	Followed   bool
+}
+
+type LinkRow struct {
+	SrcURL     string `json:"src_url"`
+	DstURL     string `json:"dst_url"`
+	AnchorText string `json:"anchor_text"`
+	Rel        string `json:"rel"`
+	Element    string `json:"element"`
+	Followed   bool   `json:"followed"`
+}
+
+func (s *SQLStore) InsertLinks(ctx context.Context, links []LinkRecord) error {
+	rows := make([][]any, 0, len(links))
+	for _, l := range links {
+		rows = append(rows, []any{l.RunID, l.SrcURL, l.DstURL, nullableString(l.AnchorText), nullableString(l.Rel), l.Element, l.Followed})
+	}
+	return s.insertRows(ctx, `INSERT INTO links (run_id, src_url, dst_url, anchor_text, rel, element, followed) VALUES `, ``, rows)
+}
+
+func (s *SQLStore) ListInlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error) {
+	return s.listLinks(ctx, `SELECT src_url, dst_url, anchor_text, rel, element, followed FROM links WHERE run_id=$1 AND dst_url=$2 ORDER BY id LIMIT $3`, runID, url, limit)
+}
+
+// inlinkBatch caps the URLs bound into one ListInlinksTo query, well under
+// the parameter limits of both databases.
+const inlinkBatch = 500
+
+// ListInlinksTo returns the links pointing at any of urls, at most perURL
+// for each target, in the order they were recorded.
+func (s *SQLStore) ListInlinksTo(ctx context.Context, runID uuid.UUID, urls []string, perURL int) ([]LinkRow, error) {
+	if perURL <= 0 {
+		perURL = 100
+	}
+	var out []LinkRow
+	for start := 0; start < len(urls); start += inlinkBatch {
+		batch := urls[start:min(start+inlinkBatch, len(urls))]
+		ph := make([]string, len(batch))
+		args := []any{runID, perURL}
+		for i, u := range batch {
+			ph[i] = "$" + strconv.Itoa(i+3)
+			args = append(args, u)
+		}
+		rows, err := s.db.QueryContext(ctx, `SELECT src_url, dst_url, anchor_text, rel, element, followed FROM (
+			SELECT id, src_url, dst_url, anchor_text, rel, element, followed,
+				ROW_NUMBER() OVER (PARTITION BY dst_url ORDER BY id) AS n
+			FROM links WHERE run_id=$1 AND dst_url IN (`+strings.Join(ph, ",")+`)
+		) ranked WHERE n <= $2 ORDER BY id`, args...)
+		if err != nil {
+			return nil, err
+		}
+		links, err := scanLinks(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, links...)
+	}
+	return out, nil
+}
+
+func (s *SQLStore) ListOutlinks(ctx context.Context, runID uuid.UUID, url string, limit int) ([]LinkRow, error) {
+	return s.listLinks(ctx, `SELECT src_url, dst_url, anchor_text, rel, element, followed FROM links WHERE run_id=$1 AND src_url=$2 ORDER BY id LIMIT $3`, runID, url, limit)
+}
+
+// LinkEdgeQuery pages through the URL-to-URL link graph in (src, dst) order.
+type LinkEdgeQuery struct {
+	// MinCount drops pairs linked fewer times.
+	MinCount int
+	After    *LinkEdge
+	Limit    int
+}
+
+// LinkEdge is a pair of canonical URLs and how many links join them.
+type LinkEdge struct {
+	Src   string `json:"src"`
+	Dst   string `json:"dst"`
+	Count int    `json:"count"`
+}
+
+func (s *SQLStore) ListLinkEdges(ctx context.Context, runID uuid.UUID, q LinkEdgeQuery) ([]LinkEdge, error) {
+	if q.Limit <= 0 {
+		q.Limit = 1000
+	}
+	query := `SELECT src_url, dst_url, COUNT(*) FROM links WHERE run_id=$1`
+	args := []any{runID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	srcCol, dstCol := s.byteOrder("src_url"), s.byteOrder("dst_url")
+	if q.After != nil {
+		query += ` AND (` + srcCol + `, ` + dstCol + `) > (` + arg(q.After.Src) + `, ` + arg(q.After.Dst) + `)`
+	}
+	query += ` GROUP BY src_url, dst_url`
+	if q.MinCount > 1 {
+		query += ` HAVING COUNT(*)>=` + arg(q.MinCount)
+	}
+	query += ` ORDER BY ` + srcCol + `, ` + dstCol + ` LIMIT ` + arg(q.Limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LinkEdge
+	for rows.Next() {
+		var e LinkEdge
+		if err := rows.Scan(&e.Src, &e.Dst, &e.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) listLinks(ctx context.Context, query string, runID uuid.UUID, url string, limit int) ([]LinkRow, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, query, runID, url, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanLinks(rows)
+}
+
+func scanLinks(rows *sql.Rows) ([]LinkRow, error) {
+	defer rows.Close()
+	var out []LinkRow
+	for rows.Next() {
+		var row LinkRow
+		var anchor, rel sql.NullString
+		if err := rows.Scan(&row.SrcURL, &row.DstURL, &anchor, &rel, &row.Element, &row.Followed); err != nil {
+			return nil, err
+		}
+		row.AnchorText = anchor.String
+		row.Rel = rel.String
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+type LinkStatusRecord struct {
+	RunID        uuid.UUID
+	URL          string
+	CanonicalURL string
+	Host         string
+	StatusCode   int
+	Method       string
+	FinalURL     string
+	FetchMS      int64
+	ErrClass     string
+	ErrMessage   string
+	CheckedAt    time.Time
+}
+
+type LinkStatusRow struct {
+	URL          string    `json:"url"`
+	CanonicalURL string    `json:"canonical_url"`
+	Host         string    `json:"host"`
+	StatusCode   int       `json:"status_code"`
+	Method       string    `json:"method"`
+	FinalURL     string    `json:"final_url"`
+	FetchMS      int64     `json:"fetch_ms"`
+	ErrorClass   string    `json:"error_class"`
+	ErrorMessage string    `json:"error_message"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+func (s *SQLStore) UpsertLinkStatus(ctx context.Context, rec LinkStatusRecord) error {
+	return s.UpsertLinkStatuses(ctx, []LinkStatusRecord{rec})
+}
+
+// UpsertLinkStatuses stores check results; a URL checked twice keeps the
+// later result.
+func (s *SQLStore) UpsertLinkStatuses(ctx context.Context, recs []LinkStatusRecord) error {
+	recs = lastByKey(recs, func(rec LinkStatusRecord) [2]string { return [2]string{rec.RunID.String(), rec.CanonicalURL} })
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, rec.CanonicalURL, rec.URL, rec.Host, nullableInt(rec.StatusCode), nullableString(rec.Method), nullableString(rec.FinalURL), nullableInt(int(rec.FetchMS)), nullableString(rec.ErrClass), nullableString(rec.ErrMessage), rec.CheckedAt})
+	}
+	return s.insertRows(ctx, `INSERT INTO link_status (run_id, canonical_url, url, host, status_code, method, final_url, fetch_ms, error_class, error_message, checked_at) VALUES `,
+		` ON CONFLICT (run_id, canonical_url) DO UPDATE SET status_code=EXCLUDED.status_code, method=EXCLUDED.method, final_url=EXCLUDED.final_url, fetch_ms=EXCLUDED.fetch_ms,
+	error_class=EXCLUDED.error_class, error_message=EXCLUDED.error_message, checked_at=EXCLUDED.checked_at`, rows)
+}
+
+func (s *SQLStore) ListLinkStatuses(ctx context.Context, runID uuid.UUID, failedOnly bool, limit int) ([]LinkStatusRow, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT url, canonical_url, host, status_code, method, final_url, fetch_ms, error_class, error_message, checked_at
+		FROM link_status WHERE run_id=$1 AND (NOT $2 OR status_code >= 400 OR error_class IS NOT NULL)
+		ORDER BY canonical_url LIMIT $3`, runID, failedOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LinkStatusRow
+	for rows.Next() {
+		var row LinkStatusRow
+		var status, fetchMS sql.NullInt32
+		var method, finalURL, errClass, errMsg sql.NullString
+		if err := rows.Scan(&row.URL, &row.CanonicalURL, &row.Host, &status, &method, &finalURL, &fetchMS, &errClass, &errMsg, &row.CheckedAt); err != nil {
+			return nil, err
+		}
+		row.StatusCode = int(status.Int32)
+		row.Method = method.String
+		row.FinalURL = finalURL.String
+		row.FetchMS = int64(fetchMS.Int32)
+		row.ErrorClass = errClass.String
+		row.ErrorMessage = errMsg.String
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+type RedirectChainRecord struct {
+	RunID       uuid.UUID
+	OriginURL   string
+	FinalURL    string
+	FinalStatus int
+	Hops        []RedirectHop
+	Outcome     string
+}
+
+type RedirectChainRow struct {
+	OriginURL   string        `json:"origin_url"`
+	FinalURL    string        `json:"final_url"`
+	FinalStatus int           `json:"final_status"`
+	HopCount    int           `json:"hop_count"`
+	Hops        []RedirectHop `json:"hops"`
+	Outcome     string        `json:"outcome"`
+	At          time.Time     `json:"at"`
+}
+
+func (s *SQLStore) InsertRedirectChain(ctx context.Context, rec RedirectChainRecord) error {
+	return s.InsertRedirectChains(ctx, []RedirectChainRecord{rec})
+}
+
+func (s *SQLStore) InsertRedirectChains(ctx context.Context, recs []RedirectChainRecord) error {
+	now := time.Now()
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		hops, err := json.Marshal(rec.Hops)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{rec.RunID, rec.OriginURL, rec.FinalURL, nullableInt(rec.FinalStatus), len(rec.Hops), hops, rec.Outcome, now})
+	}
+	return s.insertRows(ctx, `INSERT INTO redirect_chains (run_id, origin_url, final_url, final_status, hop_count, hops, outcome, at) VALUES `, ``, rows)
+}
+
+func (s *SQLStore) ListRedirectChains(ctx context.Context, runID uuid.UUID, minHops, limit int) ([]RedirectChainRow, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT origin_url, final_url, final_status, hop_count, hops, outcome, at
+		FROM redirect_chains WHERE run_id=$1 AND hop_count >= $2
+		ORDER BY hop_count DESC, origin_url LIMIT $3`, runID, minHops, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RedirectChainRow
+	for rows.Next() {
+		var row RedirectChainRow
+		var status sql.NullInt32
+		var hops []byte
+		if err := rows.Scan(&row.OriginURL, &row.FinalURL, &status, &row.HopCount, &hops, &row.Outcome, &row.At); err != nil {
+			return nil, err
+		}
+		row.FinalStatus = int(status.Int32)
+		if err := json.Unmarshal(hops, &row.Hops); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+type Percentiles struct {
+	P50MS int `json:"p50_ms"`
+	P95MS int `json:"p95_ms"`
+}
+
+type HostStatRecord struct {
+	RunID       uuid.UUID   `json:"-"`
+	Host        string      `json:"host"`
+	BucketStart time.Time   `json:"bucket_start"`
+	Requests    int         `json:"req_count"`
+	Errors      int         `json:"err_count"`
+	Latency     Percentiles `json:"latency"`
+	Bytes       int64       `json:"bytes"`
+	ReuseRate   float64     `json:"reuse_rate"`
+	DNS         Percentiles `json:"dns"`
+	Connect     Percentiles `json:"connect"`
+	TLS         Percentiles `json:"tls"`
+	TTFB        Percentiles `json:"ttfb"`
+	Transfer    Percentiles `json:"transfer"`
+}
+
+func (s *SQLStore) UpsertHostStat(ctx context.Context, rec HostStatRecord) error {
+	return s.UpsertHostStats(ctx, []HostStatRecord{rec})
+}
+
+// UpsertHostStats replaces the stats of each (host, bucket); a bucket given
+// twice keeps the later record.
+func (s *SQLStore) UpsertHostStats(ctx context.Context, recs []HostStatRecord) error {
+	type key struct {
+		runID  uuid.UUID
+		host   string
+		bucket int64
+	}
+	recs = lastByKey(recs, func(rec HostStatRecord) key { return key{rec.RunID, rec.Host, rec.BucketStart.UnixNano()} })
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, rec.Host, rec.BucketStart.UTC(), rec.Requests, rec.Errors, rec.Latency.P50MS, rec.Latency.P95MS, rec.Bytes, rec.ReuseRate,
+			rec.DNS.P50MS, rec.DNS.P95MS, rec.Connect.P50MS, rec.Connect.P95MS, rec.TLS.P50MS, rec.TLS.P95MS, rec.TTFB.P50MS, rec.TTFB.P95MS, rec.Transfer.P50MS, rec.Transfer.P95MS})
+	}
+	return s.insertRows(ctx, `INSERT INTO host_stats (run_id, host, bucket_start, req_count, err_count, p50_ms, p95_ms, bytes, reuse_rate,
+		dns_p50_ms, dns_p95_ms, connect_p50_ms, connect_p95_ms, tls_p50_ms, tls_p95_ms, ttfb_p50_ms, ttfb_p95_ms, transfer_p50_ms, transfer_p95_ms) VALUES `,
+		` ON CONFLICT (run_id, host, bucket_start) DO UPDATE SET req_count=EXCLUDED.req_count, err_count=EXCLUDED.err_count, p50_ms=EXCLUDED.p50_ms, p95_ms=EXCLUDED.p95_ms,
+		bytes=EXCLUDED.bytes, reuse_rate=EXCLUDED.reuse_rate, dns_p50_ms=EXCLUDED.dns_p50_ms, dns_p95_ms=EXCLUDED.dns_p95_ms, connect_p50_ms=EXCLUDED.connect_p50_ms,
+		connect_p95_ms=EXCLUDED.connect_p95_ms, tls_p50_ms=EXCLUDED.tls_p50_ms, tls_p95_ms=EXCLUDED.tls_p95_ms, ttfb_p50_ms=EXCLUDED.ttfb_p50_ms, ttfb_p95_ms=EXCLUDED.ttfb_p95_ms,
+		transfer_p50_ms=EXCLUDED.transfer_p50_ms, transfer_p95_ms=EXCLUDED.transfer_p95_ms`, rows)
+}
+
+func (s *SQLStore) ListHostStats(ctx context.Context, runID uuid.UUID) ([]HostStatRecord, error) {
+	return s.QueryHostStats(ctx, runID, HostStatQuery{})
+}
+
+// HostStatQuery narrows host_stats to one host and a half-open
+// [From, To) range of bucket starts.
+type HostStatQuery struct {
+	Host string
+	From *time.Time
+	To   *time.Time
+}
+
+// QueryHostStats returns buckets ordered by host, then bucket start.
+func (s *SQLStore) QueryHostStats(ctx context.Context, runID uuid.UUID, q HostStatQuery) ([]HostStatRecord, error) {
+	where := `run_id=$1`
+	args := []any{runID}
+	if q.Host != "" {
+		args = append(args, q.Host)
+		where += ` AND host=$` + strconv.Itoa(len(args))
+	}
+	if q.From != nil {
+		args = append(args, q.From.UTC())
+		where += ` AND bucket_start>=$` + strconv.Itoa(len(args))
+	}
+	if q.To != nil {
+		args = append(args, q.To.UTC())
+		where += ` AND bucket_start<$` + strconv.Itoa(len(args))
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT host, bucket_start, COALESCE(req_count, 0), COALESCE(err_count, 0), COALESCE(p50_ms, 0), COALESCE(p95_ms, 0), COALESCE(bytes, 0), COALESCE(reuse_rate, 0),
+		COALESCE(dns_p50_ms, 0), COALESCE(dns_p95_ms, 0), COALESCE(connect_p50_ms, 0), COALESCE(connect_p95_ms, 0), COALESCE(tls_p50_ms, 0), COALESCE(tls_p95_ms, 0),
+		COALESCE(ttfb_p50_ms, 0), COALESCE(ttfb_p95_ms, 0), COALESCE(transfer_p50_ms, 0), COALESCE(transfer_p95_ms, 0)
+		FROM host_stats WHERE `+where+` ORDER BY host, bucket_start`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HostStatRecord
+	for rows.Next() {
+		rec := HostStatRecord{RunID: runID}
+		if err := rows.Scan(&rec.Host, &rec.BucketStart, &rec.Requests, &rec.Errors, &rec.Latency.P50MS, &rec.Latency.P95MS, &rec.Bytes, &rec.ReuseRate,
+			&rec.DNS.P50MS, &rec.DNS.P95MS, &rec.Connect.P50MS, &rec.Connect.P95MS, &rec.TLS.P50MS, &rec.TLS.P95MS,
+			&rec.TTFB.P50MS, &rec.TTFB.P95MS, &rec.Transfer.P50MS, &rec.Transfer.P95MS); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// HostSecurityRecord is the TLS and security-header inventory of an HTTPS
+// host, taken from the first response the crawler received from it.
+type HostSecurityRecord struct {
+	RunID          uuid.UUID  `json:"-"`
+	Host           string     `json:"host"`
+	TLSVersion     string     `json:"tls_version"`
+	Cipher         string     `json:"cipher"`
+	CertSubject    string     `json:"cert_subject"`
+	CertIssuer     string     `json:"cert_issuer"`
+	CertSANs       []string   `json:"cert_sans"`
+	CertNotAfter   *time.Time `json:"cert_not_after"`
+	CertVerified   bool       `json:"cert_verified"`
+	CertError      string     `json:"cert_error"`
+	HSTS           string     `json:"hsts"`
+	CSP            string     `json:"csp"`
+	XFrameOptions  string     `json:"x_frame_options"`
+	ReferrerPolicy string     `json:"referrer_policy"`
+	InspectedAt    time.Time  `json:"inspected_at"`
+}
+
+func (s *SQLStore) UpsertHostSecurity(ctx context.Context, rec HostSecurityRecord) error {
+	return s.UpsertHostSecurities(ctx, []HostSecurityRecord{rec})
+}
+
+// UpsertHostSecurities stores inspections; a host given twice keeps the later
+// record.
+func (s *SQLStore) UpsertHostSecurities(ctx context.Context, recs []HostSecurityRecord) error {
+	recs = lastByKey(recs, func(rec HostSecurityRecord) [2]string { return [2]string{rec.RunID.String(), rec.Host} })
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		sans, err := json.Marshal(rec.CertSANs)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{rec.RunID, rec.Host, nullableString(rec.TLSVersion), nullableString(rec.Cipher), nullableString(rec.CertSubject), nullableString(rec.CertIssuer), sans, rec.CertNotAfter, rec.CertVerified,
+			nullableString(rec.CertError), nullableString(rec.HSTS), nullableString(rec.CSP), nullableString(rec.XFrameOptions), nullableString(rec.ReferrerPolicy), rec.InspectedAt})
+	}
+	return s.insertRows(ctx, `INSERT INTO hosts (run_id, host, tls_version, tls_cipher, cert_subject, cert_issuer, cert_sans, cert_not_after, cert_verified, cert_error, hsts, csp, x_frame_options, referrer_policy, inspected_at) VALUES `,
+		` ON CONFLICT (run_id, host) DO UPDATE SET tls_version=EXCLUDED.tls_version, tls_cipher=EXCLUDED.tls_cipher, cert_subject=EXCLUDED.cert_subject, cert_issuer=EXCLUDED.cert_issuer,
+	cert_sans=EXCLUDED.cert_sans, cert_not_after=EXCLUDED.cert_not_after, cert_verified=EXCLUDED.cert_verified, cert_error=EXCLUDED.cert_error, hsts=EXCLUDED.hsts, csp=EXCLUDED.csp,
+	x_frame_options=EXCLUDED.x_frame_options, referrer_policy=EXCLUDED.referrer_policy, inspected_at=EXCLUDED.inspected_at`, rows)
+}
+
+func (s *SQLStore) ListHostSecurity(ctx context.Context, runID uuid.UUID) ([]HostSecurityRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT host, COALESCE(tls_version, ''), COALESCE(tls_cipher, ''), COALESCE(cert_subject, ''), COALESCE(cert_issuer, ''), cert_sans, cert_not_after, COALESCE(cert_verified, false),
+		COALESCE(cert_error, ''), COALESCE(hsts, ''), COALESCE(csp, ''), COALESCE(x_frame_options, ''), COALESCE(referrer_policy, ''), inspected_at
+		FROM hosts WHERE run_id=$1 AND inspected_at IS NOT NULL ORDER BY host`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HostSecurityRecord
+	for rows.Next() {
+		rec := HostSecurityRecord{RunID: runID}
+		var sans []byte
+		var notAfter sql.NullTime
+		if err := rows.Scan(&rec.Host, &rec.TLSVersion, &rec.Cipher, &rec.CertSubject, &rec.CertIssuer, &sans, &notAfter, &rec.CertVerified,
+			&rec.CertError, &rec.HSTS, &rec.CSP, &rec.XFrameOptions, &rec.ReferrerPolicy, &rec.InspectedAt); err != nil {
+			return nil, err
+		}
+		if len(sans) > 0 {
+			if err := json.Unmarshal(sans, &rec.CertSANs); err != nil {
+				return nil, err
+			}
+		}
+		if notAfter.Valid {
+			t := notAfter.Time
+			rec.CertNotAfter = &t
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// HostStateRecord is the last robots.txt and circuit breaker state the
+// crawler saw for a host.
+type HostStateRecord struct {
+	RunID        uuid.UUID `json:"-"`
+	Host         string    `json:"host"`
+	RobotsState  string    `json:"robots_state"`
+	CircuitState string    `json:"circuit_state"`
+}
+
+func (s *SQLStore) UpsertHostState(ctx context.Context, rec HostStateRecord) error {
+	return s.UpsertHostStates(ctx, []HostStateRecord{rec})
+}
+
+// UpsertHostStates stores robots and breaker states; a host given twice keeps
+// the later record.
+func (s *SQLStore) UpsertHostStates(ctx context.Context, recs []HostStateRecord) error {
+	recs = lastByKey(recs, func(rec HostStateRecord) [2]string { return [2]string{rec.RunID.String(), rec.Host} })
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, rec.Host, nullableString(rec.RobotsState), nullableString(rec.CircuitState)})
+	}
+	return s.insertRows(ctx, `INSERT INTO hosts (run_id, host, robots_state, circuit_state) VALUES `,
+		` ON CONFLICT (run_id, host) DO UPDATE SET robots_state=EXCLUDED.robots_state, circuit_state=EXCLUDED.circuit_state`, rows)
+}
+
+func (s *SQLStore) ListHostStates(ctx context.Context, runID uuid.UUID) ([]HostStateRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT host, COALESCE(robots_state, ''), COALESCE(circuit_state, '') FROM hosts
+		WHERE run_id=$1 AND (robots_state IS NOT NULL OR circuit_state IS NOT NULL) ORDER BY host`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HostStateRecord
+	for rows.Next() {
+		rec := HostStateRecord{RunID: runID}
+		if err := rows.Scan(&rec.Host, &rec.RobotsState, &rec.CircuitState); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// HostCounts tallies a run's pages on one host by outcome.
+type HostCounts struct {
+	Host      string `json:"host"`
+	Pages     int64  `json:"pages"`
+	Fetched   int64  `json:"fetched"`
+	Failed    int64  `json:"failed"`
+	Status2xx int64  `json:"status_2xx"`
+	Status3xx int64  `json:"status_3xx"`
+	Status4xx int64  `json:"status_4xx"`
+	Status5xx int64  `json:"status_5xx"`
+	Bytes     int64  `json:"bytes"`
+	MinDepth  int    `json:"min_depth"`
+}
+
+func (s *SQLStore) HostPageCounts(ctx context.Context, runID uuid.UUID) ([]HostCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT host, COUNT(*), COUNT(fetched_at),
+		COUNT(*) FILTER (WHERE error_class IS NOT NULL),
+		COUNT(*) FILTER (WHERE status_code BETWEEN 200 AND 299),
+		COUNT(*) FILTER (WHERE status_code BETWEEN 300 AND 399),
+		COUNT(*) FILTER (WHERE status_code BETWEEN 400 AND 499),
+		COUNT(*) FILTER (WHERE status_code BETWEEN 500 AND 599),
+		COALESCE(SUM(size_bytes), 0), MIN(depth)
+		FROM pages WHERE run_id=$1 GROUP BY host ORDER BY host`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []HostCounts
+	for rows.Next() {
+		var c HostCounts
+		if err := rows.Scan(&c.Host, &c.Pages, &c.Fetched, &c.Failed, &c.Status2xx, &c.Status3xx, &c.Status4xx, &c.Status5xx, &c.Bytes, &c.MinDepth); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+type SkipRecord struct {
+	RunID    uuid.UUID `json:"-"`
+	URL      string    `json:"url"`
+	Host     string    `json:"host"`
+	Depth    int       `json:"depth"`
+	Reason   string    `json:"reason"`
+	Detail   string    `json:"detail"`
+	Referrer string    `json:"referrer"`
+	At       time.Time `json:"at"`
+}
+
+func (s *SQLStore) InsertSkip(ctx context.Context, rec SkipRecord) error {
+	return s.InsertSkips(ctx, []SkipRecord{rec})
+}
+
+func (s *SQLStore) InsertSkips(ctx context.Context, recs []SkipRecord) error {
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		rows = append(rows, []any{rec.RunID, rec.URL, nullableString(rec.Host), rec.Depth, rec.Reason, nullableString(rec.Detail), nullableString(rec.Referrer), rec.At})
+	}
+	return s.insertRows(ctx, `INSERT INTO skipped (run_id, url, host, depth, reason, detail, referrer_url, at) VALUES `, ``, rows)
+}
+
+func (s *SQLStore) ListSkipped(ctx context.Context, runID uuid.UUID, reason string, limit int) ([]SkipRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT url, COALESCE(host, ''), COALESCE(depth, 0), reason, COALESCE(detail, ''), COALESCE(referrer_url, ''), at
+		FROM skipped WHERE run_id=$1 AND ($2 = '' OR reason = $2)
+		ORDER BY at DESC, id DESC LIMIT $3`, runID, reason, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SkipRecord
+	for rows.Next() {
+		rec := SkipRecord{RunID: runID}
+		if err := rows.Scan(&rec.URL, &rec.Host, &rec.Depth, &rec.Reason, &rec.Detail, &rec.Referrer, &rec.At); err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// RunAnalyticsRecord is the stored output of a run's graph analytics job.
+// Result is JSON encoded by the analytics package in format Version.
+type RunAnalyticsRecord struct {
+	RunID      uuid.UUID
+	Version    int
+	Result     []byte
+	ComputedAt time.Time
+}
+
+func (s *SQLStore) UpsertRunAnalytics(ctx context.Context, rec RunAnalyticsRecord) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO run_analytics (run_id, version, result, computed_at) VALUES ($1,$2,$3,$4)
+	ON CONFLICT (run_id) DO UPDATE SET version=$2, result=$3, computed_at=$4`,
+		rec.RunID, rec.Version, rec.Result, rec.ComputedAt)
+	return err
+}
+
+// GetRunAnalytics returns nil when analytics were never computed for the run.
+func (s *SQLStore) GetRunAnalytics(ctx context.Context, runID uuid.UUID) (*RunAnalyticsRecord, error) {
+	rec := RunAnalyticsRecord{RunID: runID}
+	err := s.db.QueryRowContext(ctx, `SELECT version, result, computed_at FROM run_analytics WHERE run_id=$1`, runID).
+		Scan(&rec.Version, &rec.Result, &rec.ComputedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Schedule overlap policies decide what happens when a schedule fires while
+// its previous run is still running.
+const (
+	OverlapSkip   = "skip"
+	OverlapQueue  = "queue"
+	OverlapCancel = "cancel"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ScheduleRecord is a crawl template started on a cron expression. Config is
+// the run configuration as encoded by the crawler, tagged with
+// ConfigVersion.
+type ScheduleRecord struct {
+	ID            uuid.UUID
+	Name          string
+	Cron          string
+	Timezone      string
+	Overlap       string
+	Enabled       bool
+	Config        []byte
+	ConfigVersion int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const scheduleColumns = `id, name, cron, timezone, overlap, enabled, config, config_version, created_at, updated_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (ScheduleRecord, error) {
+	var rec ScheduleRecord
+	err := row.Scan(&rec.ID, &rec.Name, &rec.Cron, &rec.Timezone, &rec.Overlap, &rec.Enabled, &rec.Config, &rec.ConfigVersion, &rec.CreatedAt, &rec.UpdatedAt)
+	return rec, err
+}
+
+// CreateSchedule stores rec under a new id; CreatedAt and UpdatedAt are set
+// to now when zero.
+func (s *SQLStore) CreateSchedule(ctx context.Context, rec ScheduleRecord) (uuid.UUID, error) {
+	id := uuid.New()
+	now := time.Now().UTC()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = now
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO schedules (`+scheduleColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+		id, rec.Name, rec.Cron, rec.Timezone, rec.Overlap, rec.Enabled, rec.Config, rec.ConfigVersion, rec.CreatedAt, rec.UpdatedAt)
+	return id, err
+}
+
+// UpdateSchedule replaces every field but the id and creation time.
+func (s *SQLStore) UpdateSchedule(ctx context.Context, rec ScheduleRecord) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE schedules SET name=$1, cron=$2, timezone=$3, overlap=$4, enabled=$5, config=$6, config_version=$7, updated_at=$8 WHERE id=$9`,
+		rec.Name, rec.Cron, rec.Timezone, rec.Overlap, rec.Enabled, rec.Config, rec.ConfigVersion, rec.UpdatedAt, rec.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+// GetSchedule returns nil when there is no schedule with the id.
+func (s *SQLStore) GetSchedule(ctx context.Context, id uuid.UUID) (*ScheduleRecord, error) {
+	rec, err := scanSchedule(s.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id=$1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *SQLStore) ListSchedules(ctx context.Context) ([]ScheduleRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ScheduleRecord
+	for rows.Next() {
+		rec, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSchedule removes the schedule; its runs keep their schedule_id.
+func (s *SQLStore) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM schedules WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+// URLEvent is one step in a URL's lifecycle, keyed by canonical URL.
+type URLEvent struct {
+	RunID  uuid.UUID      `json:"-"`
+	URL    string         `json:"url"`
+	Event  string         `json:"event"`
+	Detail map[string]any `json:"detail"`
+	At     time.Time      `json:"at"`
+}
+
+func (s *SQLStore) InsertURLEvent(ctx context.Context, rec URLEvent) error {
+	return s.InsertURLEvents(ctx, []URLEvent{rec})
+}
+
+func (s *SQLStore) InsertURLEvents(ctx context.Context, recs []URLEvent) error {
+	rows := make([][]any, 0, len(recs))
+	for _, rec := range recs {
+		detail, err := nullableJSON(rec.Detail, len(rec.Detail) == 0)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{rec.RunID, rec.URL, rec.Event, detail, rec.At})
+	}
+	return s.insertRows(ctx, `INSERT INTO url_events (run_id, url, event, detail, at) VALUES `, ``, rows)
+}
+
+func (s *SQLStore) ListURLEvents(ctx context.Context, runID uuid.UUID, canonical string, limit int) ([]URLEvent, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT url, event, detail, at
+		FROM url_events WHERE run_id=$1 AND url=$2
+		ORDER BY at, id LIMIT $3`, runID, canonical, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []URLEvent
+	for rows.Next() {
+		rec := URLEvent{RunID: runID}
+		var detail []byte
+		if err := rows.Scan(&rec.URL, &rec.Event, &detail, &rec.At); err != nil {
+			return nil, err
+		}
+		if len(detail) > 0 {
+			if err := json.Unmarshal(detail, &rec.Detail); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// Run event kinds.
+const (
+	RunEventConfig = "config"
+	RunEventPause  = "pause"
+	RunEventResume = "resume"
+	RunEventStop   = "stop"
+)
+
+// RunEvent is one change made to a run by an operator, such as a live
+// configuration change, with who made it.
+type RunEvent struct {
+	RunID  uuid.UUID      `json:"-"`
+	Kind   string         `json:"kind"`
+	Actor  string         `json:"actor"`
+	Detail map[string]any `json:"detail,omitempty"`
+	At     time.Time      `json:"at"`
+}
+
+func (s *SQLStore) InsertRunEvent(ctx context.Context, rec RunEvent) error {
+	detail, err := nullableJSON(rec.Detail, len(rec.Detail) == 0)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO run_events (run_id, kind, actor, detail, at) VALUES ($1,$2,$3,$4,$5)`,
+		rec.RunID, rec.Kind, rec.Actor, detail, rec.At)
+	return err
+}
+
+// ListRunEvents returns the run's events, oldest first.
+func (s *SQLStore) ListRunEvents(ctx context.Context, runID uuid.UUID, limit int) ([]RunEvent, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, actor, detail, at
+		FROM run_events WHERE run_id=$1
+		ORDER BY at, id LIMIT $2`, runID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []RunEvent
+	for rows.Next() {
+		rec := RunEvent{RunID: runID}
+		var detail []byte
+		if err := rows.Scan(&rec.Kind, &rec.Actor, &detail, &rec.At); err != nil {
+			return nil, err
+		}
+		if len(detail) > 0 {
+			if err := json.Unmarshal(detail, &rec.Detail); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// textTime scans a timestamp that may arrive as text. SQLite only converts
+// columns declared as timestamps, so aggregates like MAX(fetched_at) come
+// back as the driver's string encoding.
+type textTime struct {
+	sql.NullTime
+}
+
+const textTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+func (t *textTime) Scan(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return t.NullTime.Scan(v)
+	}
+	parsed, err := time.Parse(textTimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time, t.Valid = parsed, true
+	return nil
+}
+
+func nullableString(s string) sql.NullString {
+	if s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: s, Valid: true}
+}
+
+func nullableStringPtr(s *string) sql.NullString {
+	if s == nil || *s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func nullableInt(n int) sql.NullInt32 {
+	if n == 0 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}
+}
+
+func nullableJSON(v any, empty bool) ([]byte, error) {
+	if empty {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func nullableUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
 }
 
 func nullableInt64(n int64) sql.NullInt64 {